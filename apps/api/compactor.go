@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/retention"
+	"github.com/sspier/cloudpulse/internal/store"
+)
+
+// compactInterval is how often the background compactor applies the retention policy
+const compactInterval = 10 * time.Minute
+
+// resultRetention is the global retention of results, targets can override it
+// set from the environment in main
+var resultRetention = retention.Default
+
+// compact deletes the results the retention policy no longer keeps
+// only stores that don't expire results on their own (everything but DynamoDB) implement store.Compactor
+func compact(ctx context.Context, now time.Time) {
+	compactor, ok := targetStore.(store.Compactor)
+	if !ok {
+		return
+	}
+
+	deleted, err := compactor.Compact(ctx, resultRetention, now)
+	if err != nil {
+		log.Printf("compactor: failed to apply retention: %v\n", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("compactor: deleted %d expired results\n", deleted)
+	}
+}
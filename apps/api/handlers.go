@@ -1,30 +1,83 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/sspier/cloudpulse/internal/alert"
+	"github.com/sspier/cloudpulse/internal/labels"
+	"github.com/sspier/cloudpulse/internal/maintenance"
+	"github.com/sspier/cloudpulse/internal/metrics"
 	"github.com/sspier/cloudpulse/internal/model"
 	"github.com/sspier/cloudpulse/internal/probe"
+	"github.com/sspier/cloudpulse/internal/retention"
+	"github.com/sspier/cloudpulse/internal/rollup"
+	"github.com/sspier/cloudpulse/internal/slo"
 	"github.com/sspier/cloudpulse/internal/store"
+	"github.com/sspier/cloudpulse/internal/tracing"
+	"sigs.k8s.io/yaml"
 )
 
 // targetStore is the global store instance.
 // In a real app, we might inject this dependency.
 var targetStore store.Store = NewInMemoryStore()
 
+// targetAlerter notifies about targets going down and recovering, main configures it from the environment
+// the zero value has no notifiers and only records results
+var targetAlerter = &alert.Alerter{}
+
 // runCheck performs a single probe of the target url and records the result
 // this is called both when a target is created and by the background scheduler
-func runCheck(t model.Target) {
+// the store and alerter are passed in rather than read from the globals, the probe outlives the request
+// that started it and must keep writing to the store it was started with
+func runCheck(checkStore store.Store, alerter *alert.Alerter, t model.Target) {
+	// a target can be created or imported paused, it isn't probed until resumed
+	if t.Paused {
+		return
+	}
+
+	// let the scheduler know this target was just probed so it waits a full interval
+	probeScheduler.markRun(t.ID, time.Now())
+
+	// one trace per check, so the probe and the store calls that record it show up together
+	ctx, span := tracing.Tracer().Start(context.Background(), "runCheck")
+	defer span.End()
+
 	// Use the shared probe logic
-	result := probe.Check(context.Background(), t)
+	result := probe.Check(ctx, t)
+
+	// the target may have been deleted while the probe was in flight, don't resurrect its history
+	if _, err := checkStore.GetTarget(ctx, t.ID); errors.Is(err, store.ErrNotFound) {
+		return
+	}
+
+	// probes during a maintenance window are kept, but recorded with the maintenance status
+	windows, err := checkStore.ListMaintenanceWindows(ctx)
+	if err != nil {
+		log.Printf("failed to list maintenance windows: %v", err)
+	}
+	maintenance.Apply(windows, t, &result)
+
+	// export the outcome on /metrics
+	metrics.ObserveResult(t, result)
 
 	// store the probe result so it can be retrieved via GET /results and GET /results/{id}
-	targetStore.AddResult(context.Background(), result)
+	// and let the alerter know, it notifies when the result changes the target's state
+	if err := alerter.RecordResult(ctx, checkStore, t, result); err != nil {
+		log.Printf("failed to store result for %s: %v", t.ID, err)
+	}
 }
 
 // simple health endpoint used by load balancers and humans
@@ -32,6 +85,223 @@ func healthHandler(responseWriter http.ResponseWriter, _ *http.Request) {
 	fmt.Fprintln(responseWriter, "ok")
 }
 
+// validateTarget checks a target definition before it is stored
+// it also fills in defaults, which is why it takes a pointer
+func validateTarget(target *model.Target) error {
+	// targets without a type are http targets
+	if target.Type == "" {
+		target.Type = model.TargetTypeHTTP
+	}
+
+	switch target.Type {
+	case model.TargetTypeHTTP:
+		// validate URL
+		parsedURL, err := url.ParseRequestURI(target.URL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+			return errors.New("invalid URL: must be http or https")
+		}
+
+		// validate the request settings
+		if err := validateHTTPRequestSettings(target); err != nil {
+			return err
+		}
+
+		// validate the response assertions
+		if err := probe.ValidateAssertions(target.Assertions); err != nil {
+			return fmt.Errorf("invalid assertions: %w", err)
+		}
+
+		if target.CertExpiryWarningDays < 0 {
+			return errors.New("invalid certExpiryWarningDays: must not be negative")
+		}
+
+	case model.TargetTypeTCP:
+		// tcp targets are addressed as host:port with a numeric port
+		host, port, err := net.SplitHostPort(target.URL)
+		if err != nil || host == "" {
+			return errors.New("invalid address: tcp targets must be host:port")
+		}
+		if portNumber, err := strconv.Atoi(port); err != nil || portNumber < 1 || portNumber > 65535 {
+			return errors.New("invalid address: port must be between 1 and 65535")
+		}
+		// a plain tcp connect has nothing to send
+		if target.Method != "" || len(target.Headers) > 0 || target.Body != "" {
+			return errors.New("invalid target: method, headers and body are only supported for http targets")
+		}
+		// without a response only the latency can be asserted on
+		if assertions := target.Assertions; assertions != nil {
+			if len(assertions.StatusCodes) > 0 || assertions.BodyContains != "" || assertions.BodyNotContains != "" ||
+				assertions.BodyRegex != "" || len(assertions.JSONPath) > 0 || len(assertions.Headers) > 0 {
+				return errors.New("invalid assertions: tcp targets only support maxLatencyMs")
+			}
+			if assertions.MaxLatencyMs < 0 {
+				return errors.New("invalid assertions: invalid max latency: must not be negative")
+			}
+		}
+
+	default:
+		return fmt.Errorf("invalid type %q: must be http or tcp", target.Type)
+	}
+
+	for _, tag := range target.Tags {
+		if strings.TrimSpace(tag) == "" {
+			return errors.New("invalid tags: tags must not be empty")
+		}
+	}
+
+	if err := labels.Validate(target.Labels); err != nil {
+		return fmt.Errorf("invalid labels: %w", err)
+	}
+
+	// zero keeps the global retention
+	if target.RetentionDays < 0 || target.RetentionDays > retention.MaxDays {
+		return fmt.Errorf("invalid retentionDays: must be between 0 and %d", retention.MaxDays)
+	}
+	if target.MaxResults < 0 || target.MaxResults > retention.MaxMaxResults {
+		return fmt.Errorf("invalid maxResults: must be between 0 and %d", retention.MaxMaxResults)
+	}
+
+	return validateTimingPolicy(*target)
+}
+
+// limits for the per-target timing policy
+const (
+	maxTimeoutSeconds  = 60
+	minIntervalSeconds = 10
+	maxIntervalSeconds = 24 * 60 * 60
+	maxRetries         = 5
+	// a whole probe, retries included, has to finish within the runner Lambda's run
+	// the Lambda timeout in infra/modules/runner leaves room on top of this to load targets and store results
+	maxProbeSeconds = 60
+)
+
+// validateTimingPolicy checks the timeout, interval and retries of a target
+// zero values are allowed and mean "use the default"
+func validateTimingPolicy(target model.Target) error {
+	if target.TimeoutSeconds < 0 || target.TimeoutSeconds > maxTimeoutSeconds {
+		return fmt.Errorf("invalid timeoutSeconds: must be between 1 and %d", maxTimeoutSeconds)
+	}
+	if target.IntervalSeconds != 0 && (target.IntervalSeconds < minIntervalSeconds || target.IntervalSeconds > maxIntervalSeconds) {
+		return fmt.Errorf("invalid intervalSeconds: must be between %d and %d", minIntervalSeconds, maxIntervalSeconds)
+	}
+	if target.Retries < 0 || target.Retries > maxRetries {
+		return fmt.Errorf("invalid retries: must be between 0 and %d", maxRetries)
+	}
+	if probe.MaxDuration(target) > maxProbeSeconds*time.Second {
+		return fmt.Errorf("invalid retries: timeoutSeconds * (retries + 1) plus %v between retries must not exceed %d seconds", time.Second, maxProbeSeconds)
+	}
+	// a probe that can outlive its interval would pile up behind itself
+	if target.Timeout() >= target.Interval() {
+		return errors.New("invalid timeoutSeconds: must be shorter than the interval")
+	}
+	return nil
+}
+
+// allowedMethods lists the http methods a target may be probed with
+var allowedMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// validateHTTPRequestSettings checks the method and headers of an http target
+// the method is normalized to upper case, which is why it takes a pointer
+func validateHTTPRequestSettings(target *model.Target) error {
+	target.Method = strings.ToUpper(target.Method)
+	if target.Method == "" {
+		target.Method = http.MethodGet
+	}
+	if !allowedMethods[target.Method] {
+		return fmt.Errorf("invalid method %q", target.Method)
+	}
+
+	for headerName, headerValue := range target.Headers {
+		if !isHeaderToken(headerName) {
+			return fmt.Errorf("invalid header name %q", headerName)
+		}
+		// line breaks would let a header value inject extra headers
+		if strings.ContainsAny(headerValue, "\r\n") {
+			return fmt.Errorf("invalid value for header %q", headerName)
+		}
+	}
+
+	return nil
+}
+
+// isHeaderToken reports whether name is a valid http header field name (an RFC 7230 token)
+func isHeaderToken(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, character := range name {
+		switch {
+		case character >= 'a' && character <= 'z',
+			character >= 'A' && character <= 'Z',
+			character >= '0' && character <= '9',
+			strings.ContainsRune("!#$%&'*+-.^_`|~", character):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// redactedHeaderValue stands in for the header values of a target in responses, they often hold credentials
+// a PUT or PATCH that sends it back keeps the stored value, so a target read through the API can be written back as is
+const redactedHeaderValue = "[redacted]"
+
+// redactHeaders returns the target with every header value replaced by redactedHeaderValue
+// the header map is copied, the target may be the one the store holds
+func redactHeaders(target model.Target) model.Target {
+	if len(target.Headers) == 0 {
+		return target
+	}
+	redacted := make(map[string]string, len(target.Headers))
+	for headerName := range target.Headers {
+		redacted[headerName] = redactedHeaderValue
+	}
+	target.Headers = redacted
+	return target
+}
+
+// redactAllHeaders redacts the headers of every target, see redactHeaders
+func redactAllHeaders(targets []model.Target) []model.Target {
+	redacted := make([]model.Target, 0, len(targets))
+	for _, target := range targets {
+		redacted = append(redacted, redactHeaders(target))
+	}
+	return redacted
+}
+
+// hasRedactedHeaders reports whether any header of the target was sent back as redactedHeaderValue
+func hasRedactedHeaders(target model.Target) bool {
+	for _, headerValue := range target.Headers {
+		if headerValue == redactedHeaderValue {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreRedactedHeaders replaces the headers sent back as redactedHeaderValue with their value in stored
+func restoreRedactedHeaders(target *model.Target, stored model.Target) error {
+	for headerName, headerValue := range target.Headers {
+		if headerValue != redactedHeaderValue {
+			continue
+		}
+		storedValue, ok := stored.Headers[headerName]
+		if !ok {
+			return fmt.Errorf("invalid value for header %q: %s only keeps a stored value", headerName, redactedHeaderValue)
+		}
+		target.Headers[headerName] = storedValue
+	}
+	return nil
+}
+
 // targetsHandler handles target creation and listing
 // GET returns all targets
 // POST registers a new target and kicks off an immediate probe
@@ -41,6 +311,13 @@ func targetsHandler(responseWriter http.ResponseWriter, request *http.Request) {
 	switch request.Method {
 
 	case http.MethodGet:
+		// ?labels=team=payments,env=prod narrows the list down to matching targets
+		selector, err := labels.ParseSelector(request.URL.Query().Get("labels"))
+		if err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// pull all targets from the store
 		targets, err := targetStore.ListTargets(request.Context())
 		if err != nil {
@@ -48,33 +325,34 @@ func targetsHandler(responseWriter http.ResponseWriter, request *http.Request) {
 			return
 		}
 
+		targets = redactAllHeaders(matchingTargets(targets, selector))
+
 		responseWriter.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(responseWriter).Encode(targets); err != nil {
 			log.Println("error encoding targets:", err)
 		}
 
 	case http.MethodPost:
-		// small inline struct for decoding POST body
-		var payload struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		}
+		// decode the full target definition from the POST body
+		var target model.Target
 
 		// reject invalid json bodies or missing fields
-		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+		if err := json.NewDecoder(request.Body).Decode(&target); err != nil {
 			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
 			return
 		}
 
-		// validate URL
-		parsedURL, err := url.ParseRequestURI(payload.URL)
-		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
-			http.Error(responseWriter, "invalid URL: must be http or https", http.StatusBadRequest)
+		// the store assigns IDs, never trust one from the client
+		target.ID = ""
+
+		// validate the target definition (type, url, ...)
+		if err := validateTarget(&target); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// create the target
-		created, err := targetStore.AddTarget(request.Context(), payload.Name, payload.URL)
+		created, err := targetStore.AddTarget(request.Context(), target)
 		if err != nil {
 			log.Printf("failed to add target: %v", err)
 			http.Error(responseWriter, "failed to create target", http.StatusInternalServerError)
@@ -82,68 +360,939 @@ func targetsHandler(responseWriter http.ResponseWriter, request *http.Request) {
 		}
 
 		// run an immediate uptime check in the background
-		go runCheck(created)
+		go runCheck(targetStore, targetAlerter, created)
 
 		responseWriter.WriteHeader(http.StatusCreated)
-		if err := json.NewEncoder(responseWriter).Encode(created); err != nil {
+		if err := json.NewEncoder(responseWriter).Encode(redactHeaders(created)); err != nil {
 			log.Println("error encoding created target:", err)
 		}
 	}
 }
 
-// resultsHandler returns the most recent probe result for each target
-// this is used for dashboards where you want an at-a-glance view
-func resultsHandler(responseWriter http.ResponseWriter, request *http.Request) {
-	if request.Method != http.MethodGet {
+// matchingTargets returns the targets whose labels match the selector, all of them for an empty selector
+func matchingTargets(targets []model.Target, selector labels.Selector) []model.Target {
+	if len(selector) == 0 {
+		return targets
+	}
+	matching := []model.Target{}
+	for _, target := range targets {
+		if selector.Matches(target.Labels) {
+			matching = append(matching, target)
+		}
+	}
+	return matching
+}
+
+// targetHandler handles a single target
+// GET returns the target
+// PUT replaces the target definition
+// PATCH merges the given fields into the stored target
+// DELETE removes the target, ?purge=true also deletes its probe history
+func targetHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	// extract target id from URL path
+	id := request.PathValue("id")
+	if id == "" {
+		http.Error(responseWriter, "target ID required", http.StatusBadRequest)
+		return
+	}
+
+	switch request.Method {
+
+	case http.MethodGet:
+		target, err := targetStore.GetTarget(request.Context(), id)
+		if err != nil {
+			writeStoreError(responseWriter, "target", err)
+			return
+		}
+		writeJSON(responseWriter, http.StatusOK, redactHeaders(target))
+
+	case http.MethodPut, http.MethodPatch:
+		var target, existing model.Target
+
+		if request.Method == http.MethodPatch {
+			// start from the stored target so fields missing from the body keep their value
+			var err error
+			existing, err = targetStore.GetTarget(request.Context(), id)
+			if err != nil {
+				writeStoreError(responseWriter, "target", err)
+				return
+			}
+			// round-trip through json to get a deep copy, decoding merges into maps and pointers
+			// and we must not mutate what the store handed us
+			encoded, err := json.Marshal(existing)
+			if err != nil || json.Unmarshal(encoded, &target) != nil {
+				http.Error(responseWriter, "internal error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := json.NewDecoder(request.Body).Decode(&target); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		// the id in the path wins, a target can't be renamed to another id
+		target.ID = id
+
+		// header values come back redacted from GET, those keep what is stored
+		if request.Method == http.MethodPut && hasRedactedHeaders(target) {
+			var err error
+			existing, err = targetStore.GetTarget(request.Context(), id)
+			if err != nil {
+				writeStoreError(responseWriter, "target", err)
+				return
+			}
+		}
+		if err := restoreRedactedHeaders(&target, existing); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := validateTarget(&target); err != nil {
+			http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		updated, err := targetStore.UpdateTarget(request.Context(), target)
+		if err != nil {
+			writeStoreError(responseWriter, "target", err)
+			return
+		}
+		writeJSON(responseWriter, http.StatusOK, redactHeaders(updated))
+
+	case http.MethodDelete:
+		// results are kept unless explicitly purged, so history survives an accidental delete
+		purgeResults := false
+		if purge := request.URL.Query().Get("purge"); purge != "" {
+			parsed, err := strconv.ParseBool(purge)
+			if err != nil {
+				http.Error(responseWriter, "invalid purge parameter: must be true or false", http.StatusBadRequest)
+				return
+			}
+			purgeResults = parsed
+		}
+
+		if err := targetStore.DeleteTarget(request.Context(), id, purgeResults); err != nil {
+			writeStoreError(responseWriter, "target", err)
+			return
+		}
+
+		// stop scheduling the deleted target and drop its series from /metrics
+		probeScheduler.forget(id)
+		metrics.ForgetTarget(id)
+		responseWriter.WriteHeader(http.StatusNoContent)
+
+	default:
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// targetPauseHandler returns the handler for POST /targets/{id}/pause (paused=true)
+// and POST /targets/{id}/resume (paused=false)
+func targetPauseHandler(paused bool) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := request.PathValue("id")
+		if id == "" {
+			http.Error(responseWriter, "target ID required", http.StatusBadRequest)
+			return
+		}
+
+		target, err := targetStore.GetTarget(request.Context(), id)
+		if err != nil {
+			writeStoreError(responseWriter, "target", err)
+			return
+		}
+
+		// pausing twice (or resuming an active target) is not an error, the end state is what matters
+		wasPaused := target.Paused
+		target.Paused = paused
+		updated, err := targetStore.UpdateTarget(request.Context(), target)
+		if err != nil {
+			writeStoreError(responseWriter, "target", err)
+			return
+		}
+
+		// probe a resumed target right away so its status doesn't stay stale for a whole interval
+		if wasPaused && !paused {
+			go runCheck(targetStore, targetAlerter, updated)
+		}
+
+		writeJSON(responseWriter, http.StatusOK, redactHeaders(updated))
+	}
+}
+
+// maxImportBytes limits the size of an import document, thousands of targets fit easily
+const maxImportBytes = 5 << 20
+
+// targetDocument is the format of /targets:import and /targets:export, written as YAML or JSON
+type targetDocument struct {
+	Targets []model.Target `json:"targets"`
+}
+
+// what an import does with an entry of the document
+const (
+	importCreated   = "created"
+	importUpdated   = "updated"
+	importUnchanged = "unchanged"
+	importInvalid   = "invalid"
+)
+
+// importEntry reports what the import did (or would do, in a dry run) with one target of the document
+type importEntry struct {
+	// Index is the position of the target in the document, starting at 0
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	// ID is the stored target, empty for invalid entries and for targets a dry run would create
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// importReport is the response of /targets:import
+type importReport struct {
+	DryRun    bool          `json:"dryRun"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Invalid   int           `json:"invalid"`
+	Entries   []importEntry `json:"entries"`
+}
+
+// targetsImportHandler creates and updates targets from a YAML or JSON document
+// POST /targets:import with {"targets": [...]}, ?dryRun=true only reports what would change
+// targets are matched by name: a new name creates a target, a known one replaces its definition like PUT
+// the import is all or nothing, when any entry is invalid nothing is written and the report says why,
+// and when the store fails partway the targets written before are deleted or restored again
+func targetsImportHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
 		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	responseWriter.Header().Set("Content-Type", "application/json")
+	dryRun := false
+	if dryRunText := request.URL.Query().Get("dryRun"); dryRunText != "" {
+		parsed, err := strconv.ParseBool(dryRunText)
+		if err != nil {
+			http.Error(responseWriter, "invalid dryRun parameter: must be true or false", http.StatusBadRequest)
+			return
+		}
+		dryRun = parsed
+	}
 
-	results, err := targetStore.LatestResults(request.Context())
+	body, err := io.ReadAll(http.MaxBytesReader(responseWriter, request.Body, maxImportBytes))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(responseWriter, fmt.Sprintf("document too large: at most %d bytes", maxImportBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(responseWriter, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	// JSON is valid YAML, so one parser reads both
+	// unknown fields are rejected, a typo in a file of hundreds of targets would otherwise go unnoticed
+	var document targetDocument
+	if err := yaml.UnmarshalStrict(body, &document); err != nil {
+		http.Error(responseWriter, fmt.Sprintf("invalid document: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(document.Targets) == 0 {
+		http.Error(responseWriter, "invalid document: no targets", http.StatusBadRequest)
+		return
+	}
+
+	existingTargets, err := targetStore.ListTargets(request.Context())
 	if err != nil {
+		log.Printf("failed to list targets for import: %v", err)
 		http.Error(responseWriter, "internal error", http.StatusInternalServerError)
 		return
 	}
+	// ids differ between installations, names are what a document and the store have in common
+	targetsByName := map[string][]model.Target{}
+	for _, existing := range existingTargets {
+		targetsByName[existing.Name] = append(targetsByName[existing.Name], existing)
+	}
+
+	report := importReport{DryRun: dryRun, Entries: make([]importEntry, 0, len(document.Targets))}
+	// the stored definitions of the targets an import updates, to restore them if it fails
+	originals := make([]model.Target, len(document.Targets))
+	firstIndex := map[string]int{}
+	for index := range document.Targets {
+		target := &document.Targets[index]
+		entry := importEntry{Index: index, Name: target.Name}
+
+		if first, ok := firstIndex[target.Name]; ok && target.Name != "" {
+			entry.Action, entry.Error = importInvalid, fmt.Sprintf("duplicate name: already used by entry %d", first)
+		} else {
+			firstIndex[target.Name] = index
+			action, err := planTargetImport(target, targetsByName[target.Name])
+			entry.Action, entry.ID = action, target.ID
+			if err != nil {
+				entry.Action, entry.ID, entry.Error = importInvalid, "", err.Error()
+			}
+			if entry.Action == importUpdated {
+				originals[index] = targetsByName[target.Name][0]
+			}
+		}
+
+		switch entry.Action {
+		case importCreated:
+			report.Created++
+		case importUpdated:
+			report.Updated++
+		case importUnchanged:
+			report.Unchanged++
+		case importInvalid:
+			report.Invalid++
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	if report.Invalid > 0 {
+		writeJSON(responseWriter, http.StatusBadRequest, report)
+		return
+	}
+	if dryRun {
+		writeJSON(responseWriter, http.StatusOK, report)
+		return
+	}
 
-	if results == nil {
-		// always return [] instead of null for better json ergonomics
-		results = []model.Result{}
+	// the store has no transactions, targets are written one by one and undone when a write fails
+	var createdTargets []model.Target
+	for index, entry := range report.Entries {
+		var err error
+		switch entry.Action {
+		case importCreated:
+			var created model.Target
+			if created, err = targetStore.AddTarget(request.Context(), document.Targets[index]); err == nil {
+				report.Entries[index].ID = created.ID
+				createdTargets = append(createdTargets, created)
+			}
+		case importUpdated:
+			_, err = targetStore.UpdateTarget(request.Context(), document.Targets[index])
+		}
+		if err == nil {
+			continue
+		}
+
+		log.Printf("failed to import target %q, rolling back: %v", entry.Name, err)
+		// undo even when the client went away, a half applied import is worse than a slow one
+		if rollbackErr := rollbackImport(context.WithoutCancel(request.Context()), report.Entries[:index], originals); rollbackErr != nil {
+			log.Printf("failed to roll back import: %v", rollbackErr)
+			http.Error(responseWriter, fmt.Sprintf("failed to import target %q, and failed to undo the entries before it", entry.Name), http.StatusInternalServerError)
+			return
+		}
+		http.Error(responseWriter, fmt.Sprintf("failed to import target %q, nothing was imported", entry.Name), http.StatusInternalServerError)
+		return
 	}
 
-	responseWriter.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(responseWriter).Encode(results); err != nil {
-		log.Println("error encoding results:", err)
+	// like targets created through POST /targets, probe them right away
+	for _, created := range createdTargets {
+		go runCheck(targetStore, targetAlerter, created)
+	}
+
+	writeJSON(responseWriter, http.StatusOK, report)
+}
+
+// rollbackImport undoes the entries an import already wrote, newest first:
+// created targets are deleted with their results, updated ones get their stored definition back
+func rollbackImport(ctx context.Context, entries []importEntry, originals []model.Target) error {
+	var errs []error
+	for _, entry := range slices.Backward(entries) {
+		switch entry.Action {
+		case importCreated:
+			if err := targetStore.DeleteTarget(ctx, entry.ID, true); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete %q: %w", entry.Name, err))
+			}
+		case importUpdated:
+			if _, err := targetStore.UpdateTarget(ctx, originals[entry.Index]); err != nil {
+				errs = append(errs, fmt.Errorf("failed to restore %q: %w", entry.Name, err))
+			}
+		}
 	}
+	return errors.Join(errs...)
 }
 
-// resultsForTargetHandler returns the full probe history for a given target
-// useful for charts, graphs, or debugging uptime issues
-func resultsForTargetHandler(responseWriter http.ResponseWriter, request *http.Request) {
+// planTargetImport validates a target of an import document and decides what to do with it
+// matches are the stored targets with the same name, the target gets the id of the one it updates
+func planTargetImport(target *model.Target, matches []model.Target) (string, error) {
+	if strings.TrimSpace(target.Name) == "" {
+		return "", errors.New("invalid name: must not be empty")
+	}
+	if len(matches) > 1 {
+		return "", fmt.Errorf("ambiguous name: %d targets are named %q", len(matches), target.Name)
+	}
+
+	// an export without header values imports again, the redacted ones keep what the matching target has stored
+	var stored model.Target
+	if len(matches) == 1 {
+		stored = matches[0]
+	}
+	if err := restoreRedactedHeaders(target, stored); err != nil {
+		return "", err
+	}
+
+	// like POST /targets, never trust an id from the document
+	target.ID = ""
+	if err := validateTarget(target); err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return importCreated, nil
+	}
+
+	target.ID = matches[0].ID
+	// compare the encoded definitions, so a missing map and an empty one are the same
+	encodedTarget, targetErr := json.Marshal(target)
+	encodedMatch, matchErr := json.Marshal(matches[0])
+	if targetErr == nil && matchErr == nil && bytes.Equal(encodedTarget, encodedMatch) {
+		return importUnchanged, nil
+	}
+	return importUpdated, nil
+}
+
+// targetsExportHandler returns the targets as a document that /targets:import accepts
+// GET /targets:export?format=yaml|json (defaults to json), ?labels= narrows it down like GET /targets
+// header values are redacted unless ?includeHeaderValues=true, which is only needed to copy targets to another installation
+func targetsExportHandler(responseWriter http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodGet {
 		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	// extract target id from URL path
-	id := request.PathValue("id")
-	if id == "" {
-		http.Error(responseWriter, "target ID required", http.StatusBadRequest)
+	format := request.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "yaml" {
+		http.Error(responseWriter, "invalid format: must be json or yaml", http.StatusBadRequest)
 		return
 	}
 
-	responseWriter.Header().Set("Content-Type", "application/json")
+	includeHeaderValues := false
+	if includeText := request.URL.Query().Get("includeHeaderValues"); includeText != "" {
+		parsed, err := strconv.ParseBool(includeText)
+		if err != nil {
+			http.Error(responseWriter, "invalid includeHeaderValues parameter: must be true or false", http.StatusBadRequest)
+			return
+		}
+		includeHeaderValues = parsed
+	}
+
+	selector, err := labels.ParseSelector(request.URL.Query().Get("labels"))
+	if err != nil {
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// fetch the full list of results for this specific target
-	results, err := targetStore.ResultsForTarget(request.Context(), id)
+	targets, err := targetStore.ListTargets(request.Context())
 	if err != nil {
+		log.Printf("failed to list targets for export: %v", err)
 		http.Error(responseWriter, "internal error", http.StatusInternalServerError)
 		return
 	}
+	targets = matchingTargets(targets, selector)
+	if !includeHeaderValues {
+		targets = redactAllHeaders(targets)
+	}
 
-	if results == nil {
-		results = []model.Result{}
+	// sorted by name, so exports of the same targets diff cleanly
+	slices.SortStableFunc(targets, func(first, second model.Target) int {
+		return strings.Compare(first.Name, second.Name)
+	})
+	document := targetDocument{Targets: targets}
+
+	if format != "yaml" {
+		writeJSON(responseWriter, http.StatusOK, document)
+		return
+	}
+
+	encoded, err := yaml.Marshal(document)
+	if err != nil {
+		log.Printf("failed to encode targets as yaml: %v", err)
+		http.Error(responseWriter, "internal error", http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Header().Set("Content-Type", "application/yaml")
+	responseWriter.WriteHeader(http.StatusOK)
+	if _, err := responseWriter.Write(encoded); err != nil {
+		log.Println("error writing targets export:", err)
+	}
+}
+
+// uptimeHandler computes availability and latency statistics for a target
+// GET /targets/{id}/uptime?window=24h|7d|30d (defaults to 24h)
+func uptimeHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := request.PathValue("id")
+	if id == "" {
+		http.Error(responseWriter, "target ID required", http.StatusBadRequest)
+		return
+	}
+
+	windowParameter := request.URL.Query().Get("window")
+	if windowParameter == "" {
+		windowParameter = "24h"
+	}
+	window, err := slo.ParseWindow(windowParameter)
+	if err != nil {
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the target's interval is needed to weigh down probes
+	target, err := targetStore.GetTarget(request.Context(), id)
+	if err != nil {
+		writeStoreError(responseWriter, "target", err)
+		return
+	}
+
+	to := time.Now()
+	from := to.Add(-window)
+	results, err := targetStore.ResultsBetween(request.Context(), id, from, to)
+	if err != nil {
+		writeStoreError(responseWriter, "results", err)
+		return
+	}
+
+	uptime := slo.ComputeUptime(results, from, to, target.Interval())
+	uptime.TargetID = id
+	uptime.Window = windowParameter
+	writeJSON(responseWriter, http.StatusOK, uptime)
+}
+
+// maintenanceHandler handles maintenance window creation and listing
+// GET returns all windows
+// POST registers a new one-off or recurring window
+func maintenanceHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+
+	case http.MethodGet:
+		windows, err := targetStore.ListMaintenanceWindows(request.Context())
+		if err != nil {
+			writeStoreError(responseWriter, "maintenance window", err)
+			return
+		}
+		if windows == nil {
+			windows = []model.MaintenanceWindow{}
+		}
+		writeJSON(responseWriter, http.StatusOK, windows)
+
+	case http.MethodPost:
+		var window model.MaintenanceWindow
+		if err := json.NewDecoder(request.Body).Decode(&window); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		// the store assigns IDs
+		window.ID = ""
+
+		if err := maintenance.Validate(window); err != nil {
+			http.Error(responseWriter, "invalid maintenance window: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// catch typos in the target ID now rather than silently never matching
+		if window.TargetID != "" {
+			if _, err := targetStore.GetTarget(request.Context(), window.TargetID); err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					http.Error(responseWriter, "invalid maintenance window: unknown targetId", http.StatusBadRequest)
+					return
+				}
+				writeStoreError(responseWriter, "target", err)
+				return
+			}
+		}
+
+		created, err := targetStore.AddMaintenanceWindow(request.Context(), window)
+		if err != nil {
+			writeStoreError(responseWriter, "maintenance window", err)
+			return
+		}
+		writeJSON(responseWriter, http.StatusCreated, created)
+
+	default:
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// maintenanceWindowHandler handles a single maintenance window
+// GET returns the window, DELETE removes it
+func maintenanceWindowHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		http.Error(responseWriter, "maintenance window ID required", http.StatusBadRequest)
+		return
+	}
+
+	switch request.Method {
+
+	case http.MethodGet:
+		window, err := targetStore.GetMaintenanceWindow(request.Context(), id)
+		if err != nil {
+			writeStoreError(responseWriter, "maintenance window", err)
+			return
+		}
+		writeJSON(responseWriter, http.StatusOK, window)
+
+	case http.MethodDelete:
+		if err := targetStore.DeleteMaintenanceWindow(request.Context(), id); err != nil {
+			writeStoreError(responseWriter, "maintenance window", err)
+			return
+		}
+		responseWriter.WriteHeader(http.StatusNoContent)
+
+	default:
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// slosHandler handles SLO creation and listing
+// GET returns all SLO definitions
+// POST registers a new SLO for a target or a tag
+func slosHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+
+	case http.MethodGet:
+		objectives, err := targetStore.ListSLOs(request.Context())
+		if err != nil {
+			writeStoreError(responseWriter, "slo", err)
+			return
+		}
+		if objectives == nil {
+			objectives = []model.SLO{}
+		}
+		writeJSON(responseWriter, http.StatusOK, objectives)
+
+	case http.MethodPost:
+		var objective model.SLO
+		if err := json.NewDecoder(request.Body).Decode(&objective); err != nil {
+			http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		// the store assigns IDs
+		objective.ID = ""
+
+		if err := slo.Validate(objective); err != nil {
+			http.Error(responseWriter, "invalid slo: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// same as maintenance windows, an unknown target ID is almost certainly a typo
+		if objective.TargetID != "" {
+			if _, err := targetStore.GetTarget(request.Context(), objective.TargetID); err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					http.Error(responseWriter, "invalid slo: unknown targetId", http.StatusBadRequest)
+					return
+				}
+				writeStoreError(responseWriter, "target", err)
+				return
+			}
+		}
+
+		created, err := targetStore.AddSLO(request.Context(), objective)
+		if err != nil {
+			writeStoreError(responseWriter, "slo", err)
+			return
+		}
+		writeJSON(responseWriter, http.StatusCreated, created)
+
+	default:
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// sloHandler handles a single SLO
+// GET returns the SLO together with its current SLI, error budget and burn rates, DELETE removes it
+func sloHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	id := request.PathValue("id")
+	if id == "" {
+		http.Error(responseWriter, "slo ID required", http.StatusBadRequest)
+		return
+	}
+
+	switch request.Method {
+
+	case http.MethodGet:
+		objective, err := targetStore.GetSLO(request.Context(), id)
+		if err != nil {
+			writeStoreError(responseWriter, "slo", err)
+			return
+		}
+
+		report, err := evaluateSLO(request.Context(), objective, time.Now())
+		if err != nil {
+			writeStoreError(responseWriter, "results", err)
+			return
+		}
+		writeJSON(responseWriter, http.StatusOK, report)
+
+	case http.MethodDelete:
+		if err := targetStore.DeleteSLO(request.Context(), id); err != nil {
+			writeStoreError(responseWriter, "slo", err)
+			return
+		}
+		responseWriter.WriteHeader(http.StatusNoContent)
+
+	default:
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// evaluateSLO loads the results of every target the SLO selects over its window and computes the report
+func evaluateSLO(ctx context.Context, objective model.SLO, now time.Time) (slo.Report, error) {
+	window, err := slo.ParseWindow(objective.Window)
+	if err != nil {
+		return slo.Report{}, err
+	}
+
+	// resolve the selector into target IDs, a deleted target simply contributes no events
+	var targetIDs []string
+	if objective.TargetID != "" {
+		targetIDs = []string{objective.TargetID}
+	} else {
+		targets, err := targetStore.ListTargets(ctx)
+		if err != nil {
+			return slo.Report{}, err
+		}
+		for _, target := range targets {
+			if labels.SelectsTarget(objective.TargetID, objective.Tag, objective.Labels, target) {
+				targetIDs = append(targetIDs, target.ID)
+			}
+		}
+	}
+
+	var results []model.Result
+	for _, targetID := range targetIDs {
+		targetResults, err := targetStore.ResultsBetween(ctx, targetID, now.Add(-window), now)
+		if err != nil {
+			return slo.Report{}, err
+		}
+		results = append(results, targetResults...)
+	}
+
+	return slo.Evaluate(objective, targetIDs, results, now)
+}
+
+// maxNoteLength caps incident notes, they are meant for short status updates
+const maxNoteLength = 4096
+
+// incidentsHandler lists incidents across all targets, newest first
+// GET /incidents?status=open|resolved
+func incidentsHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	incidents, err := targetStore.ListIncidents(request.Context())
+	if err != nil {
+		writeStoreError(responseWriter, "incident", err)
+		return
+	}
+	writeIncidents(responseWriter, request, incidents)
+}
+
+// targetIncidentsHandler lists the incidents of a single target, newest first
+// GET /targets/{id}/incidents?status=open|resolved
+// incidents outlive their target, so this doesn't check that the target still exists
+func targetIncidentsHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := request.PathValue("id")
+	if id == "" {
+		http.Error(responseWriter, "target ID required", http.StatusBadRequest)
+		return
+	}
+
+	incidents, err := targetStore.IncidentsForTarget(request.Context(), id)
+	if err != nil {
+		writeStoreError(responseWriter, "incident", err)
+		return
+	}
+	writeIncidents(responseWriter, request, incidents)
+}
+
+// writeIncidents applies the optional status filter and writes the incidents with their durations
+func writeIncidents(responseWriter http.ResponseWriter, request *http.Request, incidents []model.Incident) {
+	status := request.URL.Query().Get("status")
+	if status != "" && status != model.IncidentOpen && status != model.IncidentResolved {
+		http.Error(responseWriter, "status must be open or resolved", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	filtered := make([]model.Incident, 0, len(incidents))
+	for _, incident := range incidents {
+		if status == "" || incident.Status == status {
+			filtered = append(filtered, withDuration(incident, now))
+		}
+	}
+	writeJSON(responseWriter, http.StatusOK, filtered)
+}
+
+// withDuration fills in how long the incident lasted, or has lasted so far if it is still open
+func withDuration(incident model.Incident, now time.Time) model.Incident {
+	endTime := incident.EndTime
+	if endTime == 0 {
+		endTime = now.Unix()
+	}
+	incident.DurationSeconds = max(endTime-incident.StartTime, 0)
+	return incident
+}
+
+// incidentHandler returns a single incident
+// GET /incidents/{id}
+func incidentHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := request.PathValue("id")
+	if id == "" {
+		http.Error(responseWriter, "incident ID required", http.StatusBadRequest)
+		return
+	}
+
+	incident, err := targetStore.GetIncident(request.Context(), id)
+	if err != nil {
+		writeStoreError(responseWriter, "incident", err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, withDuration(incident, time.Now()))
+}
+
+// incidentAckHandler acknowledges an incident, so others know someone is on it
+// POST /incidents/{id}/ack with an optional body {"by": "alice"}
+func incidentAckHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := request.PathValue("id")
+	if id == "" {
+		http.Error(responseWriter, "incident ID required", http.StatusBadRequest)
+		return
+	}
+
+	var acknowledgement struct {
+		By string `json:"by"`
+	}
+	// the body is optional, an empty one acknowledges anonymously
+	if err := json.NewDecoder(request.Body).Decode(&acknowledgement); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	incident, err := targetStore.AcknowledgeIncident(request.Context(), id, acknowledgement.By, time.Now().Unix())
+	if err != nil {
+		writeStoreError(responseWriter, "incident", err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusOK, withDuration(incident, time.Now()))
+}
+
+// incidentNotesHandler annotates an incident
+// POST /incidents/{id}/notes with {"author": "alice", "text": "rolled back the deploy"}
+func incidentNotesHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := request.PathValue("id")
+	if id == "" {
+		http.Error(responseWriter, "incident ID required", http.StatusBadRequest)
+		return
+	}
+
+	var note model.IncidentNote
+	if err := json.NewDecoder(request.Body).Decode(&note); err != nil {
+		http.Error(responseWriter, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(note.Text) == "" || len(note.Text) > maxNoteLength {
+		http.Error(responseWriter, fmt.Sprintf("text is required and at most %d bytes", maxNoteLength), http.StatusBadRequest)
+		return
+	}
+	note.Timestamp = time.Now().Unix()
+
+	incident, err := targetStore.AddIncidentNote(request.Context(), id, note)
+	if err != nil {
+		writeStoreError(responseWriter, "incident", err)
+		return
+	}
+	writeJSON(responseWriter, http.StatusCreated, withDuration(incident, time.Now()))
+}
+
+// writeJSON writes a json response with the given status code
+func writeJSON(responseWriter http.ResponseWriter, statusCode int, value any) {
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(statusCode)
+	if err := json.NewEncoder(responseWriter).Encode(value); err != nil {
+		log.Println("error encoding response:", err)
+	}
+}
+
+// writeStoreError maps a store error to an http error response
+// not found becomes a 404, anything else is logged and hidden behind a 500
+func writeStoreError(responseWriter http.ResponseWriter, kind string, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(responseWriter, kind+" not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrTableNotConfigured) {
+		http.Error(responseWriter, kind+" storage is not configured", http.StatusNotImplemented)
+		return
+	}
+	log.Printf("store error for %s: %v", kind, err)
+	http.Error(responseWriter, "internal error", http.StatusInternalServerError)
+}
+
+// resultsHandler returns the most recent probe result for each target
+// this is used for dashboards where you want an at-a-glance view
+func resultsHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+
+	// same selector as GET /targets, matched against the labels of each result's target
+	selector, err := labels.ParseSelector(request.URL.Query().Get("labels"))
+	if err != nil {
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := targetStore.LatestResults(request.Context())
+	if err != nil {
+		http.Error(responseWriter, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	results = slices.DeleteFunc(results, func(result model.Result) bool {
+		return !selector.Matches(result.Labels)
+	})
+
+	if results == nil {
+		// always return [] instead of null for better json ergonomics
+		results = []model.Result{}
 	}
 
 	responseWriter.WriteHeader(http.StatusOK)
@@ -151,3 +1300,187 @@ func resultsForTargetHandler(responseWriter http.ResponseWriter, request *http.R
 		log.Println("error encoding results:", err)
 	}
 }
+
+// resultsForTargetHandler returns the probe history for a given target, one page at a time
+// useful for charts, graphs, or debugging uptime issues
+//
+// from/to limit the time range (unix seconds or RFC 3339), limit sets the page size and order=asc returns
+// the oldest results first; when there are more results the X-Next-Cursor header carries the cursor for the next page
+// results are newest first by default, so a client that doesn't page still sees the most recent probes
+func resultsForTargetHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// extract target id from URL path
+	id := request.PathValue("id")
+	if id == "" {
+		http.Error(responseWriter, "target ID required", http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseResultQuery(request.URL.Query())
+	if err != nil {
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// fetch one page of results for this specific target
+	page, err := targetStore.QueryResults(request.Context(), id, query)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		http.Error(responseWriter, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeStoreError(responseWriter, "results", err)
+		return
+	}
+
+	results := page.Results
+	if results == nil {
+		results = []model.Result{}
+	}
+
+	// the body stays a plain list, the cursor travels in a header
+	if page.NextCursor != "" {
+		responseWriter.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	writeJSON(responseWriter, http.StatusOK, results)
+}
+
+// how far back a series reaches when from is not given, and the most buckets a single request may span
+const (
+	defaultSeriesBuckets = 720
+	maxSeriesBuckets     = 10000
+)
+
+// seriesResponse is the body of GET /results/{id}/series
+// buckets without any probe are left out
+type seriesResponse struct {
+	TargetID string         `json:"targetId"`
+	Step     string         `json:"step"`
+	From     int64          `json:"from"`
+	To       int64          `json:"to"`
+	Points   []model.Rollup `json:"points"`
+}
+
+// resultsSeriesHandler serves the rolled-up history of a target for long-range charts
+// step is 1m, 1h or 1d (default 1h), from/to work like on GET /results/{id} and default to the last 720 steps
+func resultsSeriesHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := request.PathValue("id")
+	if id == "" {
+		http.Error(responseWriter, "target ID required", http.StatusBadRequest)
+		return
+	}
+
+	parameters := request.URL.Query()
+	stepParameter := parameters.Get("step")
+	if stepParameter == "" {
+		stepParameter = "1h"
+	}
+	step, err := rollup.ParseStep(stepParameter)
+	if err != nil {
+		http.Error(responseWriter, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseTimeParameter(parameters.Get("from"))
+	if err != nil {
+		http.Error(responseWriter, "invalid from parameter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParameter(parameters.Get("to"))
+	if err != nil {
+		http.Error(responseWriter, "invalid to parameter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultSeriesBuckets * step)
+	}
+	if to.Before(from) {
+		http.Error(responseWriter, "invalid time range: to must not be before from", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxSeriesBuckets*step {
+		http.Error(responseWriter, fmt.Sprintf("time range too long: at most %d buckets, use a larger step", maxSeriesBuckets), http.StatusBadRequest)
+		return
+	}
+
+	// from falls inside a bucket that started earlier, include it
+	points, err := targetStore.Rollups(request.Context(), id, step, time.Unix(rollup.BucketStart(from.Unix(), step), 0), to)
+	if err != nil {
+		writeStoreError(responseWriter, "results", err)
+		return
+	}
+	if points == nil {
+		points = []model.Rollup{}
+	}
+	for index := range points {
+		rollup.Summarize(&points[index])
+	}
+
+	writeJSON(responseWriter, http.StatusOK, seriesResponse{
+		TargetID: id,
+		Step:     stepParameter,
+		From:     from.Unix(),
+		To:       to.Unix(),
+		Points:   points,
+	})
+}
+
+// parseResultQuery reads the paging parameters of GET /results/{id}
+func parseResultQuery(parameters url.Values) (store.ResultQuery, error) {
+	query := store.ResultQuery{Cursor: parameters.Get("cursor")}
+
+	var err error
+	if query.From, err = parseTimeParameter(parameters.Get("from")); err != nil {
+		return query, fmt.Errorf("invalid from parameter: %w", err)
+	}
+	if query.To, err = parseTimeParameter(parameters.Get("to")); err != nil {
+		return query, fmt.Errorf("invalid to parameter: %w", err)
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && query.To.Before(query.From) {
+		return query, errors.New("invalid time range: to must not be before from")
+	}
+
+	if limit := parameters.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > store.MaxResultLimit {
+			return query, fmt.Errorf("invalid limit parameter: must be between 1 and %d", store.MaxResultLimit)
+		}
+	}
+
+	switch parameters.Get("order") {
+	case "", "desc":
+		query.Descending = true
+	case "asc":
+	default:
+		return query, errors.New("invalid order parameter: must be asc or desc")
+	}
+
+	return query, nil
+}
+
+// parseTimeParameter accepts unix seconds or an RFC 3339 timestamp, empty is the zero time
+func parseTimeParameter(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("must be unix seconds or RFC 3339")
+	}
+	return parsed, nil
+}
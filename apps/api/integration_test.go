@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/sspier/cloudpulse/internal/model"
 	"github.com/sspier/cloudpulse/internal/probe"
 	"github.com/sspier/cloudpulse/internal/store"
 )
@@ -83,7 +84,7 @@ func TestIntegrationWithLocalDynamoDB(t *testing.T) {
 	// add a target
 	t.Log("Adding target...")
 	targetURL := "https://example.com"
-	target, err := dynamoDBStore.AddTarget(ctx, "Integration Test Target", targetURL)
+	target, err := dynamoDBStore.AddTarget(ctx, model.Target{Name: "Integration Test Target", URL: targetURL})
 	if err != nil {
 		t.Fatalf("Failed to add target: %v", err)
 	}
@@ -7,13 +7,34 @@ import (
 	"os"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sspier/cloudpulse/internal/alert"
+	"github.com/sspier/cloudpulse/internal/metrics"
+	"github.com/sspier/cloudpulse/internal/retention"
 	"github.com/sspier/cloudpulse/internal/store"
+	"github.com/sspier/cloudpulse/internal/tracing"
 )
 
 // read data (serve API) and write data (schedule)
 func main() {
+	// traces go to the OTLP endpoint from OTEL_EXPORTER_OTLP_ENDPOINT, without it spans are dropped
+	flushTraces, err := tracing.Setup(context.Background(), "cloudpulse-api")
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+	defer flushTraces(context.Background())
+
+	// how long results are kept, targets can override it with retentionDays and maxResults
+	policy, err := retention.FromEnv()
+	if err != nil {
+		log.Fatalf("invalid retention configuration: %v", err)
+	}
+	resultRetention = policy
+
 	resultsTable := os.Getenv("TABLE_NAME_RESULTS")
 	targetsTable := os.Getenv("TABLE_NAME_TARGETS")
+	// the in-memory store always keeps incidents, DynamoDB only with an incidents table
+	recordIncidents := true
 
 	// CLOUD MODE: if the results table and the target table are set, we assume cloud mode and use DynamoDB
 	if resultsTable != "" && targetsTable != "" {
@@ -24,75 +45,123 @@ func main() {
 		}
 
 		// initialize the store with DynamoDB using the region, targets table, and results table as well as the top level context
-		db, err := store.NewDynamoDBStore(context.Background(), awsRegion, targetsTable, resultsTable)
+		// optional tables, features backed by a missing table are disabled
+		// DynamoDB deletes old results itself, through the ttl the store sets from the policy
+		storeOptions := []store.DynamoDBOption{store.WithRetention(resultRetention)}
+		if maintenanceTable := os.Getenv("TABLE_NAME_MAINTENANCE"); maintenanceTable != "" {
+			storeOptions = append(storeOptions, store.WithMaintenanceTable(maintenanceTable))
+		}
+		if slosTable := os.Getenv("TABLE_NAME_SLOS"); slosTable != "" {
+			storeOptions = append(storeOptions, store.WithSLOsTable(slosTable))
+		}
+		incidentsTable := os.Getenv("TABLE_NAME_INCIDENTS")
+		if incidentsTable != "" {
+			storeOptions = append(storeOptions, store.WithIncidentsTable(incidentsTable))
+		}
+		recordIncidents = incidentsTable != ""
+
+		db, err := store.NewDynamoDBStore(context.Background(), awsRegion, targetsTable, resultsTable, storeOptions...)
 		if err != nil {
 			log.Fatalf("failed to init dynamodb store: %v", err)
 		}
 		targetStore = db
-		// LOCAL MODE: if the results table and the target table are not set, we assume local mode and use in-memory store
+		// POSTGRES MODE: outside AWS, DATABASE_URL points at a PostgreSQL database
+		// LOCAL MODE: otherwise we assume local mode and use in-memory store
+		// or the file store when LOCAL_STORE_PATH is set
+	} else if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		// the schema is migrated on startup, expired results are deleted in the background
+		log.Println("initializing postgres store")
+		postgresStore, err := store.NewPostgresStore(context.Background(), databaseURL)
+		if err != nil {
+			log.Fatalf("failed to init postgres store: %v", err)
+		}
+		defer postgresStore.Close()
+		targetStore = postgresStore
+	} else if storePath := os.Getenv("LOCAL_STORE_PATH"); storePath != "" {
+		// LOCAL MODE with a file: targets and history survive restarts
+		log.Printf("initializing local store at %s", storePath)
+		boltStore, err := store.NewBoltStore(storePath)
+		if err != nil {
+			log.Fatalf("failed to open local store: %v", err)
+		}
+		defer boltStore.Close()
+		targetStore = boltStore
 	} else {
 		log.Println("initializing in-memory store") // targetStore is already init to NewInMemoryStore by default in handlers.go
 	}
 
+	// notifiers for targets going down and recovering, used by the local scheduler
+	// incidents are recorded through the same transitions
+	alerter, err := alert.FromEnv()
+	if err != nil {
+		log.Fatalf("invalid alerting configuration: %v", err)
+	}
+	if recordIncidents {
+		alerter.Notifiers = append(alerter.Notifiers, &alert.IncidentRecorder{Store: targetStore})
+	}
+	targetAlerter = alerter
+
 	// create the http router that wires paths to handler functions
 	httpRouter := http.NewServeMux()
 
 	// register API endpoints for health checks, targets, and results
 	httpRouter.HandleFunc("/health", healthHandler)
+	// prometheus scrape endpoint: per-target probe metrics, api request metrics and the go runtime
+	httpRouter.Handle("/metrics", promhttp.Handler())
 	httpRouter.HandleFunc("/targets", targetsHandler)
+	// bulk create and update targets by name, and write them all out in the same format
+	httpRouter.HandleFunc("/targets:import", targetsImportHandler)
+	httpRouter.HandleFunc("/targets:export", targetsExportHandler)
+	// read, replace, patch or delete a single target
+	httpRouter.HandleFunc("/targets/{id}", targetHandler)
+	// stop and restart probing a target without losing it
+	httpRouter.HandleFunc("/targets/{id}/pause", targetPauseHandler(true))
+	httpRouter.HandleFunc("/targets/{id}/resume", targetPauseHandler(false))
+	// availability, downtime and latency percentiles over a window
+	httpRouter.HandleFunc("/targets/{id}/uptime", uptimeHandler)
 	httpRouter.HandleFunc("/results", resultsHandler)
 	// returns full probe history for a specific target
 	httpRouter.HandleFunc("/results/{id}", resultsForTargetHandler)
+	// minute, hour or day aggregates for long-range charts
+	httpRouter.HandleFunc("/results/{id}/series", resultsSeriesHandler)
+	// maintenance windows suppress downtime for a target or tag
+	httpRouter.HandleFunc("/maintenance", maintenanceHandler)
+	httpRouter.HandleFunc("/maintenance/{id}", maintenanceWindowHandler)
+	// service level objectives with error budget and burn rates
+	httpRouter.HandleFunc("/slos", slosHandler)
+	httpRouter.HandleFunc("/slos/{id}", sloHandler)
+	// outages opened on the first confirmed failure and resolved on recovery
+	httpRouter.HandleFunc("/incidents", incidentsHandler)
+	httpRouter.HandleFunc("/incidents/{id}", incidentHandler)
+	httpRouter.HandleFunc("/incidents/{id}/ack", incidentAckHandler)
+	httpRouter.HandleFunc("/incidents/{id}/notes", incidentNotesHandler)
+	httpRouter.HandleFunc("/targets/{id}/incidents", targetIncidentsHandler)
 
 	// background scheduler for recurring uptime checks
 	// we only run this if explicit configuration says so, OR if we are in local mode.
 	// in cloud mode, the runner service handles this
 	// for simplicity:
-	// - if using in-memory, run scheduler
+	// - if using in-memory, the file store or postgres, run scheduler
 	// - if using DynamoDB, assume runner handles it
 	if resultsTable == "" {
 		go func() {
 			// NewTicker creates a channel that sends a timestamp every X interval
-			// here: every 30 seconds, we wake up and run probes
+			// here: every second we wake up and probe the targets whose own interval has elapsed
 			//
 			// a timeTicker does NOT block; it simply emits events on timeTicker.C
-			timeTicker := time.NewTicker(30 * time.Second)
+			timeTicker := time.NewTicker(schedulerTick)
 			defer timeTicker.Stop()
 
 			// this loop runs forever while the process is alive
 			// each iteration executes when timeTicker.C receives a "tick"
-			for range timeTicker.C {
+			for now := range timeTicker.C {
 
 				// use a fresh context per cycle
 				// context.Context or 'ctx' in Go is used for control flow, specifically around:
 				// - timeouts/deadlines: "stop this operation if it takes longer than 5 seconds"
 				// - cancellation: "the user hit Ctrl+C or closed their browser, so stop processing this request immediately to save resources"
 				// - request scoped values: carrying trace IDs or authentication data throughout the request chain
-				ctx := context.Background()
-
-				// pull the latest set of targets from the store
-				targets, err := targetStore.ListTargets(ctx)
-				if err != nil {
-					log.Printf("scheduler: failed to list targets: %v\n", err)
-					continue
-				}
-
-				// if no targets exist yet, nothing to do this tick
-				if len(targets) == 0 {
-					continue
-				}
-
-				log.Printf("scheduler: running checks for %d targets\n", len(targets))
-
-				// kick off one probe per target
-				// each check runs asynchronously in its own goroutine
-				// so targets don't block each other
-				//
-				// the scheduler loop does not wait for check completion
-				// in the DynamoDB-backed version, results will be written to the table
-				for _, target := range targets {
-					go runCheck(target)
-				}
+				probeScheduler.tick(context.Background(), now)
 			}
 		}()
 	} else {
@@ -102,12 +171,24 @@ func main() {
 		log.Println("scheduler disabled (assuming external runner)")
 	}
 
+	// every other store gets compacted in the background, so memory and disk don't grow without bound
+	if _, ok := targetStore.(store.Compactor); ok {
+		go func() {
+			timeTicker := time.NewTicker(compactInterval)
+			defer timeTicker.Stop()
+
+			for now := range timeTicker.C {
+				compact(context.Background(), now)
+			}
+		}()
+	}
+
 	// configure the http httpServer with sensible timeouts
 	// read header timeout helps protect against slowloris-style clients
 	// idle timeout prevents connections from lingering
 	httpServer := &http.Server{
 		Addr:              ":8080",
-		Handler:           httpRouter,
+		Handler:           tracing.InstrumentHandler(metrics.InstrumentHandler(httpRouter)),
 		ReadHeaderTimeout: 5 * time.Second,
 		IdleTimeout:       60 * time.Second,
 	}
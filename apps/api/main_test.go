@@ -4,13 +4,41 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/snappy"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sspier/cloudpulse/internal/alert"
+	"github.com/sspier/cloudpulse/internal/maintenance"
+	"github.com/sspier/cloudpulse/internal/metrics"
 	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/probe"
+	"github.com/sspier/cloudpulse/internal/retention"
+	"github.com/sspier/cloudpulse/internal/slo"
+	"github.com/sspier/cloudpulse/internal/store"
+	"github.com/sspier/cloudpulse/internal/store/storetest"
+	"github.com/sspier/cloudpulse/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // testHealthEndpoint checks that /health responds with 200
@@ -109,8 +137,8 @@ func TestTargetsGET(t *testing.T) {
 
 	// reset store and seed a couple of targets
 	targetStore = NewInMemoryStore()
-	targetStore.AddTarget(context.Background(), "Example A", "https://a.example.com")
-	targetStore.AddTarget(context.Background(), "Example B", "https://b.example.com")
+	targetStore.AddTarget(context.Background(), model.Target{Name: "Example A", URL: "https://a.example.com"})
+	targetStore.AddTarget(context.Background(), model.Target{Name: "Example B", URL: "https://b.example.com"})
 
 	router := http.NewServeMux()
 	router.HandleFunc("/targets", targetsHandler)
@@ -139,7 +167,7 @@ func TestResultsLatest(t *testing.T) {
 
 	// reset store and seed a target + multiple results
 	targetStore = NewInMemoryStore()
-	target, _ := targetStore.AddTarget(context.Background(), "Example", "https://example.com")
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Example", URL: "https://example.com"})
 
 	// older result
 	targetStore.AddResult(context.Background(), model.Result{
@@ -181,12 +209,12 @@ func TestResultsLatest(t *testing.T) {
 	}
 }
 
-// TestResultsHistory verifies GET /results/{id} returns full history for a target
+// TestResultsHistory verifies GET /results/{id} returns the history of a target, newest first
 func TestResultsHistory(t *testing.T) {
 
 	// reset store and seed a target + multiple results
 	targetStore = NewInMemoryStore()
-	target, _ := targetStore.AddTarget(context.Background(), "Example", "https://example.com")
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Example", URL: "https://example.com"})
 
 	targetStore.AddResult(context.Background(), model.Result{
 		TargetID:   target.ID,
@@ -225,11 +253,2055 @@ func TestResultsHistory(t *testing.T) {
 		t.Fatalf("expected 2 results in history, got %d", len(results))
 	}
 
-	// first result should be the older one; second should be the newer one
-	if results[0].Status != "down" || results[0].HTTPStatus != 500 {
-		t.Fatalf("expected first result down/500, got status=%q httpStatus=%d", results[0].Status, results[0].HTTPStatus)
+	// first result should be the newer one; second should be the older one
+	if results[0].Status != "up" || results[0].HTTPStatus != 200 {
+		t.Fatalf("expected first result up/200, got status=%q httpStatus=%d", results[0].Status, results[0].HTTPStatus)
+	}
+	if results[1].Status != "down" || results[1].HTTPStatus != 500 {
+		t.Fatalf("expected second result down/500, got status=%q httpStatus=%d", results[1].Status, results[1].HTTPStatus)
+	}
+}
+
+// TestResultsIncludeLatency probes a slow local server and verifies the latency is recorded and returned
+func TestResultsIncludeLatency(t *testing.T) {
+
+	// a local server that takes a little while before responding
+	slowServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, _ *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		responseWriter.WriteHeader(http.StatusOK)
+	}))
+	defer slowServer.Close()
+
+	// reset store and register the slow server as a target
+	targetStore = NewInMemoryStore()
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Slow", URL: slowServer.URL})
+
+	// run the check synchronously so the result is stored before we query it
+	runCheck(targetStore, targetAlerter, target)
+
+	router := http.NewServeMux()
+	router.HandleFunc("/results/{id}", resultsForTargetHandler)
+
+	request := httptest.NewRequest(http.MethodGet, "/results/"+target.ID, nil)
+	request.SetPathValue("id", target.ID)
+
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	var results []model.Result
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if results[0].Status != "up" {
+		t.Fatalf("expected status up, got %q", results[0].Status)
+	}
+	if results[0].LatencyMs < 50 {
+		t.Fatalf("expected latency of at least 50ms, got %dms", results[0].LatencyMs)
+	}
+	if results[0].FirstByteMs < 50 {
+		t.Fatalf("expected time to first byte of at least 50ms, got %dms", results[0].FirstByteMs)
+	}
+}
+
+// TestTCPTarget registers a tcp target via POST /targets and verifies the connect probe reports it up
+func TestTCPTarget(t *testing.T) {
+
+	// a local listener stands in for a database or smtp server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	targetStore = NewInMemoryStore()
+
+	router := http.NewServeMux()
+	router.HandleFunc("/targets", targetsHandler)
+
+	// an address without a port is rejected
+	body := `{"name": "Database", "type": "tcp", "url": "127.0.0.1"}`
+	request := httptest.NewRequest(http.MethodPost, "/targets", bytes.NewBufferString(body))
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 Bad Request for missing port, got %d", responseRecorder.Code)
+	}
+
+	body = `{"name": "Database", "type": "tcp", "url": "` + listener.Addr().String() + `"}`
+	request = httptest.NewRequest(http.MethodPost, "/targets", bytes.NewBufferString(body))
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected HTTP 201 Created, got %d", responseRecorder.Code)
+	}
+
+	var target model.Target
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&target); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if target.Type != model.TargetTypeTCP {
+		t.Fatalf("expected target type tcp, got %q", target.Type)
+	}
+
+	// the connect probe should succeed against the listener
+	result := probe.Check(context.Background(), target)
+	if result.Status != "up" {
+		t.Fatalf("expected tcp target to be up, got %q", result.Status)
+	}
+
+	// once the listener is gone the probe should report down
+	listener.Close()
+	result = probe.Check(context.Background(), target)
+	if result.Status != "down" {
+		t.Fatalf("expected closed tcp target to be down, got %q", result.Status)
+	}
+}
+
+// TestTargetRequestSettings verifies a target's method, headers and body are validated and sent by the probe
+func TestTargetRequestSettings(t *testing.T) {
+
+	// capture what the probe sends
+	var receivedMethod, receivedAuthorization, receivedHost, receivedBody string
+	echoServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		receivedMethod = request.Method
+		receivedAuthorization = request.Header.Get("Authorization")
+		receivedHost = request.Host
+		requestBody, _ := io.ReadAll(request.Body)
+		receivedBody = string(requestBody)
+		responseWriter.WriteHeader(http.StatusOK)
+	}))
+	defer echoServer.Close()
+
+	targetStore = NewInMemoryStore()
+
+	router := http.NewServeMux()
+	router.HandleFunc("/targets", targetsHandler)
+
+	// unknown methods are rejected
+	body := `{"name": "Health", "url": "` + echoServer.URL + `", "method": "FETCH"}`
+	request := httptest.NewRequest(http.MethodPost, "/targets", bytes.NewBufferString(body))
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 Bad Request for invalid method, got %d", responseRecorder.Code)
+	}
+
+	body = `{
+		"name": "Health",
+		"url": "` + echoServer.URL + `",
+		"method": "post",
+		"headers": {"Authorization": "Bearer secret", "Host": "api.internal"},
+		"body": "{\"ping\": true}"
+	}`
+	request = httptest.NewRequest(http.MethodPost, "/targets", bytes.NewBufferString(body))
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected HTTP 201 Created, got %d", responseRecorder.Code)
+	}
+
+	var target model.Target
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&target); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if target.Method != http.MethodPost {
+		t.Fatalf("expected method to be normalized to POST, got %q", target.Method)
+	}
+
+	// run the probe against the stored target
+	storedTarget, _ := targetStore.ListTargets(context.Background())
+	result := probe.Check(context.Background(), storedTarget[0])
+	if result.Status != "up" {
+		t.Fatalf("expected target to be up, got %q", result.Status)
+	}
+
+	if receivedMethod != http.MethodPost {
+		t.Fatalf("expected probe to send POST, got %q", receivedMethod)
+	}
+	if receivedAuthorization != "Bearer secret" {
+		t.Fatalf("expected Authorization header to be sent, got %q", receivedAuthorization)
+	}
+	if receivedHost != "api.internal" {
+		t.Fatalf("expected Host override api.internal, got %q", receivedHost)
+	}
+	if receivedBody != `{"ping": true}` {
+		t.Fatalf("expected request body to be sent, got %q", receivedBody)
+	}
+}
+
+// TestTargetAssertions verifies that a 200 response failing an assertion is reported down with the failure recorded
+func TestTargetAssertions(t *testing.T) {
+
+	// a server that answers 200 but with an error payload
+	errorPageServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, _ *http.Request) {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+		fmt.Fprint(responseWriter, `{"status": "error", "checks": [{"name": "db", "ok": false}]}`)
+	}))
+	defer errorPageServer.Close()
+
+	targetStore = NewInMemoryStore()
+
+	router := http.NewServeMux()
+	router.HandleFunc("/targets", targetsHandler)
+
+	// a regex that does not compile is rejected
+	body := `{"name": "Status", "url": "` + errorPageServer.URL + `", "assertions": {"bodyRegex": "("}}`
+	request := httptest.NewRequest(http.MethodPost, "/targets", bytes.NewBufferString(body))
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 Bad Request for invalid regex, got %d", responseRecorder.Code)
+	}
+
+	testCases := []struct {
+		name            string
+		assertions      model.Assertions
+		expectedStatus  string
+		expectedFailure string
+	}{
+		{
+			name:           "default rule accepts 200",
+			assertions:     model.Assertions{},
+			expectedStatus: "up",
+		},
+		{
+			name:            "status code not accepted",
+			assertions:      model.Assertions{StatusCodes: []int{204}},
+			expectedStatus:  "down",
+			expectedFailure: "status code 200 not in [204]",
+		},
+		{
+			name:            "body contains error",
+			assertions:      model.Assertions{BodyNotContains: `"error"`},
+			expectedStatus:  "down",
+			expectedFailure: `body contains "\"error\""`,
+		},
+		{
+			name:            "json path mismatch",
+			assertions:      model.Assertions{JSONPath: []model.JSONPathAssertion{{Path: "$.checks[0].ok", Equals: "true"}}},
+			expectedStatus:  "down",
+			expectedFailure: `json path $.checks[0].ok is "false", want "true"`,
+		},
+		{
+			name: "json path and header match",
+			assertions: model.Assertions{
+				JSONPath: []model.JSONPathAssertion{{Path: "checks[0].name", Equals: "db"}},
+				Headers:  map[string]string{"Content-Type": "application/json"},
+			},
+			expectedStatus: "up",
+		},
+		{
+			name:            "required header missing",
+			assertions:      model.Assertions{Headers: map[string]string{"X-Request-Id": ""}},
+			expectedStatus:  "down",
+			expectedFailure: `header "X-Request-Id" missing`,
+		},
+		{
+			name:            "first failing header by name",
+			assertions:      model.Assertions{Headers: map[string]string{"X-Trace-Id": "", "Content-Type": "text/html", "X-Request-Id": "", "X-Build": ""}},
+			expectedStatus:  "down",
+			expectedFailure: `header "Content-Type" is "application/json", want "text/html"`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assertions := testCase.assertions
+			target := model.Target{ID: "assert", URL: errorPageServer.URL, Assertions: &assertions}
+
+			result := probe.Check(context.Background(), target)
+			if result.Status != testCase.expectedStatus {
+				t.Fatalf("expected status %q, got %q (failed assertion %q)", testCase.expectedStatus, result.Status, result.FailedAssertion)
+			}
+			if result.FailedAssertion != testCase.expectedFailure {
+				t.Fatalf("expected failed assertion %q, got %q", testCase.expectedFailure, result.FailedAssertion)
+			}
+		})
+	}
+}
+
+// TestSchedulerPerTargetInterval verifies each target is scheduled on its own interval
+func TestSchedulerPerTargetInterval(t *testing.T) {
+
+	critical := model.Target{ID: "critical", IntervalSeconds: 10}
+	dashboard := model.Target{ID: "dashboard", IntervalSeconds: 300}
+	targets := []model.Target{critical, dashboard}
+
+	testScheduler := newScheduler()
+	startTime := time.Unix(1700000000, 0)
+
+	// never probed, so both are due
+	if due := testScheduler.dueTargets(targets, startTime); len(due) != 2 {
+		t.Fatalf("expected 2 due targets on first tick, got %d", len(due))
+	}
+
+	// one second later nothing is due
+	if due := testScheduler.dueTargets(targets, startTime.Add(time.Second)); len(due) != 0 {
+		t.Fatalf("expected no due targets after 1s, got %d", len(due))
+	}
+
+	// after 10 seconds only the critical target is due
+	due := testScheduler.dueTargets(targets, startTime.Add(10*time.Second))
+	if len(due) != 1 || due[0].ID != "critical" {
+		t.Fatalf("expected only the critical target after 10s, got %v", due)
+	}
+
+	// after 5 minutes both are due again
+	if due := testScheduler.dueTargets(targets, startTime.Add(300*time.Second)); len(due) != 2 {
+		t.Fatalf("expected 2 due targets after 5m, got %d", len(due))
+	}
+}
+
+// TestTargetTimingPolicy verifies the timing policy is validated and retries recover a flaky target
+func TestTargetTimingPolicy(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+
+	router := http.NewServeMux()
+	router.HandleFunc("/targets", targetsHandler)
+
+	// intervals below the minimum are rejected
+	body := `{"name": "Too fast", "url": "https://example.com", "intervalSeconds": 1}`
+	request := httptest.NewRequest(http.MethodPost, "/targets", bytes.NewBufferString(body))
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 Bad Request for 1s interval, got %d", responseRecorder.Code)
+	}
+
+	// timeouts that are not shorter than the interval are rejected
+	body = `{"name": "Slow", "url": "https://example.com", "intervalSeconds": 10, "timeoutSeconds": 10}`
+	request = httptest.NewRequest(http.MethodPost, "/targets", bytes.NewBufferString(body))
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 Bad Request for timeout >= interval, got %d", responseRecorder.Code)
+	}
+
+	// a probe that could outlast the runner's budget is rejected, 4 attempts of 15s plus 3s of retry delay
+	body = `{"name": "Patient", "url": "https://example.com", "intervalSeconds": 300, "timeoutSeconds": 15, "retries": 3}`
+	request = httptest.NewRequest(http.MethodPost, "/targets", bytes.NewBufferString(body))
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 Bad Request for a probe longer than 60s, got %d", responseRecorder.Code)
+	}
+	if maxDuration := probe.MaxDuration(model.Target{TimeoutSeconds: 14, Retries: 3}); maxDuration != 59*time.Second {
+		t.Fatalf("expected 4 attempts of 14s and 3 pauses to take at most 59s, got %v", maxDuration)
+	}
+
+	// a server that fails the first request and recovers afterwards
+	var requestCount atomic.Int32
+	flakyServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, _ *http.Request) {
+		if requestCount.Add(1) == 1 {
+			responseWriter.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		responseWriter.WriteHeader(http.StatusOK)
+	}))
+	defer flakyServer.Close()
+
+	result := probe.Check(context.Background(), model.Target{ID: "flaky", URL: flakyServer.URL, Retries: 1})
+	if result.Status != "up" {
+		t.Fatalf("expected retry to recover the target, got %q", result.Status)
+	}
+	if result.Attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", result.Attempts)
+	}
+}
+
+// TestCertificateExpiry verifies https results carry certificate details and are degraded close to expiry
+func TestCertificateExpiry(t *testing.T) {
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, _ *http.Request) {
+		responseWriter.WriteHeader(http.StatusOK)
+	}))
+	defer tlsServer.Close()
+
+	// the probe clones the default transport, so point it at one that trusts the test certificate
+	originalTransport := http.DefaultTransport
+	http.DefaultTransport = tlsServer.Client().Transport
+	defer func() { http.DefaultTransport = originalTransport }()
+
+	result := probe.Check(context.Background(), model.Target{ID: "tls", URL: tlsServer.URL})
+	if result.Status != model.StatusUp {
+		t.Fatalf("expected status up, got %q (error %q)", result.Status, result.Error)
+	}
+	if result.TLS == nil {
+		t.Fatalf("expected tls details in result")
+	}
+	if result.TLS.Version == "" || result.TLS.Issuer == "" || len(result.TLS.SANs) == 0 {
+		t.Fatalf("expected version, issuer and sans to be set, got %+v", result.TLS)
+	}
+	if result.TLS.DaysToExpiry <= model.DefaultCertExpiryWarningDays {
+		t.Fatalf("expected test certificate to be far from expiry, got %d days", result.TLS.DaysToExpiry)
+	}
+
+	// a warning window longer than the certificate's remaining lifetime marks the target degraded
+	warningDays := result.TLS.DaysToExpiry + 30
+	result = probe.Check(context.Background(), model.Target{ID: "tls", URL: tlsServer.URL, CertExpiryWarningDays: warningDays})
+	if result.Status != model.StatusDegraded {
+		t.Fatalf("expected status degraded, got %q", result.Status)
+	}
+}
+
+// TestTargetCRUD exercises GET, PUT, PATCH and DELETE on /targets/{id}
+func TestTargetCRUD(t *testing.T) {
+
+	// reset store and seed a target with some history
+	targetStore = NewInMemoryStore()
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{
+		Name:    "Example",
+		URL:     "https://example.com",
+		Type:    model.TargetTypeHTTP,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+	targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusUp, HTTPStatus: 200})
+
+	router := http.NewServeMux()
+	router.HandleFunc("/targets/{id}", targetHandler)
+
+	// sendRequest runs a request against /targets/{id} and returns the recorder
+	sendRequest := func(method, id, query, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "/targets/"+id+query, bytes.NewBufferString(body))
+		request.SetPathValue("id", id)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+
+	// GET an unknown target
+	if responseRecorder := sendRequest(http.MethodGet, "missing", "", ""); responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected HTTP 404 for unknown target, got %d", responseRecorder.Code)
+	}
+
+	// GET the seeded target, header values are redacted
+	responseRecorder := sendRequest(http.MethodGet, target.ID, "", "")
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK, got %d", responseRecorder.Code)
+	}
+	fetchedBody := responseRecorder.Body.String()
+	if strings.Contains(fetchedBody, "Bearer secret") || !strings.Contains(fetchedBody, redactedHeaderValue) {
+		t.Fatalf("expected the header value to be redacted, got %s", fetchedBody)
+	}
+
+	// PUT the target back as fetched, the redacted value keeps the stored one
+	if responseRecorder := sendRequest(http.MethodPut, target.ID, "", fetchedBody); responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK for PUT of the fetched target, got %d", responseRecorder.Code)
+	}
+	if stored, _ := targetStore.GetTarget(context.Background(), target.ID); stored.Headers["Authorization"] != "Bearer secret" {
+		t.Fatalf("expected the stored header value to be kept, got %+v", stored.Headers)
+	}
+
+	// a redacted value for a header that isn't stored can't stand for anything
+	if responseRecorder := sendRequest(http.MethodPut, target.ID, "", `{"name": "Example", "url": "https://example.com", "headers": {"X-Api-Key": "`+redactedHeaderValue+`"}}`); responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 for a redacted value of an unknown header, got %d", responseRecorder.Code)
+	}
+
+	// PATCH only the name, the url and headers must be kept
+	responseRecorder = sendRequest(http.MethodPatch, target.ID, "", `{"name": "Renamed"}`)
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK for PATCH, got %d", responseRecorder.Code)
+	}
+	var patched model.Target
+	json.NewDecoder(responseRecorder.Body).Decode(&patched)
+	stored, _ := targetStore.GetTarget(context.Background(), target.ID)
+	if patched.Name != "Renamed" || patched.URL != "https://example.com" || patched.Headers["Authorization"] != redactedHeaderValue || stored.Headers["Authorization"] != "Bearer secret" {
+		t.Fatalf("expected PATCH to only change the name, got %+v", patched)
+	}
+
+	// PUT with an invalid url is rejected
+	if responseRecorder := sendRequest(http.MethodPut, target.ID, "", `{"name": "Bad", "url": "ftp://example.com"}`); responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 for invalid PUT, got %d", responseRecorder.Code)
+	}
+
+	// PUT replaces the whole definition, the headers are gone afterwards
+	responseRecorder = sendRequest(http.MethodPut, target.ID, "", `{"name": "Replaced", "url": "https://replaced.example.com", "id": "ignored"}`)
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK for PUT, got %d", responseRecorder.Code)
+	}
+	stored, _ = targetStore.GetTarget(context.Background(), target.ID)
+	if stored.Name != "Replaced" || stored.URL != "https://replaced.example.com" || len(stored.Headers) != 0 {
+		t.Fatalf("expected PUT to replace the target, got %+v", stored)
+	}
+
+	// PUT on an unknown target
+	if responseRecorder := sendRequest(http.MethodPut, "missing", "", `{"name": "New", "url": "https://example.com"}`); responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected HTTP 404 for PUT on unknown target, got %d", responseRecorder.Code)
+	}
+
+	// DELETE with purge removes the target and its history
+	if responseRecorder := sendRequest(http.MethodDelete, target.ID, "?purge=true", ""); responseRecorder.Code != http.StatusNoContent {
+		t.Fatalf("expected HTTP 204 for DELETE, got %d", responseRecorder.Code)
+	}
+	if _, err := targetStore.GetTarget(context.Background(), target.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected target to be gone, got %v", err)
+	}
+	if results, _ := targetStore.ResultsForTarget(context.Background(), target.ID); len(results) != 0 {
+		t.Fatalf("expected results to be purged, got %d", len(results))
+	}
+
+	// deleting again is a 404
+	if responseRecorder := sendRequest(http.MethodDelete, target.ID, "", ""); responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected HTTP 404 for second DELETE, got %d", responseRecorder.Code)
+	}
+}
+
+// TestPauseResumeTarget verifies paused targets are skipped by the scheduler and flagged in GET /results
+func TestPauseResumeTarget(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Example", URL: "https://example.com"})
+	targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusDown, HTTPStatus: 500})
+
+	router := http.NewServeMux()
+	router.HandleFunc("/targets/{id}/pause", targetPauseHandler(true))
+	router.HandleFunc("/targets/{id}/resume", targetPauseHandler(false))
+	router.HandleFunc("/results", resultsHandler)
+
+	// pause the target
+	request := httptest.NewRequest(http.MethodPost, "/targets/"+target.ID+"/pause", nil)
+	request.SetPathValue("id", target.ID)
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK for pause, got %d", responseRecorder.Code)
+	}
+
+	// the scheduler must not pick up the paused target
+	targets, _ := targetStore.ListTargets(context.Background())
+	if due := newScheduler().dueTargets(targets, time.Now()); len(due) != 0 {
+		t.Fatalf("expected paused target to be skipped, got %d due", len(due))
+	}
+
+	// the latest result is flagged as paused
+	request = httptest.NewRequest(http.MethodGet, "/results", nil)
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	var results []model.Result
+	json.NewDecoder(responseRecorder.Body).Decode(&results)
+	if len(results) != 1 || !results[0].Paused {
+		t.Fatalf("expected the latest result to be flagged paused, got %+v", results)
+	}
+
+	// resume the target
+	request = httptest.NewRequest(http.MethodPost, "/targets/"+target.ID+"/resume", nil)
+	request.SetPathValue("id", target.ID)
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	var resumed model.Target
+	json.NewDecoder(responseRecorder.Body).Decode(&resumed)
+	if responseRecorder.Code != http.StatusOK || resumed.Paused {
+		t.Fatalf("expected resumed target, got HTTP %d paused=%v", responseRecorder.Code, resumed.Paused)
+	}
+
+	// pausing an unknown target is a 404
+	request = httptest.NewRequest(http.MethodPost, "/targets/missing/pause", nil)
+	request.SetPathValue("id", "missing")
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected HTTP 404 for unknown target, got %d", responseRecorder.Code)
+	}
+}
+
+// TestCreatePausedTarget verifies that a target created paused is not probed
+func TestCreatePausedTarget(t *testing.T) {
+
+	var probes atomic.Int32
+	targetServer := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		probes.Add(1)
+	}))
+	defer targetServer.Close()
+
+	targetStore = NewInMemoryStore()
+	body := `{"name":"Example","url":"` + targetServer.URL + `","paused":true}`
+	request := httptest.NewRequest(http.MethodPost, "/targets", strings.NewReader(body))
+	responseRecorder := httptest.NewRecorder()
+	targetsHandler(responseRecorder, request)
+
+	var created model.Target
+	json.NewDecoder(responseRecorder.Body).Decode(&created)
+	if responseRecorder.Code != http.StatusCreated || !created.Paused {
+		t.Fatalf("expected a paused target, got HTTP %d %+v", responseRecorder.Code, created)
+	}
+
+	// the check the handler starts returns right away, run it again synchronously to be sure
+	runCheck(targetStore, targetAlerter, created)
+
+	results, _ := targetStore.ResultsForTarget(context.Background(), created.ID)
+	if len(results) != 0 || probes.Load() != 0 {
+		t.Fatalf("expected no probe of a paused target, got %d results and %d requests", len(results), probes.Load())
+	}
+}
+
+// TestMaintenanceWindows verifies windows are validated via /maintenance and suppress downtime for matching targets
+func TestMaintenanceWindows(t *testing.T) {
+
+	// a target that is down, as it would be while being upgraded
+	downServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, _ *http.Request) {
+		responseWriter.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer downServer.Close()
+
+	targetStore = NewInMemoryStore()
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Billing", URL: downServer.URL, Tags: []string{"billing"}})
+
+	router := http.NewServeMux()
+	router.HandleFunc("/maintenance", maintenanceHandler)
+	router.HandleFunc("/maintenance/{id}", maintenanceWindowHandler)
+
+	// a window needs a selector and a schedule
+	request := httptest.NewRequest(http.MethodPost, "/maintenance", bytes.NewBufferString(`{"tag": "billing"}`))
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 for window without schedule, got %d", responseRecorder.Code)
+	}
+
+	// a one-off window for the tag, covering now
+	now := time.Now().Unix()
+	body := fmt.Sprintf(`{"tag": "billing", "description": "db upgrade", "startTime": %d, "endTime": %d}`, now-60, now+3600)
+	request = httptest.NewRequest(http.MethodPost, "/maintenance", bytes.NewBufferString(body))
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected HTTP 201 Created, got %d", responseRecorder.Code)
+	}
+	var window model.MaintenanceWindow
+	json.NewDecoder(responseRecorder.Body).Decode(&window)
+
+	// the probe still runs, but is recorded as maintenance
+	runCheck(targetStore, targetAlerter, target)
+	results, _ := targetStore.ResultsForTarget(context.Background(), target.ID)
+	if len(results) != 1 || results[0].Status != model.StatusMaintenance || results[0].MaintenanceWindowID != window.ID {
+		t.Fatalf("expected a maintenance result from window %s, got %+v", window.ID, results)
+	}
+	if results[0].HTTPStatus != http.StatusServiceUnavailable {
+		t.Fatalf("expected the observed http status to be kept, got %d", results[0].HTTPStatus)
+	}
+
+	// after deleting the window the target is reported down again
+	request = httptest.NewRequest(http.MethodDelete, "/maintenance/"+window.ID, nil)
+	request.SetPathValue("id", window.ID)
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusNoContent {
+		t.Fatalf("expected HTTP 204 for DELETE, got %d", responseRecorder.Code)
+	}
+
+	runCheck(targetStore, targetAlerter, target)
+	results, _ = targetStore.ResultsForTarget(context.Background(), target.ID)
+	if len(results) != 2 || results[1].Status != model.StatusDown {
+		t.Fatalf("expected the second result to be down, got %+v", results)
+	}
+
+	// a window can select targets by label selector too, but only by one kind of selector
+	labeled := model.Target{ID: "1", Labels: map[string]string{"team": "billing", "env": "prod"}}
+	byLabels := model.MaintenanceWindow{Labels: "team=billing,env!=staging", StartTime: now - 60, EndTime: now + 3600}
+	if err := maintenance.Validate(byLabels); err != nil || !maintenance.Applies(byLabels, labeled) || maintenance.Applies(byLabels, target) {
+		t.Fatalf("expected the label selector to select only the labeled target (%v)", err)
+	}
+	for _, invalid := range []model.MaintenanceWindow{
+		{Tag: "billing", Labels: "team=billing", StartTime: now - 60, EndTime: now + 3600},
+		{Labels: "team=billing,=", StartTime: now - 60, EndTime: now + 3600},
+	} {
+		if err := maintenance.Validate(invalid); err == nil {
+			t.Fatalf("expected window %+v to be invalid", invalid)
+		}
+	}
+}
+
+// TestRecurringMaintenanceWindow verifies cron based windows open and close at the right times
+func TestRecurringMaintenanceWindow(t *testing.T) {
+
+	// every sunday at 02:30 UTC for 90 minutes
+	window := model.MaintenanceWindow{TargetID: "target", Cron: "30 2 * * 0", DurationMinutes: 90}
+	if err := maintenance.Validate(window); err != nil {
+		t.Fatalf("expected window to be valid, got %v", err)
+	}
+
+	sunday := time.Date(2025, time.November, 23, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		at       time.Time
+		expected bool
+	}{
+		{sunday.Add(2*time.Hour + 29*time.Minute), false},
+		{sunday.Add(2*time.Hour + 30*time.Minute), true},
+		{sunday.Add(3*time.Hour + 59*time.Minute), true},
+		{sunday.Add(4 * time.Hour), false},
+		// same time on monday
+		{sunday.Add(26*time.Hour + 45*time.Minute), false},
+	}
+
+	for _, testCase := range testCases {
+		if active := maintenance.Active(window, testCase.at); active != testCase.expected {
+			t.Fatalf("expected active=%v at %s, got %v", testCase.expected, testCase.at, active)
+		}
+	}
+
+	// malformed expressions are rejected
+	invalid := model.MaintenanceWindow{TargetID: "target", Cron: "61 * * * *", DurationMinutes: 10}
+	if err := maintenance.Validate(invalid); err == nil {
+		t.Fatalf("expected invalid cron minute to be rejected")
+	}
+}
+
+// TestUptime verifies GET /targets/{id}/uptime computes availability, downtime and latency percentiles
+func TestUptime(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Example", URL: "https://example.com", IntervalSeconds: 60})
+
+	// ten probes one minute apart over the last hour: 7 up, 2 down, 1 during maintenance
+	startTime := time.Now().Add(-time.Hour).Unix()
+	statuses := []string{
+		model.StatusUp, model.StatusUp, model.StatusDown, model.StatusDown, model.StatusUp,
+		model.StatusMaintenance, model.StatusUp, model.StatusDegraded, model.StatusUp, model.StatusUp,
+	}
+	for index, status := range statuses {
+		targetStore.AddResult(context.Background(), model.Result{
+			TargetID:  target.ID,
+			Status:    status,
+			Timestamp: startTime + int64(index*60),
+			LatencyMs: int64((index + 1) * 10),
+		})
+	}
+
+	// a result outside the window is ignored
+	targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusDown, Timestamp: startTime - 48*3600})
+
+	router := http.NewServeMux()
+	router.HandleFunc("/targets/{id}/uptime", uptimeHandler)
+
+	// unsupported windows are rejected
+	request := httptest.NewRequest(http.MethodGet, "/targets/"+target.ID+"/uptime?window=forever", nil)
+	request.SetPathValue("id", target.ID)
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 for invalid window, got %d", responseRecorder.Code)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/targets/"+target.ID+"/uptime?window=24h", nil)
+	request.SetPathValue("id", target.ID)
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK, got %d", responseRecorder.Code)
+	}
+
+	var uptime slo.Uptime
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&uptime); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if uptime.Checks != 9 || uptime.UpChecks != 7 || uptime.MaintenanceChecks != 1 {
+		t.Fatalf("expected 9 checks, 7 up and 1 maintenance, got %+v", uptime)
+	}
+	if uptime.AvailabilityPercent != 77.7778 {
+		t.Fatalf("expected availability 77.7778%%, got %v", uptime.AvailabilityPercent)
+	}
+	// two down probes, each followed by another probe a minute later
+	if uptime.DowntimeSeconds != 120 {
+		t.Fatalf("expected 120s downtime, got %d", uptime.DowntimeSeconds)
+	}
+	// latencies of the good probes: 10 20 50 70 80 90 100
+	if uptime.P50LatencyMs != 70 || uptime.P95LatencyMs != 100 || uptime.P99LatencyMs != 100 {
+		t.Fatalf("unexpected latency percentiles: %+v", uptime)
+	}
+	if uptime.MeanLatencyMs != 60 {
+		t.Fatalf("expected mean latency 60ms, got %v", uptime.MeanLatencyMs)
+	}
+}
+
+// TestSLO verifies SLO creation and the error budget and burn rates reported by GET /slos/{id}
+func TestSLO(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+	checkout, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Checkout", URL: "https://checkout.example.com", Tags: []string{"payments"}})
+	refunds, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Refunds", URL: "https://refunds.example.com", Tags: []string{"payments"}})
+	other, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Blog", URL: "https://blog.example.com"})
+
+	// 99 good probes ten hours ago and a single failure half an hour ago, across the two tagged targets
+	now := time.Now()
+	for index := 0; index < 99; index++ {
+		targetID := checkout.ID
+		if index%2 == 1 {
+			targetID = refunds.ID
+		}
+		targetStore.AddResult(context.Background(), model.Result{
+			TargetID:  targetID,
+			Status:    model.StatusUp,
+			Timestamp: now.Add(-10*time.Hour).Unix() + int64(index),
+		})
+	}
+	targetStore.AddResult(context.Background(), model.Result{TargetID: refunds.ID, Status: model.StatusDown, Timestamp: now.Add(-30 * time.Minute).Unix()})
+
+	// ignored: outside the window, during maintenance, and a target without the tag
+	targetStore.AddResult(context.Background(), model.Result{TargetID: checkout.ID, Status: model.StatusDown, Timestamp: now.Add(-48 * time.Hour).Unix()})
+	targetStore.AddResult(context.Background(), model.Result{TargetID: checkout.ID, Status: model.StatusMaintenance, Timestamp: now.Add(-time.Minute).Unix()})
+	targetStore.AddResult(context.Background(), model.Result{TargetID: other.ID, Status: model.StatusDown, Timestamp: now.Add(-time.Minute).Unix()})
+
+	router := http.NewServeMux()
+	router.HandleFunc("/slos", slosHandler)
+	router.HandleFunc("/slos/{id}", sloHandler)
+
+	// invalid definitions are rejected
+	for _, body := range []string{
+		`{"name":"too strict","tag":"payments","objective":100,"window":"24h"}`,
+		`{"name":"no selector","objective":99,"window":"24h"}`,
+		`{"name":"typo","targetId":"missing","objective":99,"window":"24h"}`,
+		`{"name":"bad window","tag":"payments","objective":99,"window":"1y"}`,
+		`{"name":"two selectors","tag":"payments","labels":"team=payments","objective":99,"window":"24h"}`,
+		`{"name":"bad selector","labels":"team==payments","objective":99,"window":"24h"}`,
+	} {
+		request := httptest.NewRequest(http.MethodPost, "/slos", strings.NewReader(body))
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+
+		if responseRecorder.Code != http.StatusBadRequest {
+			t.Fatalf("expected HTTP 400 for %s, got %d", body, responseRecorder.Code)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/slos", strings.NewReader(`{"name":"payments availability","tag":"payments","objective":98,"window":"24h"}`))
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected HTTP 201 Created, got %d", responseRecorder.Code)
+	}
+
+	var created model.SLO
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/slos/"+created.ID, nil)
+	request.SetPathValue("id", created.ID)
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK, got %d", responseRecorder.Code)
+	}
+
+	var report slo.Report
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if len(report.TargetIDs) != 2 || report.TotalEvents != 100 || report.GoodEvents != 99 {
+		t.Fatalf("expected 100 events (99 good) over 2 targets, got %+v", report)
+	}
+	if report.SLIPercent != 99 || report.ErrorBudgetRemainingPercent != 50 {
+		t.Fatalf("expected SLI 99%% and half the budget left, got %v and %v", report.SLIPercent, report.ErrorBudgetRemainingPercent)
+	}
+
+	// the 3d burn rate window doesn't fit in a 24h slo
+	if len(report.BurnRates) != 3 {
+		t.Fatalf("expected burn rates for 1h, 6h and 24h, got %+v", report.BurnRates)
+	}
+	// the only probe in the last hour failed: 100% bad against a 2% budget
+	if report.BurnRates[0].Window != "1h" || report.BurnRates[0].Rate != 50 {
+		t.Fatalf("expected 1h burn rate 50, got %+v", report.BurnRates[0])
+	}
+	if report.BurnRates[2].Rate != 0.5 {
+		t.Fatalf("expected 24h burn rate 0.5, got %+v", report.BurnRates[2])
+	}
+	if report.Status != slo.StatusAtRisk {
+		t.Fatalf("expected status %q, got %q", slo.StatusAtRisk, report.Status)
+	}
+
+	request = httptest.NewRequest(http.MethodDelete, "/slos/"+created.ID, nil)
+	request.SetPathValue("id", created.ID)
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusNoContent {
+		t.Fatalf("expected HTTP 204 No Content, got %d", responseRecorder.Code)
+	}
+	if _, err := targetStore.GetSLO(context.Background(), created.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected slo to be deleted, got %v", err)
+	}
+}
+
+// TestAlerting verifies that down and recovery notifications fire once per outage, after the failure threshold
+func TestAlerting(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+
+	// a target whose health we control
+	var healthy atomic.Bool
+	targetServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, _ *http.Request) {
+		if !healthy.Load() {
+			responseWriter.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer targetServer.Close()
+
+	// collect what the generic and the slack webhook receive
+	events := make(chan alert.Event, 10)
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+		var event alert.Event
+		if err := json.NewDecoder(request.Body).Decode(&event); err != nil {
+			t.Errorf("failed to decode webhook payload: %v", err)
+		}
+		events <- event
+	}))
+	defer webhookServer.Close()
+
+	messages := make(chan string, 10)
+	slackServer := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+		var message struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(request.Body).Decode(&message); err != nil {
+			t.Errorf("failed to decode slack payload: %v", err)
+		}
+		messages <- message.Text
+	}))
+	defer slackServer.Close()
+
+	targetAlerter = &alert.Alerter{
+		FailureThreshold: 2,
+		Notifiers: []alert.Notifier{
+			&alert.WebhookNotifier{URL: webhookServer.URL},
+			&alert.SlackNotifier{WebhookURL: slackServer.URL},
+		},
+	}
+	defer func() { targetAlerter = &alert.Alerter{} }()
+
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Checkout", URL: targetServer.URL})
+
+	// up, then three failures, then up again: one down event on the second failure and one recovery
+	for _, up := range []bool{true, false, false, false, true, true} {
+		healthy.Store(up)
+		runCheck(targetStore, targetAlerter, target)
+	}
+
+	if len(events) != 2 || len(messages) != 2 {
+		t.Fatalf("expected 2 notifications per notifier, got %d webhook and %d slack", len(events), len(messages))
+	}
+
+	down := <-events
+	if down.Kind != alert.EventDown || down.TargetID != target.ID || down.ConsecutiveFailures != 2 || down.HTTPStatus != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected down event: %+v", down)
+	}
+	recovered := <-events
+	if recovered.Kind != alert.EventRecovered || recovered.ConsecutiveFailures != 3 {
+		t.Fatalf("unexpected recovery event: %+v", recovered)
+	}
+
+	if message := <-messages; !strings.Contains(message, "Checkout is DOWN after 2 failed probes") {
+		t.Fatalf("unexpected slack message: %q", message)
+	}
+
+	// probes during maintenance neither alert nor end an outage
+	previous := []model.Result{
+		{Status: model.StatusDown, Timestamp: 1},
+		{Status: model.StatusMaintenance, Timestamp: 2},
+	}
+	if _, ok := alert.Detect(target, previous, model.Result{Status: model.StatusMaintenance, Timestamp: 3}, 2); ok {
+		t.Fatalf("expected no event for a maintenance probe")
+	}
+	if event, ok := alert.Detect(target, previous, model.Result{Status: model.StatusDown, Timestamp: 3}, 2); !ok || event.DownSince != 1 {
+		t.Fatalf("expected the failure streak to continue across maintenance, got %+v %v", event, ok)
+	}
+
+	// the result that crosses the threshold is alerted on even when it can't be stored, the next one wouldn't be
+	brokenStore := failingResultStore{InMemoryStore: NewInMemoryStore()}
+	if err := brokenStore.InMemoryStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusDown, Timestamp: 1}); err != nil {
+		t.Fatalf("failed to add result: %v", err)
+	}
+	err := targetAlerter.RecordResult(context.Background(), brokenStore, target, model.Result{TargetID: target.ID, Status: model.StatusDown, Timestamp: 2})
+	if err == nil {
+		t.Fatalf("expected the store error to be returned")
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected the down event despite the store error, got %d", len(events))
+	}
+}
+
+// failingResultStore is an in-memory store that can't store results
+type failingResultStore struct {
+	*InMemoryStore
+}
+
+// AddResult always fails
+func (failingResultStore) AddResult(context.Context, model.Result) error {
+	return errors.New("store unavailable")
+}
+
+// TestAlertingHistoryIsBounded verifies that recording a result only reads the history the streak needs
+func TestAlertingHistoryIsBounded(t *testing.T) {
+	ctx := context.Background()
+	target := model.Target{ID: "1", Name: "Checkout"}
+	events := &eventRecorder{}
+	alerter := &alert.Alerter{FailureThreshold: 3, Notifiers: []alert.Notifier{events}}
+
+	// a long healthy history, then an outage of 300 probes
+	countingStore := &countingResultStore{InMemoryStore: NewInMemoryStore()}
+	timestamp := int64(0)
+	record := func(status string) {
+		timestamp++
+		if err := alerter.RecordResult(ctx, countingStore, target, model.Result{TargetID: target.ID, Status: status, Timestamp: timestamp}); err != nil {
+			t.Fatalf("failed to record result: %v", err)
+		}
+	}
+	for range 500 {
+		record(model.StatusUp)
+	}
+	// one page of threshold+1 results per probe, however long the history
+	if countingStore.read > 500*4 {
+		t.Fatalf("expected a healthy target to read one page per probe, read %d results for 500 probes", countingStore.read)
+	}
+
+	countingStore.read = 0
+	for range 300 {
+		record(model.StatusDown)
+	}
+	if countingStore.read > 300*4 {
+		t.Fatalf("expected a failing target to read one page per probe, read %d results for 300 probes", countingStore.read)
+	}
+	if len(events.events) != 1 || events.events[0].Kind != alert.EventDown || events.events[0].DownSince != 501 {
+		t.Fatalf("expected one down event, got %+v", events.events)
+	}
+
+	// the recovery reads back to the start of the outage, so its length is exact
+	record(model.StatusUp)
+	if len(events.events) != 2 || events.events[1].Kind != alert.EventRecovered || events.events[1].ConsecutiveFailures != 300 || events.events[1].DownSince != 501 {
+		t.Fatalf("expected a recovery after 300 failures, got %+v", events.events)
+	}
+}
+
+// countingResultStore is an in-memory store that counts the results QueryResults returns
+type countingResultStore struct {
+	*InMemoryStore
+	read int
+}
+
+// QueryResults counts the results of the page
+func (countingResultStore *countingResultStore) QueryResults(ctx context.Context, targetID string, query store.ResultQuery) (store.ResultPage, error) {
+	page, err := countingResultStore.InMemoryStore.QueryResults(ctx, targetID, query)
+	countingResultStore.read += len(page.Results)
+	return page, err
+}
+
+// eventRecorder is a notifier that keeps the events it receives
+type eventRecorder struct {
+	events []alert.Event
+}
+
+// Notify keeps the event
+func (eventRecorder *eventRecorder) Notify(_ context.Context, event alert.Event) error {
+	eventRecorder.events = append(eventRecorder.events, event)
+	return nil
+}
+
+// TestIncidents verifies that an outage opens an incident, recovery resolves it and operators can ack and annotate it
+func TestIncidents(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+	targetAlerter = &alert.Alerter{
+		FailureThreshold: 2,
+		Notifiers:        []alert.Notifier{&alert.IncidentRecorder{Store: targetStore}},
+	}
+	defer func() { targetAlerter = &alert.Alerter{} }()
+
+	var healthy atomic.Bool
+	targetServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, _ *http.Request) {
+		if !healthy.Load() {
+			responseWriter.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer targetServer.Close()
+
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Checkout", URL: targetServer.URL})
+	other, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Blog", URL: "https://blog.example.com"})
+
+	router := http.NewServeMux()
+	router.HandleFunc("/incidents", incidentsHandler)
+	router.HandleFunc("/incidents/{id}", incidentHandler)
+	router.HandleFunc("/incidents/{id}/ack", incidentAckHandler)
+	router.HandleFunc("/incidents/{id}/notes", incidentNotesHandler)
+	router.HandleFunc("/targets/{id}/incidents", targetIncidentsHandler)
+
+	listIncidents := func(path string) []model.Incident {
+		t.Helper()
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if responseRecorder.Code != http.StatusOK {
+			t.Fatalf("expected HTTP 200 OK for %s, got %d", path, responseRecorder.Code)
+		}
+		var incidents []model.Incident
+		if err := json.NewDecoder(responseRecorder.Body).Decode(&incidents); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+		return incidents
+	}
+
+	// a single failure is not confirmed yet
+	healthy.Store(false)
+	runCheck(targetStore, targetAlerter, target)
+	if incidents := listIncidents("/incidents"); len(incidents) != 0 {
+		t.Fatalf("expected no incident after one failure, got %+v", incidents)
+	}
+
+	// the second failure opens the incident
+	runCheck(targetStore, targetAlerter, target)
+	open := listIncidents("/incidents?status=open")
+	if len(open) != 1 || open[0].TargetID != target.ID || open[0].HTTPStatus != http.StatusBadGateway || open[0].EndTime != 0 {
+		t.Fatalf("expected one open incident with the failing status, got %+v", open)
+	}
+	incidentID := open[0].ID
+
+	// acknowledge and annotate it
+	responseRecorder := httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodPost, "/incidents/"+incidentID+"/ack", strings.NewReader(`{"by":"alice"}`)))
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK for ack, got %d", responseRecorder.Code)
+	}
+
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodPost, "/incidents/"+incidentID+"/notes", strings.NewReader(`{"text":"   "}`)))
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 for an empty note, got %d", responseRecorder.Code)
+	}
+
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodPost, "/incidents/"+incidentID+"/notes", strings.NewReader(`{"author":"alice","text":"upstream 502s, rolling back"}`)))
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected HTTP 201 Created for a note, got %d", responseRecorder.Code)
+	}
+
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodPost, "/incidents/missing/ack", nil))
+	if responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected HTTP 404 for an unknown incident, got %d", responseRecorder.Code)
+	}
+
+	// recovery resolves it
+	healthy.Store(true)
+	runCheck(targetStore, targetAlerter, target)
+
+	responseRecorder = httptest.NewRecorder()
+	router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/incidents/"+incidentID, nil))
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK, got %d", responseRecorder.Code)
+	}
+
+	var incident model.Incident
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&incident); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if incident.Status != model.IncidentResolved || incident.EndTime < incident.StartTime || incident.DurationSeconds != incident.EndTime-incident.StartTime {
+		t.Fatalf("expected a resolved incident with its duration, got %+v", incident)
+	}
+	if incident.AcknowledgedBy != "alice" || incident.AcknowledgedAt == 0 {
+		t.Fatalf("expected the acknowledgement to be kept, got %+v", incident)
+	}
+	if len(incident.Notes) != 1 || incident.Notes[0].Text != "upstream 502s, rolling back" {
+		t.Fatalf("expected the note to be kept, got %+v", incident.Notes)
+	}
+
+	// per-target listing only shows the target's own incidents
+	if incidents := listIncidents("/targets/" + target.ID + "/incidents"); len(incidents) != 1 {
+		t.Fatalf("expected 1 incident for the target, got %d", len(incidents))
+	}
+	if incidents := listIncidents("/targets/" + other.ID + "/incidents"); len(incidents) != 0 {
+		t.Fatalf("expected no incidents for the other target, got %d", len(incidents))
+	}
+	if incidents := listIncidents("/incidents?status=open"); len(incidents) != 0 {
+		t.Fatalf("expected no open incidents after recovery, got %d", len(incidents))
+	}
+}
+
+// TestProbeMetrics verifies that probe outcomes and api requests show up on /metrics
+func TestProbeMetrics(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+
+	healthyServer := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer healthyServer.Close()
+	failingServer := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, _ *http.Request) {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingServer.Close()
+
+	healthy, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Healthy", URL: healthyServer.URL})
+	failing, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Failing", URL: failingServer.URL})
+	runCheck(targetStore, targetAlerter, healthy)
+	runCheck(targetStore, targetAlerter, failing)
+
+	// serve through the same instrumented router main uses
+	router := http.NewServeMux()
+	router.HandleFunc("/health", healthHandler)
+	router.Handle("/metrics", promhttp.Handler())
+	handler := metrics.InstrumentHandler(router)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	scrape := func() string {
+		t.Helper()
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if responseRecorder.Code != http.StatusOK {
+			t.Fatalf("expected HTTP 200 OK for /metrics, got %d", responseRecorder.Code)
+		}
+		return responseRecorder.Body.String()
+	}
+
+	metricsOutput := scrape()
+	for _, expected := range []string{
+		fmt.Sprintf(`cloudpulse_target_up{target_id=%q} 1`, healthy.ID),
+		fmt.Sprintf(`cloudpulse_target_up{target_id=%q} 0`, failing.ID),
+		fmt.Sprintf(`cloudpulse_target_http_status{target_id=%q} 500`, failing.ID),
+		fmt.Sprintf(`cloudpulse_probe_errors_total{reason="status_code",target_id=%q} 1`, failing.ID),
+		fmt.Sprintf(`cloudpulse_probe_duration_seconds_count{target_id=%q} 1`, healthy.ID),
+		fmt.Sprintf(`cloudpulse_target_info{name="Healthy",target_id=%q,type="http",url=%q} 1`, healthy.ID, healthyServer.URL),
+		`cloudpulse_api_requests_total{code="200",method="GET",route="/health"}`,
+	} {
+		if !strings.Contains(metricsOutput, expected) {
+			t.Fatalf("expected %q in /metrics output", expected)
+		}
+	}
+
+	// deleted targets disappear from /metrics
+	metrics.ForgetTarget(failing.ID)
+	if strings.Contains(scrape(), failing.ID) {
+		t.Fatalf("expected no series for the deleted target")
+	}
+
+	// transport errors are classified by their text
+	reasons := map[string]string{
+		"dial tcp: lookup nowhere.invalid: no such host":                                   metrics.ReasonDNS,
+		"context deadline exceeded (Client.Timeout exceeded while awaiting headers)":       metrics.ReasonTimeout,
+		"dial tcp 127.0.0.1:1: connect: connection refused":                                metrics.ReasonConnectionRefused,
+		"tls: failed to verify certificate: x509: certificate signed by unknown authority": metrics.ReasonTLS,
+	}
+	for errorText, reason := range reasons {
+		if got := metrics.ErrorReason(model.Result{Error: errorText}); got != reason {
+			t.Fatalf("expected reason %q for %q, got %q", reason, errorText, got)
+		}
+	}
+}
+
+// TestMetricsExport verifies the pushgateway and remote-write exporters the runner uses
+func TestMetricsExport(t *testing.T) {
+
+	target := model.Target{ID: "export-test", Name: "Export", URL: "https://example.com"}
+	metrics.ObserveResult(target, model.Result{TargetID: target.ID, Status: model.StatusUp, HTTPStatus: 200, LatencyMs: 42})
+	defer metrics.ForgetTarget(target.ID)
+
+	type pushedRequest struct {
+		method string
+		path   string
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan pushedRequest, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		requests <- pushedRequest{method: request.Method, path: request.URL.Path, header: request.Header, body: body}
+	}))
+	defer receiver.Close()
+
+	// the pushgateway gets one group per target, the target_id comes from the grouping key
+	pushgatewayExporter := &metrics.PushgatewayExporter{URL: receiver.URL}
+	if err := pushgatewayExporter.Export(context.Background(), []string{target.ID}); err != nil {
+		t.Fatalf("pushgateway export failed: %v", err)
+	}
+
+	pushed := <-requests
+	if pushed.method != http.MethodPut || pushed.path != "/metrics/job/cloudpulse-runner/target_id/export-test" {
+		t.Fatalf("unexpected pushgateway request %s %s", pushed.method, pushed.path)
+	}
+	if !bytes.Contains(pushed.body, []byte("cloudpulse_target_up")) || bytes.Contains(pushed.body, []byte("target_id")) {
+		t.Fatalf("expected the target's metrics without the target_id label in the push")
+	}
+
+	// remote write gets a snappy compressed protobuf with every series of the target
+	remoteWriteExporter := &metrics.RemoteWriteExporter{URL: receiver.URL + "/api/v1/write"}
+	if err := remoteWriteExporter.Export(context.Background(), []string{target.ID}); err != nil {
+		t.Fatalf("remote write export failed: %v", err)
+	}
+
+	written := <-requests
+	if written.method != http.MethodPost || written.header.Get("Content-Encoding") != "snappy" || written.header.Get("Content-Type") != "application/x-protobuf" {
+		t.Fatalf("unexpected remote write request %s with headers %v", written.method, written.header)
+	}
+	writeRequest, err := snappy.Decode(nil, written.body)
+	if err != nil {
+		t.Fatalf("remote write body is not snappy encoded: %v", err)
+	}
+	for _, expected := range []string{"cloudpulse_target_up", "cloudpulse_probe_duration_seconds_bucket", "+Inf", "export-test", "cloudpulse-runner"} {
+		if !bytes.Contains(writeRequest, []byte(expected)) {
+			t.Fatalf("expected %q in the remote write request", expected)
+		}
+	}
+
+	// nothing to send for targets without metrics
+	if err := remoteWriteExporter.Export(context.Background(), []string{"unknown"}); err != nil || len(requests) != 0 {
+		t.Fatalf("expected no request for a target without metrics, got %v", err)
+	}
+}
+
+// TestTracing verifies that requests and probes produce spans, and that incoming trace context is continued
+func TestTracing(t *testing.T) {
+
+	// record spans in memory instead of exporting them
+	spanRecorder := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	defer otel.SetTracerProvider(previousProvider)
+	if _, err := tracing.Setup(context.Background(), "cloudpulse-test"); err != nil {
+		t.Fatalf("failed to set up tracing: %v", err)
+	}
+
+	targetStore = NewInMemoryStore()
+	targetServer := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer targetServer.Close()
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Example", URL: targetServer.URL})
+
+	// a probe gets its own span with the timing breakdown
+	result := probe.Check(context.Background(), target)
+
+	router := http.NewServeMux()
+	router.HandleFunc("/results/{id}", resultsForTargetHandler)
+	handler := tracing.InstrumentHandler(router)
+
+	request := httptest.NewRequest(http.MethodGet, "/results/"+target.ID, nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range spanRecorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	probeSpan, ok := spans["probe.Check"]
+	if !ok {
+		t.Fatalf("expected a probe.Check span, got %v", spans)
+	}
+	probeAttributes := map[attribute.Key]attribute.Value{}
+	for _, keyValue := range probeSpan.Attributes() {
+		probeAttributes[keyValue.Key] = keyValue.Value
+	}
+	if probeAttributes["cloudpulse.target.id"].AsString() != target.ID || probeAttributes["cloudpulse.probe.status"].AsString() != model.StatusUp {
+		t.Fatalf("unexpected probe span attributes: %v", probeAttributes)
+	}
+	if probeAttributes["cloudpulse.probe.latency_ms"].AsInt64() != result.LatencyMs {
+		t.Fatalf("expected the probe latency on the span, got %v", probeAttributes["cloudpulse.probe.latency_ms"])
+	}
+
+	requestSpan, ok := spans["GET /results/{id}"]
+	if !ok {
+		t.Fatalf("expected a span named after the route, got %v", spans)
+	}
+	if requestSpan.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || requestSpan.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("expected the request span to continue the incoming trace, got trace %s parent %s", requestSpan.SpanContext().TraceID(), requestSpan.Parent().SpanID())
+	}
+}
+
+func TestTargetLabels(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+
+	createTarget := func(body string) (int, model.Target) {
+		t.Helper()
+		responseRecorder := httptest.NewRecorder()
+		targetsHandler(responseRecorder, httptest.NewRequest(http.MethodPost, "/targets", strings.NewReader(body)))
+		var created model.Target
+		if responseRecorder.Code == http.StatusCreated {
+			if err := json.NewDecoder(responseRecorder.Body).Decode(&created); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+		}
+		return responseRecorder.Code, created
+	}
+
+	_, checkout := createTarget(`{"name":"Checkout","url":"https://checkout.example.com","labels":{"team":"payments","env":"prod"}}`)
+	_, staging := createTarget(`{"name":"Checkout staging","url":"https://staging.example.com","labels":{"team":"payments","env":"staging"}}`)
+	_, blog := createTarget(`{"name":"Blog","url":"https://blog.example.com","labels":{"team":"marketing"}}`)
+
+	// invalid keys and values are rejected
+	for _, body := range []string{
+		`{"name":"Bad","url":"https://bad.example.com","labels":{"team name":"payments"}}`,
+		`{"name":"Bad","url":"https://bad.example.com","labels":{"team":"pay,ments"}}`,
+	} {
+		if code, _ := createTarget(body); code != http.StatusBadRequest {
+			t.Fatalf("expected HTTP 400 Bad Request for %s, got %d", body, code)
+		}
+	}
+
+	listTargets := func(selector string) []string {
+		t.Helper()
+		responseRecorder := httptest.NewRecorder()
+		targetsHandler(responseRecorder, httptest.NewRequest(http.MethodGet, "/targets?labels="+url.QueryEscape(selector), nil))
+		if responseRecorder.Code != http.StatusOK {
+			t.Fatalf("expected HTTP 200 OK for selector %q, got %d", selector, responseRecorder.Code)
+		}
+		var targets []model.Target
+		if err := json.NewDecoder(responseRecorder.Body).Decode(&targets); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+		var ids []string
+		for _, target := range targets {
+			ids = append(ids, target.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	sorted := func(ids ...string) []string {
+		slices.Sort(ids)
+		return ids
+	}
+
+	if ids := listTargets(""); !slices.Equal(ids, sorted(checkout.ID, staging.ID, blog.ID)) {
+		t.Fatalf("expected every target without a selector, got %v", ids)
+	}
+	if ids := listTargets("team=payments,env=prod"); !slices.Equal(ids, []string{checkout.ID}) {
+		t.Fatalf("expected only the prod payments target, got %v", ids)
+	}
+	if ids := listTargets("team=payments,env!=prod"); !slices.Equal(ids, []string{staging.ID}) {
+		t.Fatalf("expected only the staging payments target, got %v", ids)
+	}
+	if ids := listTargets("!env"); !slices.Equal(ids, []string{blog.ID}) {
+		t.Fatalf("expected only the target without env label, got %v", ids)
+	}
+	if ids := listTargets("team=search"); len(ids) != 0 {
+		t.Fatalf("expected no targets, got %v", ids)
+	}
+
+	responseRecorder := httptest.NewRecorder()
+	targetsHandler(responseRecorder, httptest.NewRequest(http.MethodGet, "/targets?labels="+url.QueryEscape("team==payments"), nil))
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected HTTP 400 Bad Request for an invalid selector, got %d", responseRecorder.Code)
+	}
+
+	// latest results carry the labels of their target and are filtered the same way
+	for _, target := range []model.Target{checkout, staging, blog} {
+		_ = targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusUp, Timestamp: time.Now().Unix()})
+	}
+
+	responseRecorder = httptest.NewRecorder()
+	resultsHandler(responseRecorder, httptest.NewRequest(http.MethodGet, "/results?labels=team=payments", nil))
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK, got %d", responseRecorder.Code)
+	}
+	var results []model.Result
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected the two payments results, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Labels["team"] != "payments" {
+			t.Fatalf("expected payments labels on the result, got %+v", result)
+		}
+	}
+}
+
+// TestResultsPagination pages through a target's history with a time range, a limit and the cursor
+func TestResultsPagination(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Example", URL: "https://example.com"})
+
+	// 250 probes a minute apart, the last three share a timestamp
+	start := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC).Unix()
+	for index := 0; index < 250; index++ {
+		timestamp := start + int64(min(index, 247))*60
+		_ = targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusUp, HTTPStatus: 200 + index, Timestamp: timestamp})
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc("/results/{id}", resultsForTargetHandler)
+
+	// readAll follows the cursor until the last page and returns the http status of every result
+	readAll := func(parameters string) (pages int, statuses []int) {
+		t.Helper()
+		cursor := ""
+		for {
+			path := "/results/" + target.ID + "?" + parameters
+			if cursor != "" {
+				path += "&cursor=" + url.QueryEscape(cursor)
+			}
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, path, nil))
+			if responseRecorder.Code != http.StatusOK {
+				t.Fatalf("expected HTTP 200 OK for %s, got %d", path, responseRecorder.Code)
+			}
+			var results []model.Result
+			if err := json.NewDecoder(responseRecorder.Body).Decode(&results); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+			pages++
+			for _, result := range results {
+				statuses = append(statuses, result.HTTPStatus)
+			}
+			cursor = responseRecorder.Header().Get("X-Next-Cursor")
+			if cursor == "" {
+				return pages, statuses
+			}
+		}
+	}
+
+	expectRange := func(statuses []int, first, last int) {
+		t.Helper()
+		step := 1
+		if last < first {
+			step = -1
+		}
+		var expected []int
+		for status := first; status != last+step; status += step {
+			expected = append(expected, status)
+		}
+		if !slices.Equal(statuses, expected) {
+			t.Fatalf("expected results %d..%d, got %v", first-200, last-200, statuses)
+		}
+	}
+
+	// the default page size is 100, newest first so a client that doesn't page sees the recent results
+	pages, statuses := readAll("")
+	if pages != 3 {
+		t.Fatalf("expected 3 pages, got %d", pages)
+	}
+	expectRange(statuses, 449, 200)
+
+	pages, statuses = readAll("order=asc")
+	if pages != 3 {
+		t.Fatalf("expected 3 pages, got %d", pages)
+	}
+	expectRange(statuses, 200, 449)
+
+	// pages that end in the middle of equal timestamps neither repeat nor skip results
+	pages, statuses = readAll("limit=2&order=asc&from=" + strconv.FormatInt(start+240*60, 10))
+	if pages != 5 {
+		t.Fatalf("expected 5 pages, got %d", pages)
+	}
+	expectRange(statuses, 440, 449)
+
+	pages, statuses = readAll("limit=4&order=desc&to=" + time.Unix(start+9*60, 0).UTC().Format(time.RFC3339))
+	if pages != 3 {
+		t.Fatalf("expected 3 pages, got %d", pages)
+	}
+	expectRange(statuses, 209, 200)
+
+	for _, parameters := range []string{"limit=0", "limit=abc", "order=sideways", "from=yesterday", "from=200&to=100", "cursor=not-a-cursor"} {
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/results/"+target.ID+"?"+parameters, nil))
+		if responseRecorder.Code != http.StatusBadRequest {
+			t.Fatalf("expected HTTP 400 Bad Request for %s, got %d", parameters, responseRecorder.Code)
+		}
+	}
+}
+
+// TestResultSeries rolls results up into minute and hour buckets and serves them as a series
+func TestResultSeries(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Example", URL: "https://example.com"})
+
+	// 10:00 to 10:59: one probe every 30 seconds, 10 to 1190ms; 11:00: one down and one maintenance probe
+	start := time.Date(2025, 11, 1, 10, 0, 0, 0, time.UTC).Unix()
+	for index := int64(0); index < 120; index++ {
+		_ = targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusUp, LatencyMs: 10 + index*10, Timestamp: start + index*30})
+	}
+	_ = targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusDown, Timestamp: start + 3600})
+	_ = targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusMaintenance, Timestamp: start + 3630})
+
+	router := http.NewServeMux()
+	router.HandleFunc("/results/{id}/series", resultsSeriesHandler)
+
+	getSeries := func(parameters string) seriesResponse {
+		t.Helper()
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/results/"+target.ID+"/series?"+parameters, nil))
+		if responseRecorder.Code != http.StatusOK {
+			t.Fatalf("expected HTTP 200 OK for %s, got %d: %s", parameters, responseRecorder.Code, responseRecorder.Body.String())
+		}
+		var series seriesResponse
+		if err := json.NewDecoder(responseRecorder.Body).Decode(&series); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+		return series
+	}
+
+	hourly := getSeries(fmt.Sprintf("step=1h&from=%d&to=%d", start+1800, start+7200))
+	if len(hourly.Points) != 2 {
+		t.Fatalf("expected 2 hourly points, got %+v", hourly.Points)
+	}
+	first, second := hourly.Points[0], hourly.Points[1]
+	if first.Start != start || first.Count != 120 || first.UpCount != 120 || first.MinLatencyMs != 10 || first.MaxLatencyMs != 1200 || first.AvgLatencyMs != 605 {
+		t.Fatalf("unexpected first hour %+v", first)
+	}
+	// the 114th latency is 1140ms, in the histogram bucket up to 2500ms, clamped to the max
+	if first.P95LatencyMs != 1200 || first.AvailabilityPercent != 100 {
+		t.Fatalf("unexpected p95 or availability in first hour %+v", first)
 	}
-	if results[1].Status != "up" || results[1].HTTPStatus != 200 {
-		t.Fatalf("expected second result up/200, got status=%q httpStatus=%d", results[1].Status, results[1].HTTPStatus)
+	if second.Count != 1 || second.UpCount != 0 || second.MaintenanceCount != 1 || second.AvailabilityPercent != 0 || second.MaxLatencyMs != 0 {
+		t.Fatalf("unexpected second hour %+v", second)
+	}
+
+	minutely := getSeries(fmt.Sprintf("step=1m&from=%d&to=%d", start, start+299))
+	if len(minutely.Points) != 5 {
+		t.Fatalf("expected 5 minute points, got %+v", minutely.Points)
+	}
+	if point := minutely.Points[2]; point.Start != start+120 || point.Count != 2 || point.MinLatencyMs != 50 || point.MaxLatencyMs != 60 || point.AvgLatencyMs != 55 {
+		t.Fatalf("unexpected third minute %+v", point)
+	}
+
+	for _, parameters := range []string{"step=5m", "step=1m&from=0", "from=200&to=100"} {
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/results/"+target.ID+"/series?"+parameters, nil))
+		if responseRecorder.Code != http.StatusBadRequest {
+			t.Fatalf("expected HTTP 400 Bad Request for %s, got %d", parameters, responseRecorder.Code)
+		}
+	}
+
+	// purging the target drops its rollups too
+	if err := targetStore.DeleteTarget(context.Background(), target.ID, true); err != nil {
+		t.Fatalf("failed to delete target: %v", err)
+	}
+	if series := getSeries(fmt.Sprintf("step=1h&from=%d&to=%d", start, start+7200)); len(series.Points) != 0 {
+		t.Fatalf("expected no points after purge, got %+v", series.Points)
+	}
+}
+
+// TestResultsLatestIgnoresLateResults checks that a result stored after a newer one doesn't become the latest
+func TestResultsLatestIgnoresLateResults(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+	target, _ := targetStore.AddTarget(context.Background(), model.Target{Name: "Example", URL: "https://example.com"})
+
+	now := time.Now().Unix()
+	_ = targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusUp, HTTPStatus: 200, Timestamp: now})
+	_ = targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusDown, HTTPStatus: 503, Timestamp: now - 30})
+
+	responseRecorder := httptest.NewRecorder()
+	resultsHandler(responseRecorder, httptest.NewRequest(http.MethodGet, "/results", nil))
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected HTTP 200 OK, got %d", responseRecorder.Code)
+	}
+
+	var results []model.Result
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&results); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(results) != 1 || results[0].Timestamp != now || results[0].Status != model.StatusUp || results[0].Name != "Example" {
+		t.Fatalf("expected the newest result as latest, got %+v", results)
+	}
+}
+
+// TestBoltStoreSurvivesRestart stores targets, results and incidents in the file store and reads them back after reopening it
+func TestBoltStoreSurvivesRestart(t *testing.T) {
+
+	// the store semantics are covered by the conformance suite, this only checks what is on disk after a restart
+	path := t.TempDir() + "/cloudpulse.db"
+	boltStore, err := store.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("failed to open bolt store: %v", err)
+	}
+
+	target, err := boltStore.AddTarget(context.Background(), model.Target{Name: "Checkout", URL: "https://checkout.example.com", Labels: map[string]string{"team": "payments"}})
+	if err != nil {
+		t.Fatalf("failed to add target: %v", err)
+	}
+	start := time.Date(2025, 11, 1, 10, 0, 0, 0, time.UTC).Unix()
+	for index := int64(0); index < 5; index++ {
+		_ = boltStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusUp, HTTPStatus: 200 + int(index), LatencyMs: 100, Timestamp: start + index*30})
+	}
+	incident, _ := boltStore.AddIncident(context.Background(), model.Incident{TargetID: target.ID, Status: model.IncidentOpen, StartTime: start})
+	_, _ = boltStore.AddIncidentNote(context.Background(), incident.ID, model.IncidentNote{Text: "looking into it", Timestamp: start})
+
+	if err := boltStore.Close(); err != nil {
+		t.Fatalf("failed to close bolt store: %v", err)
+	}
+	boltStore, err = store.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen bolt store: %v", err)
+	}
+	defer boltStore.Close()
+
+	if reopened, err := boltStore.GetTarget(context.Background(), target.ID); err != nil || reopened.Name != "Checkout" || reopened.Labels["team"] != "payments" {
+		t.Fatalf("expected the target after reopening, got %+v (%v)", reopened, err)
+	}
+	if latest, err := boltStore.LatestResults(context.Background()); err != nil || len(latest) != 1 || latest[0].HTTPStatus != 204 {
+		t.Fatalf("expected the latest result after reopening, got %+v (%v)", latest, err)
+	}
+	if history, err := boltStore.ResultsForTarget(context.Background(), target.ID); err != nil || len(history) != 5 {
+		t.Fatalf("expected the history after reopening, got %+v (%v)", history, err)
+	}
+	if rollups, err := boltStore.Rollups(context.Background(), target.ID, time.Hour, time.Unix(start, 0), time.Unix(start, 0)); err != nil || len(rollups) != 1 || rollups[0].Count != 5 {
+		t.Fatalf("expected the hourly rollup after reopening, got %+v (%v)", rollups, err)
+	}
+	if reopened, err := boltStore.GetIncident(context.Background(), incident.ID); err != nil || len(reopened.Notes) != 1 {
+		t.Fatalf("expected the incident with its note after reopening, got %+v (%v)", reopened, err)
+	}
+}
+
+// every backend runs the shared conformance suite
+// PostgreSQL and DynamoDB Local only when a database is available, the CI job starts both
+func TestStoreConformance(t *testing.T) {
+
+	t.Run("InMemory", func(t *testing.T) {
+		storetest.Run(t, func(t *testing.T) store.Store { return NewInMemoryStore() })
+	})
+
+	t.Run("Bolt", func(t *testing.T) {
+		storetest.Run(t, func(t *testing.T) store.Store {
+			boltStore, err := store.NewBoltStore(t.TempDir() + "/cloudpulse.db")
+			if err != nil {
+				t.Fatalf("failed to open bolt store: %v", err)
+			}
+			t.Cleanup(func() { boltStore.Close() })
+			return boltStore
+		})
+	})
+
+	t.Run("Postgres", func(t *testing.T) {
+		databaseURL := os.Getenv("CLOUDPULSE_TEST_DATABASE_URL")
+		if databaseURL == "" {
+			t.Skip("CLOUDPULSE_TEST_DATABASE_URL is not set")
+		}
+		storetest.Run(t, func(t *testing.T) store.Store {
+			postgresStore, err := store.NewPostgresStore(context.Background(), databaseURL)
+			if err != nil {
+				t.Fatalf("failed to open postgres store: %v", err)
+			}
+			t.Cleanup(postgresStore.Close)
+			return postgresStore
+		})
+	})
+
+	t.Run("DynamoDB", func(t *testing.T) {
+		endpoint := os.Getenv("CLOUDPULSE_TEST_DYNAMODB_ENDPOINT")
+		if endpoint == "" {
+			t.Skip("CLOUDPULSE_TEST_DYNAMODB_ENDPOINT is not set")
+		}
+		dynamoDBStore := newDynamoDBTestStore(t, endpoint)
+		storetest.Run(t, func(t *testing.T) store.Store { return dynamoDBStore })
+	})
+}
+
+// newDynamoDBTestStore creates fresh tables on DynamoDB Local, with the keys of setup-tables-job.yaml, and drops them afterwards
+func newDynamoDBTestStore(t *testing.T, endpoint string) *store.DynamoDBStore {
+	t.Helper()
+
+	// DynamoDB Local accepts any credentials, but the SDK wants some
+	t.Setenv("AWS_ENDPOINT", endpoint)
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		t.Setenv("AWS_ACCESS_KEY_ID", "dummy")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "dummy")
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.Background(), config.WithRegion("us-east-1"))
+	if err != nil {
+		t.Fatalf("failed to load aws config: %v", err)
+	}
+	client := dynamodb.NewFromConfig(awsConfig, func(options *dynamodb.Options) {
+		options.BaseEndpoint = aws.String(endpoint)
+	})
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	createTable := func(name string, keys ...string) string {
+		tableName := "storetest-" + name + "-" + suffix
+		input := &dynamodb.CreateTableInput{
+			TableName:   aws.String(tableName),
+			BillingMode: types.BillingModePayPerRequest,
+		}
+		// the first key is the hash key, an optional second one the numeric range key
+		input.AttributeDefinitions = append(input.AttributeDefinitions, types.AttributeDefinition{AttributeName: aws.String(keys[0]), AttributeType: types.ScalarAttributeTypeS})
+		input.KeySchema = append(input.KeySchema, types.KeySchemaElement{AttributeName: aws.String(keys[0]), KeyType: types.KeyTypeHash})
+		if len(keys) > 1 {
+			input.AttributeDefinitions = append(input.AttributeDefinitions, types.AttributeDefinition{AttributeName: aws.String(keys[1]), AttributeType: types.ScalarAttributeTypeN})
+			input.KeySchema = append(input.KeySchema, types.KeySchemaElement{AttributeName: aws.String(keys[1]), KeyType: types.KeyTypeRange})
+		}
+		if _, err := client.CreateTable(context.Background(), input); err != nil {
+			t.Fatalf("failed to create table %s: %v", tableName, err)
+		}
+		t.Cleanup(func() {
+			_, _ = client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(tableName)})
+		})
+		return tableName
+	}
+
+	targetsTable := createTable("targets", "id")
+	resultsTable := createTable("results", "target_id", "timestamp")
+	dynamoDBStore, err := store.NewDynamoDBStore(context.Background(), "us-east-1", targetsTable, resultsTable,
+		store.WithMaintenanceTable(createTable("maintenance", "id")),
+		store.WithSLOsTable(createTable("slos", "id")),
+		store.WithIncidentsTable(createTable("incidents", "id")),
+	)
+	if err != nil {
+		t.Fatalf("failed to init dynamodb store: %v", err)
+	}
+	return dynamoDBStore
+}
+
+func TestResultRetention(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+	defer func() { resultRetention = retention.Default }()
+
+	createTarget := func(body string) (int, model.Target) {
+		t.Helper()
+		responseRecorder := httptest.NewRecorder()
+		targetsHandler(responseRecorder, httptest.NewRequest(http.MethodPost, "/targets", strings.NewReader(body)))
+		var created model.Target
+		if responseRecorder.Code == http.StatusCreated {
+			if err := json.NewDecoder(responseRecorder.Body).Decode(&created); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+		}
+		return responseRecorder.Code, created
+	}
+
+	// settings out of range are rejected
+	for _, body := range []string{
+		`{"name":"Bad","url":"https://bad.example.com","retentionDays":-1}`,
+		`{"name":"Bad","url":"https://bad.example.com","maxResults":-1}`,
+		`{"name":"Bad","url":"https://bad.example.com","retentionDays":3651}`,
+	} {
+		if code, _ := createTarget(body); code != http.StatusBadRequest {
+			t.Fatalf("expected HTTP 400 Bad Request for %s, got %d", body, code)
+		}
+	}
+
+	code, limited := createTarget(`{"name":"Limited","url":"https://limited.example.com","maxResults":2}`)
+	if code != http.StatusCreated || limited.MaxResults != 2 {
+		t.Fatalf("expected the target to keep its maxResults, got %d %+v", code, limited)
+	}
+	_, unlimited := createTarget(`{"name":"Unlimited","url":"https://unlimited.example.com"}`)
+
+	now := time.Now().Unix()
+	for _, target := range []model.Target{limited, unlimited} {
+		for index := int64(5); index > 0; index-- {
+			if err := targetStore.AddResult(context.Background(), model.Result{TargetID: target.ID, Status: model.StatusUp, Timestamp: now - index*60}); err != nil {
+				t.Fatalf("failed to add result: %v", err)
+			}
+		}
+	}
+
+	// the compactor applies the global policy, which the limited target overrides
+	resultRetention = retention.Policy{MaxAge: 4*time.Minute + 30*time.Second}
+	compact(context.Background(), time.Unix(now, 0))
+
+	if history, _ := targetStore.ResultsForTarget(context.Background(), limited.ID); len(history) != 2 || history[0].Timestamp != now-120 {
+		t.Fatalf("expected the two newest results of the limited target, got %+v", history)
+	}
+	if history, _ := targetStore.ResultsForTarget(context.Background(), unlimited.ID); len(history) != 4 || history[0].Timestamp != now-240 {
+		t.Fatalf("expected the results of the last four and a half minutes, got %+v", history)
+	}
+
+	// DynamoDB only deletes by time, so the count limit becomes an age over the probe interval
+	result := model.Result{Timestamp: now}
+	if expiry := retention.Default.Expiry(result, time.Minute); !expiry.Equal(time.Unix(now, 0).Add(30 * 24 * time.Hour)) {
+		t.Fatalf("expected the default expiry after 30 days, got %v", expiry)
+	}
+	if expiry := retention.Default.ForTarget(limited).Expiry(result, time.Minute); !expiry.Equal(time.Unix(now+120, 0)) {
+		t.Fatalf("expected two results a minute apart to expire after two minutes, got %v", expiry)
+	}
+	if expiry := (retention.Policy{}).Expiry(result, time.Minute); !expiry.IsZero() {
+		t.Fatalf("expected results without a limit never to expire, got %v", expiry)
+	}
+}
+
+func TestTargetsImportExport(t *testing.T) {
+
+	targetStore = NewInMemoryStore()
+
+	existing, err := targetStore.AddTarget(context.Background(), model.Target{Name: "Checkout", URL: "https://checkout.example.com", Type: model.TargetTypeHTTP, Method: http.MethodGet})
+	if err != nil {
+		t.Fatalf("failed to add target: %v", err)
+	}
+	unchanged, err := targetStore.AddTarget(context.Background(), model.Target{Name: "Blog", URL: "https://blog.example.com", Type: model.TargetTypeHTTP, Method: http.MethodGet})
+	if err != nil {
+		t.Fatalf("failed to add target: %v", err)
+	}
+
+	importTargets := func(query, body string) (int, importReport) {
+		t.Helper()
+		responseRecorder := httptest.NewRecorder()
+		targetsImportHandler(responseRecorder, httptest.NewRequest(http.MethodPost, "/targets:import"+query, strings.NewReader(body)))
+		var report importReport
+		if strings.HasPrefix(responseRecorder.Header().Get("Content-Type"), "application/json") {
+			if err := json.NewDecoder(responseRecorder.Body).Decode(&report); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+		}
+		return responseRecorder.Code, report
+	}
+
+	document := `
+targets:
+  - name: Checkout
+    url: https://checkout.example.com
+    labels:
+      team: payments
+  - name: Blog
+    url: https://blog.example.com
+  - name: Orders DB
+    type: tcp
+    url: orders-db.internal:5432
+`
+
+	// a dry run reports what would change without writing anything
+	code, report := importTargets("?dryRun=true", document)
+	if code != http.StatusOK || !report.DryRun || report.Created != 1 || report.Updated != 1 || report.Unchanged != 1 {
+		t.Fatalf("expected one target to create, update and keep, got %d %+v", code, report)
+	}
+	if report.Entries[0].ID != existing.ID || report.Entries[2].Action != importCreated || report.Entries[2].ID != "" {
+		t.Fatalf("unexpected entries %+v", report.Entries)
+	}
+	if targets, _ := targetStore.ListTargets(context.Background()); len(targets) != 2 {
+		t.Fatalf("expected the dry run not to write, got %+v", targets)
+	}
+
+	// invalid entries are reported one by one, and nothing is written
+	code, report = importTargets("", `{"targets": [
+		{"name": "Checkout", "url": "https://checkout.example.com", "labels": {"team": "payments"}},
+		{"name": "Broken", "url": "ftp://broken.example.com"},
+		{"name": "Checkout", "url": "https://other.example.com"},
+		{"url": "https://nameless.example.com"}
+	]}`)
+	if code != http.StatusBadRequest || report.Invalid != 3 || report.Entries[0].Action != importUpdated {
+		t.Fatalf("expected three invalid entries, got %d %+v", code, report)
+	}
+	for _, index := range []int{1, 2, 3} {
+		if entry := report.Entries[index]; entry.Action != importInvalid || entry.Error == "" {
+			t.Fatalf("expected entry %d to be invalid with an error, got %+v", index, entry)
+		}
+	}
+	if stored, _ := targetStore.GetTarget(context.Background(), existing.ID); len(stored.Labels) != 0 {
+		t.Fatalf("expected nothing to be written when an entry is invalid, got %+v", stored)
+	}
+
+	// unknown fields and empty documents are rejected
+	for _, body := range []string{`targets: [{name: Typo, url: "https://typo.example.com", intervalSecond: 30}]`, `targets: []`, `{`} {
+		if code, _ := importTargets("", body); code != http.StatusBadRequest {
+			t.Fatalf("expected HTTP 400 Bad Request for %s, got %d", body, code)
+		}
+	}
+
+	// the import creates and updates by name, and keeps the ids of known targets
+	code, report = importTargets("", document)
+	if code != http.StatusOK || report.DryRun || report.Created != 1 || report.Updated != 1 || report.Unchanged != 1 || report.Entries[2].ID == "" {
+		t.Fatalf("expected the import to apply, got %d %+v", code, report)
+	}
+	if stored, _ := targetStore.GetTarget(context.Background(), existing.ID); stored.Labels["team"] != "payments" {
+		t.Fatalf("expected the existing target to be updated, got %+v", stored)
+	}
+	if stored, _ := targetStore.GetTarget(context.Background(), report.Entries[2].ID); stored.Type != model.TargetTypeTCP || stored.Name != "Orders DB" {
+		t.Fatalf("expected the tcp target to be created, got %+v", stored)
+	}
+
+	// header values are redacted in the export, and the redacted values keep the stored ones on import
+	unchanged.Headers = map[string]string{"Authorization": "Bearer secret"}
+	if _, err := targetStore.UpdateTarget(context.Background(), unchanged); err != nil {
+		t.Fatalf("failed to update target: %v", err)
+	}
+
+	// the export reads back in without changes, in both formats
+	for _, format := range []string{"yaml", "json"} {
+		responseRecorder := httptest.NewRecorder()
+		targetsExportHandler(responseRecorder, httptest.NewRequest(http.MethodGet, "/targets:export?format="+format, nil))
+		if responseRecorder.Code != http.StatusOK || !strings.Contains(responseRecorder.Header().Get("Content-Type"), format) {
+			t.Fatalf("expected a %s export, got %d %q", format, responseRecorder.Code, responseRecorder.Header().Get("Content-Type"))
+		}
+		exported := responseRecorder.Body.String()
+		if strings.Index(exported, "Blog") > strings.Index(exported, "Orders DB") {
+			t.Fatalf("expected the export to be sorted by name, got %s", exported)
+		}
+		if strings.Contains(exported, "Bearer secret") || !strings.Contains(exported, redactedHeaderValue) {
+			t.Fatalf("expected the header value to be redacted, got %s", exported)
+		}
+
+		code, report := importTargets("?dryRun=true", exported)
+		if code != http.StatusOK || report.Unchanged != 3 {
+			t.Fatalf("expected the %s export to import unchanged, got %d %+v", format, code, report)
+		}
+	}
+	if report.Entries[1].ID != unchanged.ID {
+		t.Fatalf("expected the blog to keep its id, got %+v", report.Entries[1])
+	}
+
+	// the header values are only exported when asked for
+	responseRecorder := httptest.NewRecorder()
+	targetsExportHandler(responseRecorder, httptest.NewRequest(http.MethodGet, "/targets:export?includeHeaderValues=true", nil))
+	if !strings.Contains(responseRecorder.Body.String(), "Bearer secret") {
+		t.Fatalf("expected the header value in the export, got %s", responseRecorder.Body.String())
+	}
+
+	// a new target has no stored value for a redacted one to keep
+	code, report = importTargets("?dryRun=true", `targets: [{name: Admin, url: "https://admin.example.com", headers: {Authorization: "`+redactedHeaderValue+`"}}]`)
+	if code != http.StatusBadRequest || report.Invalid != 1 {
+		t.Fatalf("expected a redacted header value of a new target to be invalid, got %d %+v", code, report)
+	}
+
+	// the export takes a label selector like GET /targets
+	responseRecorder = httptest.NewRecorder()
+	targetsExportHandler(responseRecorder, httptest.NewRequest(http.MethodGet, "/targets:export?labels=team=payments", nil))
+	var exported targetDocument
+	if err := json.NewDecoder(responseRecorder.Body).Decode(&exported); err != nil || len(exported.Targets) != 1 || exported.Targets[0].ID != existing.ID {
+		t.Fatalf("expected only the payments target, got %+v (%v)", exported, err)
+	}
+
+	// when the store fails partway, the targets written before are undone
+	brokenStore := &failingTargetStore{InMemoryStore: NewInMemoryStore(), addsLeft: 1}
+	original, _ := brokenStore.InMemoryStore.AddTarget(context.Background(), model.Target{Name: "Checkout", URL: "https://checkout.example.com", Type: model.TargetTypeHTTP, Method: http.MethodGet})
+	targetStore = brokenStore
+	defer func() { targetStore = NewInMemoryStore() }()
+
+	if code, _ := importTargets("", document); code != http.StatusInternalServerError {
+		t.Fatalf("expected HTTP 500 Internal Server Error when the store fails, got %d", code)
+	}
+	if targets, _ := targetStore.ListTargets(context.Background()); len(targets) != 1 {
+		t.Fatalf("expected the created target to be deleted again, got %+v", targets)
+	}
+	if stored, _ := targetStore.GetTarget(context.Background(), original.ID); len(stored.Labels) != 0 {
+		t.Fatalf("expected the updated target to be restored, got %+v", stored)
+	}
+}
+
+// failingTargetStore is an in-memory store that fails to add targets once addsLeft are used up
+type failingTargetStore struct {
+	*InMemoryStore
+	addsLeft int
+}
+
+// AddTarget adds the target while addsLeft lasts
+func (failingTargetStore *failingTargetStore) AddTarget(ctx context.Context, target model.Target) (model.Target, error) {
+	if failingTargetStore.addsLeft == 0 {
+		return model.Target{}, errors.New("store unavailable")
 	}
+	failingTargetStore.addsLeft--
+	return failingTargetStore.InMemoryStore.AddTarget(ctx, target)
 }
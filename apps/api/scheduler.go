@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/schedule"
+)
+
+// schedulerTick is how often the local scheduler wakes up to look for due targets
+// it bounds how precisely per-target intervals are honoured
+const schedulerTick = time.Second
+
+// scheduler tracks when each target was last probed so that every target
+// runs on its own interval instead of one global tick
+type scheduler struct {
+	mutex   sync.Mutex
+	lastRun map[string]time.Time
+}
+
+// probeScheduler is the scheduler used by the background loop and by runCheck
+var probeScheduler = newScheduler()
+
+// newScheduler creates a scheduler with no probe history
+func newScheduler() *scheduler {
+	return &scheduler{
+		lastRun: make(map[string]time.Time),
+	}
+}
+
+// markRun records that a probe for the target started at the given time
+func (probeScheduler *scheduler) markRun(targetID string, startedAt time.Time) {
+	probeScheduler.mutex.Lock()
+	defer probeScheduler.mutex.Unlock()
+
+	probeScheduler.lastRun[targetID] = startedAt
+}
+
+// forget drops the probe history of a deleted target
+func (probeScheduler *scheduler) forget(targetID string) {
+	probeScheduler.mutex.Lock()
+	defer probeScheduler.mutex.Unlock()
+
+	delete(probeScheduler.lastRun, targetID)
+}
+
+// dueTargets returns the targets that should be probed now and marks them as run
+// marking them here (rather than when the probe finishes) keeps slow probes from being started twice
+func (probeScheduler *scheduler) dueTargets(targets []model.Target, now time.Time) []model.Target {
+	probeScheduler.mutex.Lock()
+	defer probeScheduler.mutex.Unlock()
+
+	var due []model.Target
+	for _, target := range targets {
+		if !schedule.Due(target, probeScheduler.lastRun[target.ID], now, 0) {
+			continue
+		}
+		probeScheduler.lastRun[target.ID] = now
+		due = append(due, target)
+	}
+	return due
+}
+
+// tick runs one scheduling cycle: probe every target whose interval has elapsed
+func (probeScheduler *scheduler) tick(ctx context.Context, now time.Time) {
+	// pull the latest set of targets from the store
+	targets, err := targetStore.ListTargets(ctx)
+	if err != nil {
+		log.Printf("scheduler: failed to list targets: %v\n", err)
+		return
+	}
+
+	// if no targets are due yet, nothing to do this tick
+	due := probeScheduler.dueTargets(targets, now)
+	if len(due) == 0 {
+		return
+	}
+
+	log.Printf("scheduler: running checks for %d targets\n", len(due))
+
+	// kick off one probe per target
+	// each check runs asynchronously in its own goroutine
+	// so targets don't block each other
+	//
+	// the scheduler loop does not wait for check completion
+	for _, target := range due {
+		go runCheck(targetStore, targetAlerter, target)
+	}
+}
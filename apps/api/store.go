@@ -1,12 +1,17 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/retention"
+	"github.com/sspier/cloudpulse/internal/rollup"
+	"github.com/sspier/cloudpulse/internal/store"
 )
 
 // InMemoryStore keeps all targets and probe results in memory
@@ -17,40 +22,54 @@ type InMemoryStore struct {
 	sequenceNumber int64 // sequence number for generating unique IDs
 	targets        map[string]model.Target
 	results        map[string][]model.Result
+	maintenance    map[string]model.MaintenanceWindow
+	slos           map[string]model.SLO
+	incidents      map[string]model.Incident
+	// latest is the newest result of each target by timestamp, like the latest result item of DynamoDB
+	latest map[string]model.Result
+	// rollups holds the aggregates of each target and step (see rollup.Key), oldest first
+	rollups map[string][]model.Rollup
 }
 
 // NewInMemoryStore sets up empty maps so the store is ready to use
 func NewInMemoryStore() *InMemoryStore {
 	// create the store
 	return &InMemoryStore{
-		targets: make(map[string]model.Target),
-		results: make(map[string][]model.Result),
+		targets:     make(map[string]model.Target),
+		results:     make(map[string][]model.Result),
+		latest:      make(map[string]model.Result),
+		maintenance: make(map[string]model.MaintenanceWindow),
+		slos:        make(map[string]model.SLO),
+		incidents:   make(map[string]model.Incident),
+		rollups:     make(map[string][]model.Rollup),
 	}
 }
 
-// AddTarget registers a new target and returns it
-func (inMemoryStore *InMemoryStore) AddTarget(ctx context.Context, name, url string) (model.Target, error) {
-	// store is protected by a mutex, so we need to lock it
-	inMemoryStore.rwMutex.Lock()
-	// unlock when we're done
-	defer inMemoryStore.rwMutex.Unlock()
-
+// nextID generates a unique ID using the current time and sequence number
+// callers must hold the write lock
+func (inMemoryStore *InMemoryStore) nextID() string {
 	// increment the sequence number
 	inMemoryStore.sequenceNumber++
 
-	// generate a unique ID for the target using the current time and sequence number
-	uniqueId := fmt.Sprintf(
+	return fmt.Sprintf(
 		"%s-%d",
 		time.Now().UTC().Format("20060102150405.000000000"),
 		inMemoryStore.sequenceNumber,
 	)
+}
 
-	// create the target
-	target := model.Target{
-		ID:   uniqueId,
-		Name: name,
-		URL:  url,
-	}
+// AddTarget registers a new target and returns it
+func (inMemoryStore *InMemoryStore) AddTarget(ctx context.Context, target model.Target) (model.Target, error) {
+	// store is protected by a mutex, so we need to lock it
+	inMemoryStore.rwMutex.Lock()
+	// unlock when we're done
+	defer inMemoryStore.rwMutex.Unlock()
+
+	// generate a unique ID for the target using the current time and sequence number
+	uniqueId := inMemoryStore.nextID()
+
+	// assign the ID to the target
+	target.ID = uniqueId
 
 	// add the target to the store
 	inMemoryStore.targets[uniqueId] = target
@@ -69,12 +88,84 @@ func (inMemoryStore *InMemoryStore) ListTargets(ctx context.Context) ([]model.Ta
 	return targets, nil
 }
 
+// GetTarget returns a single target by ID
+func (inMemoryStore *InMemoryStore) GetTarget(ctx context.Context, id string) (model.Target, error) {
+	inMemoryStore.rwMutex.RLock()
+	defer inMemoryStore.rwMutex.RUnlock()
+
+	target, ok := inMemoryStore.targets[id]
+	if !ok {
+		return model.Target{}, store.ErrNotFound
+	}
+	return target, nil
+}
+
+// UpdateTarget replaces an existing target
+func (inMemoryStore *InMemoryStore) UpdateTarget(ctx context.Context, target model.Target) (model.Target, error) {
+	inMemoryStore.rwMutex.Lock()
+	defer inMemoryStore.rwMutex.Unlock()
+
+	// only existing targets can be updated, creating goes through AddTarget so IDs stay store-generated
+	if _, ok := inMemoryStore.targets[target.ID]; !ok {
+		return model.Target{}, store.ErrNotFound
+	}
+	inMemoryStore.targets[target.ID] = target
+	return target, nil
+}
+
+// DeleteTarget removes a target and optionally its probe history
+func (inMemoryStore *InMemoryStore) DeleteTarget(ctx context.Context, id string, purgeResults bool) error {
+	inMemoryStore.rwMutex.Lock()
+	defer inMemoryStore.rwMutex.Unlock()
+
+	if _, ok := inMemoryStore.targets[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(inMemoryStore.targets, id)
+
+	if purgeResults {
+		delete(inMemoryStore.results, id)
+		delete(inMemoryStore.latest, id)
+		for _, step := range rollup.Steps {
+			delete(inMemoryStore.rollups, rollup.Key(id, step))
+		}
+	}
+	return nil
+}
+
 // AddResult appends a new probe result for a given target
 func (inMemoryStore *InMemoryStore) AddResult(ctx context.Context, result model.Result) error {
 	inMemoryStore.rwMutex.Lock()
 	defer inMemoryStore.rwMutex.Unlock()
 
-	inMemoryStore.results[result.TargetID] = append(inMemoryStore.results[result.TargetID], result)
+	// keep the history sorted by timestamp: a late result goes before newer ones, after any with the same timestamp
+	results := inMemoryStore.results[result.TargetID]
+	position := len(results)
+	for position > 0 && results[position-1].Timestamp > result.Timestamp {
+		position--
+	}
+	inMemoryStore.results[result.TargetID] = slices.Insert(results, position, result)
+	// a late result must not replace a newer one
+	if latest, ok := inMemoryStore.latest[result.TargetID]; !ok || latest.Timestamp <= result.Timestamp {
+		inMemoryStore.latest[result.TargetID] = result
+	}
+
+	for _, step := range rollup.Steps {
+		key := rollup.Key(result.TargetID, step)
+		bucket := rollup.FromResult(result, step)
+
+		// results arrive (roughly) in time order, so the bucket is usually the last one or a new one
+		rollups := inMemoryStore.rollups[key]
+		index, found := slices.BinarySearchFunc(rollups, bucket.Start, func(existing model.Rollup, start int64) int {
+			return cmp.Compare(existing.Start, start)
+		})
+		if found {
+			rollup.Merge(&rollups[index], bucket)
+		} else {
+			rollups = slices.Insert(rollups, index, bucket)
+		}
+		inMemoryStore.rollups[key] = rollups
+	}
 	return nil
 }
 
@@ -87,20 +178,21 @@ func (inMemoryStore *InMemoryStore) LatestResults(ctx context.Context) ([]model.
 
 	for id := range inMemoryStore.targets {
 		// get the latest result for this target
-		resultsForTarget := inMemoryStore.results[id]
-		if len(resultsForTarget) == 0 {
+		latestResult, ok := inMemoryStore.latest[id]
+		if !ok {
 			// no results for this target
 			continue
 		}
-		latestResult := resultsForTarget[len(resultsForTarget)-1]
 		latestResult.Name = inMemoryStore.targets[id].Name
+		latestResult.Paused = inMemoryStore.targets[id].Paused
+		latestResult.Labels = inMemoryStore.targets[id].Labels
 		latest = append(latest, latestResult)
 	}
 
 	return latest, nil
 }
 
-// ResultsForTarget returns the full probe history for a single target
+// ResultsForTarget returns the full probe history for a single target, oldest first
 func (inMemoryStore *InMemoryStore) ResultsForTarget(ctx context.Context, id string) ([]model.Result, error) {
 	// inMemoryStore is protected by a mutex, so we need to lock it
 	inMemoryStore.rwMutex.RLock()
@@ -121,3 +213,259 @@ func (inMemoryStore *InMemoryStore) ResultsForTarget(ctx context.Context, id str
 	// if the target doesn't exist, return an empty slice
 	return []model.Result{}, nil
 }
+
+// ResultsBetween returns the results of a target within a time range, oldest first
+func (inMemoryStore *InMemoryStore) ResultsBetween(ctx context.Context, id string, from, to time.Time) ([]model.Result, error) {
+	inMemoryStore.rwMutex.RLock()
+	defer inMemoryStore.rwMutex.RUnlock()
+
+	// AddResult keeps the results in time order
+	resultsInRange := []model.Result{}
+	for _, result := range inMemoryStore.results[id] {
+		if result.Timestamp >= from.Unix() && result.Timestamp <= to.Unix() {
+			resultsInRange = append(resultsInRange, result)
+		}
+	}
+	return resultsInRange, nil
+}
+
+// QueryResults returns one page of the probe history of a single target
+func (inMemoryStore *InMemoryStore) QueryResults(ctx context.Context, id string, query store.ResultQuery) (store.ResultPage, error) {
+	inMemoryStore.rwMutex.RLock()
+	defer inMemoryStore.rwMutex.RUnlock()
+
+	// PageResults copies the results it returns, so the page can't race with later writes
+	return store.PageResults(inMemoryStore.results[id], query)
+}
+
+// Rollups returns the aggregates of a target for one step within a time range, oldest first
+func (inMemoryStore *InMemoryStore) Rollups(ctx context.Context, id string, step time.Duration, from, to time.Time) ([]model.Rollup, error) {
+	inMemoryStore.rwMutex.RLock()
+	defer inMemoryStore.rwMutex.RUnlock()
+
+	rollupsInRange := []model.Rollup{}
+	for _, bucket := range inMemoryStore.rollups[rollup.Key(id, step)] {
+		if bucket.Start >= from.Unix() && bucket.Start <= to.Unix() {
+			// the histogram is shared with the stored bucket, copy it so later merges don't race with the caller
+			bucket.LatencyHistogram = slices.Clone(bucket.LatencyHistogram)
+			rollupsInRange = append(rollupsInRange, bucket)
+		}
+	}
+	return rollupsInRange, nil
+}
+
+// Compact drops the results the retention policy of their target no longer keeps and the expired rollups
+// without it a long running api would keep every probe result in memory
+func (inMemoryStore *InMemoryStore) Compact(ctx context.Context, defaults retention.Policy, now time.Time) (int, error) {
+	inMemoryStore.rwMutex.Lock()
+	defer inMemoryStore.rwMutex.Unlock()
+
+	deleted := 0
+	for id, results := range inMemoryStore.results {
+		// results of a target deleted without purge follow the global policy
+		expired := defaults.ForTarget(inMemoryStore.targets[id]).Expired(results, now)
+		if expired == 0 {
+			continue
+		}
+		deleted += expired
+		if expired == len(results) {
+			delete(inMemoryStore.results, id)
+			continue
+		}
+		// copy the rest, slicing would keep the dropped results in the backing array
+		inMemoryStore.results[id] = slices.Clone(results[expired:])
+	}
+
+	// every target that ever had a result has a latest result, so these are all the rollups
+	for id := range inMemoryStore.latest {
+		for _, step := range rollup.Steps {
+			key := rollup.Key(id, step)
+			rollups := inMemoryStore.rollups[key]
+			expired := 0
+			for expired < len(rollups) && !now.Before(rollup.Expiry(rollups[expired].Start, step)) {
+				expired++
+			}
+			if expired > 0 {
+				inMemoryStore.rollups[key] = slices.Clone(rollups[expired:])
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// AddMaintenanceWindow registers a new maintenance window and returns it
+func (inMemoryStore *InMemoryStore) AddMaintenanceWindow(ctx context.Context, window model.MaintenanceWindow) (model.MaintenanceWindow, error) {
+	inMemoryStore.rwMutex.Lock()
+	defer inMemoryStore.rwMutex.Unlock()
+
+	window.ID = inMemoryStore.nextID()
+	inMemoryStore.maintenance[window.ID] = window
+	return window, nil
+}
+
+// ListMaintenanceWindows returns all maintenance windows as a slice
+func (inMemoryStore *InMemoryStore) ListMaintenanceWindows(ctx context.Context) ([]model.MaintenanceWindow, error) {
+	inMemoryStore.rwMutex.RLock()
+	defer inMemoryStore.rwMutex.RUnlock()
+
+	windows := make([]model.MaintenanceWindow, 0, len(inMemoryStore.maintenance))
+	for _, window := range inMemoryStore.maintenance {
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// GetMaintenanceWindow returns a single maintenance window by ID
+func (inMemoryStore *InMemoryStore) GetMaintenanceWindow(ctx context.Context, id string) (model.MaintenanceWindow, error) {
+	inMemoryStore.rwMutex.RLock()
+	defer inMemoryStore.rwMutex.RUnlock()
+
+	window, ok := inMemoryStore.maintenance[id]
+	if !ok {
+		return model.MaintenanceWindow{}, store.ErrNotFound
+	}
+	return window, nil
+}
+
+// DeleteMaintenanceWindow removes a maintenance window
+func (inMemoryStore *InMemoryStore) DeleteMaintenanceWindow(ctx context.Context, id string) error {
+	inMemoryStore.rwMutex.Lock()
+	defer inMemoryStore.rwMutex.Unlock()
+
+	if _, ok := inMemoryStore.maintenance[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(inMemoryStore.maintenance, id)
+	return nil
+}
+
+// AddSLO registers a new SLO and returns it
+func (inMemoryStore *InMemoryStore) AddSLO(ctx context.Context, objective model.SLO) (model.SLO, error) {
+	inMemoryStore.rwMutex.Lock()
+	defer inMemoryStore.rwMutex.Unlock()
+
+	objective.ID = inMemoryStore.nextID()
+	inMemoryStore.slos[objective.ID] = objective
+	return objective, nil
+}
+
+// ListSLOs returns all SLOs as a slice
+func (inMemoryStore *InMemoryStore) ListSLOs(ctx context.Context) ([]model.SLO, error) {
+	inMemoryStore.rwMutex.RLock()
+	defer inMemoryStore.rwMutex.RUnlock()
+
+	objectives := make([]model.SLO, 0, len(inMemoryStore.slos))
+	for _, objective := range inMemoryStore.slos {
+		objectives = append(objectives, objective)
+	}
+	return objectives, nil
+}
+
+// GetSLO returns a single SLO by ID
+func (inMemoryStore *InMemoryStore) GetSLO(ctx context.Context, id string) (model.SLO, error) {
+	inMemoryStore.rwMutex.RLock()
+	defer inMemoryStore.rwMutex.RUnlock()
+
+	objective, ok := inMemoryStore.slos[id]
+	if !ok {
+		return model.SLO{}, store.ErrNotFound
+	}
+	return objective, nil
+}
+
+// DeleteSLO removes an SLO
+func (inMemoryStore *InMemoryStore) DeleteSLO(ctx context.Context, id string) error {
+	inMemoryStore.rwMutex.Lock()
+	defer inMemoryStore.rwMutex.Unlock()
+
+	if _, ok := inMemoryStore.slos[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(inMemoryStore.slos, id)
+	return nil
+}
+
+// AddIncident registers a new incident and returns it
+func (inMemoryStore *InMemoryStore) AddIncident(ctx context.Context, incident model.Incident) (model.Incident, error) {
+	inMemoryStore.rwMutex.Lock()
+	defer inMemoryStore.rwMutex.Unlock()
+
+	incident.ID = inMemoryStore.nextID()
+	inMemoryStore.incidents[incident.ID] = incident
+	return incident, nil
+}
+
+// ListIncidents returns all incidents, newest first
+func (inMemoryStore *InMemoryStore) ListIncidents(ctx context.Context) ([]model.Incident, error) {
+	return inMemoryStore.filterIncidents(func(model.Incident) bool { return true }), nil
+}
+
+// IncidentsForTarget returns the incidents of a single target, newest first
+func (inMemoryStore *InMemoryStore) IncidentsForTarget(ctx context.Context, targetID string) ([]model.Incident, error) {
+	return inMemoryStore.filterIncidents(func(incident model.Incident) bool { return incident.TargetID == targetID }), nil
+}
+
+// filterIncidents returns the incidents matching keep, newest first
+func (inMemoryStore *InMemoryStore) filterIncidents(keep func(model.Incident) bool) []model.Incident {
+	inMemoryStore.rwMutex.RLock()
+	defer inMemoryStore.rwMutex.RUnlock()
+
+	incidents := make([]model.Incident, 0)
+	for _, incident := range inMemoryStore.incidents {
+		if keep(incident) {
+			incidents = append(incidents, incident)
+		}
+	}
+	store.SortIncidents(incidents)
+	return incidents
+}
+
+// GetIncident returns a single incident by ID
+func (inMemoryStore *InMemoryStore) GetIncident(ctx context.Context, id string) (model.Incident, error) {
+	inMemoryStore.rwMutex.RLock()
+	defer inMemoryStore.rwMutex.RUnlock()
+
+	incident, ok := inMemoryStore.incidents[id]
+	if !ok {
+		return model.Incident{}, store.ErrNotFound
+	}
+	return incident, nil
+}
+
+// ResolveIncident marks an incident as resolved
+func (inMemoryStore *InMemoryStore) ResolveIncident(ctx context.Context, id string, endTime int64) (model.Incident, error) {
+	return inMemoryStore.updateIncident(id, func(incident *model.Incident) {
+		incident.Status = model.IncidentResolved
+		incident.EndTime = endTime
+	})
+}
+
+// AcknowledgeIncident records who acknowledged an incident and when
+func (inMemoryStore *InMemoryStore) AcknowledgeIncident(ctx context.Context, id, acknowledgedBy string, acknowledgedAt int64) (model.Incident, error) {
+	return inMemoryStore.updateIncident(id, func(incident *model.Incident) {
+		incident.AcknowledgedBy = acknowledgedBy
+		incident.AcknowledgedAt = acknowledgedAt
+	})
+}
+
+// AddIncidentNote appends a note to an incident
+func (inMemoryStore *InMemoryStore) AddIncidentNote(ctx context.Context, id string, note model.IncidentNote) (model.Incident, error) {
+	return inMemoryStore.updateIncident(id, func(incident *model.Incident) {
+		// copy the notes so incidents handed out earlier don't see the new one
+		incident.Notes = append(slices.Clone(incident.Notes), note)
+	})
+}
+
+// updateIncident applies a change to a stored incident under the write lock
+func (inMemoryStore *InMemoryStore) updateIncident(id string, update func(*model.Incident)) (model.Incident, error) {
+	inMemoryStore.rwMutex.Lock()
+	defer inMemoryStore.rwMutex.Unlock()
+
+	incident, ok := inMemoryStore.incidents[id]
+	if !ok {
+		return model.Incident{}, store.ErrNotFound
+	}
+	update(&incident)
+	inMemoryStore.incidents[id] = incident
+	return incident, nil
+}
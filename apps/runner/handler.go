@@ -4,25 +4,44 @@ import (
 	"context"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/sspier/cloudpulse/internal/alert"
+	"github.com/sspier/cloudpulse/internal/maintenance"
+	"github.com/sspier/cloudpulse/internal/metrics"
 	"github.com/sspier/cloudpulse/internal/model"
 	"github.com/sspier/cloudpulse/internal/probe"
+	"github.com/sspier/cloudpulse/internal/schedule"
 	"github.com/sspier/cloudpulse/internal/store"
+	"github.com/sspier/cloudpulse/internal/tracing"
 )
 
+// dueSlack absorbs scheduling jitter of the invoking schedule (EventBridge or the local poll loop)
+// probe timestamps have second precision, so without it a target could be skipped for a whole run
+const dueSlack = 2 * time.Second
+
 type Handler struct {
 	// used to persist targets and results
 	store store.Store
+	// notifies about targets going down and recovering, nil disables alerting
+	alerter *alert.Alerter
+	// ships the probe metrics after each run since nobody can scrape a lambda, nil disables it
+	exporter metrics.Exporter
 }
 
 // HandleRequest is the entry point for the handler
 // it is called by the AWS Lambda runtime
-// performs a batch job. When triggered, it fetches all targets, runs probes for them
+// performs a batch job. When triggered, it fetches all targets, runs probes for the ones
+// whose interval has elapsed
 //
 //	concurrently (using sync.WaitGroup), saves the results, and then exits.
 //
 // It does not listen for HTTP requests.
 func (handler *Handler) HandleRequest(ctx context.Context) (string, error) {
+	// one trace per run, with the store calls and a span per probe below it
+	ctx, span := tracing.Tracer().Start(ctx, "HandleRequest")
+	defer span.End()
+
 	// list all listOfTargets
 	listOfTargets, err := handler.store.ListTargets(ctx)
 
@@ -35,15 +54,46 @@ func (handler *Handler) HandleRequest(ctx context.Context) (string, error) {
 		return "no targets to probe", nil
 	}
 
-	log.Printf("starting probes for %d targets", len(listOfTargets))
+	// the runner is stateless between invocations, so the latest stored result
+	// tells us when each target was last probed
+	lastRun := make(map[string]time.Time)
+	latestResults, err := handler.store.LatestResults(ctx)
+	if err != nil {
+		// better to probe too often than to stop monitoring
+		log.Printf("failed to load latest results, probing all targets: %v", err)
+	}
+	for _, result := range latestResults {
+		lastRun[result.TargetID] = time.Unix(result.Timestamp, 0)
+	}
+
+	// only probe the targets whose own interval has elapsed
+	now := time.Now()
+	var dueTargets []model.Target
+	for _, target := range listOfTargets {
+		if schedule.Due(target, lastRun[target.ID], now, dueSlack) {
+			dueTargets = append(dueTargets, target)
+		}
+	}
+	if len(dueTargets) == 0 {
+		return "no targets due", nil
+	}
+
+	log.Printf("starting probes for %d of %d targets", len(dueTargets), len(listOfTargets))
+
+	// load the maintenance windows once for the whole batch
+	windows, err := handler.store.ListMaintenanceWindows(ctx)
+	if err != nil {
+		// still probe, the results just won't be marked as maintenance
+		log.Printf("failed to list maintenance windows: %v", err)
+	}
 
 	// wait group to wait for all probes to complete
 	// - basically a counter + latch that blocks until all registered goroutines signal they are done
 	// - used to coordinate concurrent tasks in Go
 	var waitGroup sync.WaitGroup
 
-	// run probes for each target
-	for _, target := range listOfTargets {
+	// run probes for each due target
+	for _, target := range dueTargets {
 		// add to wait group
 		waitGroup.Add(1)
 		// run probe in a goroutine
@@ -52,7 +102,11 @@ func (handler *Handler) HandleRequest(ctx context.Context) (string, error) {
 			defer waitGroup.Done()
 			// run probe and store result
 			result := probe.Check(ctx, target)
-			if err := handler.store.AddResult(ctx, result); err != nil {
+			// probes during a maintenance window are kept, but recorded with the maintenance status
+			maintenance.Apply(windows, target, &result)
+			metrics.ObserveResult(target, result)
+			// store the result, the alerter notifies when it changes the target's state
+			if err := handler.alerter.RecordResult(ctx, handler.store, target, result); err != nil {
 				log.Printf("failed to store result for %s: %v", target.ID, err)
 			}
 		}(target)
@@ -60,5 +114,18 @@ func (handler *Handler) HandleRequest(ctx context.Context) (string, error) {
 
 	// wait for all probes to complete
 	waitGroup.Wait()
+
+	// export the metrics of the targets probed in this run
+	// a failed export is logged, the results are stored already and the next run exports again
+	if handler.exporter != nil {
+		targetIDs := make([]string, 0, len(dueTargets))
+		for _, target := range dueTargets {
+			targetIDs = append(targetIDs, target.ID)
+		}
+		if err := handler.exporter.Export(ctx, targetIDs); err != nil {
+			log.Printf("failed to export metrics: %v", err)
+		}
+	}
+
 	return "probes completed", nil
 }
@@ -7,11 +7,25 @@ import (
 	"time"
 
 	awsLambda "github.com/aws/aws-lambda-go/lambda"
+	"github.com/sspier/cloudpulse/internal/alert"
+	"github.com/sspier/cloudpulse/internal/metrics"
+	"github.com/sspier/cloudpulse/internal/retention"
 	"github.com/sspier/cloudpulse/internal/store"
+	"github.com/sspier/cloudpulse/internal/tracing"
 )
 
+// pollInterval is how often the local poll loop wakes up, it is the shortest interval a target can effectively use
+// in lambda mode the EventBridge schedule plays this role instead
+const pollInterval = 5 * time.Second
+
 // bootstraps the Lambda environment and starts the handler to write data and run probes
 func main() {
+	// traces go to the OTLP endpoint from OTEL_EXPORTER_OTLP_ENDPOINT, without it spans are dropped
+	flushTraces, err := tracing.Setup(context.Background(), "cloudpulse-runner")
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+
 	// initialize store based on environment
 	awsRegion := os.Getenv("AWS_REGION")
 	if awsRegion == "" {
@@ -25,19 +39,56 @@ func main() {
 		log.Fatal("TABLE_NAME_TARGETS and TABLE_NAME_RESULTS must be set")
 	}
 
+	// the runner writes the results, so the ttl it sets decides how long they are kept
+	resultRetention, err := retention.FromEnv()
+	if err != nil {
+		log.Fatalf("invalid retention configuration: %v", err)
+	}
+
 	// use context.Background() for init, but handler will provide its own context
-	dynamoDBStore, err := store.NewDynamoDBStore(context.Background(), awsRegion, targetsTable, resultsTable)
+	// optional tables, features backed by a missing table are disabled
+	storeOptions := []store.DynamoDBOption{store.WithRetention(resultRetention)}
+	if maintenanceTable := os.Getenv("TABLE_NAME_MAINTENANCE"); maintenanceTable != "" {
+		storeOptions = append(storeOptions, store.WithMaintenanceTable(maintenanceTable))
+	}
+	incidentsTable := os.Getenv("TABLE_NAME_INCIDENTS")
+	if incidentsTable != "" {
+		storeOptions = append(storeOptions, store.WithIncidentsTable(incidentsTable))
+	}
+
+	dynamoDBStore, err := store.NewDynamoDBStore(context.Background(), awsRegion, targetsTable, resultsTable, storeOptions...)
 	if err != nil {
 		log.Fatalf("failed to initialize store: %v", err)
 	}
 
+	alerter, err := alert.FromEnv()
+	if err != nil {
+		log.Fatalf("invalid alerting configuration: %v", err)
+	}
+	// incidents are opened and resolved through the same transitions as notifications
+	if incidentsTable != "" {
+		alerter.Notifiers = append(alerter.Notifiers, &alert.IncidentRecorder{Store: dynamoDBStore})
+	}
+
+	// optional pushgateway or remote-write export of the probe metrics
+	exporter, err := metrics.ExporterFromEnv()
+	if err != nil {
+		log.Fatalf("invalid metrics export configuration: %v", err)
+	}
+
 	handler := &Handler{
-		store: dynamoDBStore,
+		store:    dynamoDBStore,
+		alerter:  alerter,
+		exporter: exporter,
 	}
 
 	// check if running in Lambda
 	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
-		awsLambda.Start(handler.HandleRequest)
+		// the lambda is frozen between invocations, so spans have to be flushed before returning
+		awsLambda.Start(func(ctx context.Context) (string, error) {
+			defer flushTraces(ctx)
+			return handler.HandleRequest(ctx)
+		})
 	} else {
 		log.Println("running in local mode (poll loop)")
 		// run once immediately
@@ -45,7 +96,8 @@ func main() {
 			log.Printf("initial check failed: %v", err)
 		}
 
-		ticker := time.NewTicker(30 * time.Second)
+		// poll often and let each target's interval decide whether it is probed
+		ticker := time.NewTicker(pollInterval)
 		defer ticker.Stop()
 
 		for range ticker.C {
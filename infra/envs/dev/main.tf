@@ -79,6 +79,31 @@ module "dynamodb_targets" {
   tags              = local.tags
 }
 
+# tables of the optional features, without them the api answers 501 for maintenance, slos and incidents
+module "dynamodb_maintenance" {
+  source = "../../modules/dynamodb_items"
+
+  env               = local.env
+  table_name_prefix = "cloudpulse-maintenance"
+  tags              = local.tags
+}
+
+module "dynamodb_slos" {
+  source = "../../modules/dynamodb_items"
+
+  env               = local.env
+  table_name_prefix = "cloudpulse-slos"
+  tags              = local.tags
+}
+
+module "dynamodb_incidents" {
+  source = "../../modules/dynamodb_items"
+
+  env               = local.env
+  table_name_prefix = "cloudpulse-incidents"
+  tags              = local.tags
+}
+
 # ecs service running the cloudpulse api
 # deploys an ecs cluster, task definition, load balancer, etc
 module "ecs_api" {
@@ -93,8 +118,11 @@ module "ecs_api" {
   desired_count   = 1
   region          = data.aws_region.current.name
 
-  table_name_targets = module.dynamodb_targets.table_name
-  table_name_results = module.dynamodb_results.table_name
+  table_name_targets     = module.dynamodb_targets.table_name
+  table_name_results     = module.dynamodb_results.table_name
+  table_name_maintenance = module.dynamodb_maintenance.table_name
+  table_name_slos        = module.dynamodb_slos.table_name
+  table_name_incidents   = module.dynamodb_incidents.table_name
 
   tags = local.tags
 }
@@ -108,8 +136,10 @@ module "runner" {
   schedule_expression = "rate(1 minute)" # how often the runner should probe targets
   runner_image        = local.runner_image
 
-  table_name_targets = module.dynamodb_targets.table_name
-  table_name_results = module.dynamodb_results.table_name
+  table_name_targets     = module.dynamodb_targets.table_name
+  table_name_results     = module.dynamodb_results.table_name
+  table_name_maintenance = module.dynamodb_maintenance.table_name
+  table_name_incidents   = module.dynamodb_incidents.table_name
 
   tags = local.tags
 }
@@ -40,8 +40,11 @@ module "ecs_api" {
   region          = data.aws_region.current.name
 
   # environment variables for table access
-  table_name_targets = module.targets_table.table_name
-  table_name_results = module.results_table.table_name
+  table_name_targets     = module.targets_table.table_name
+  table_name_results     = module.results_table.table_name
+  table_name_maintenance = module.maintenance_table.table_name
+  table_name_slos        = module.slos_table.table_name
+  table_name_incidents   = module.incidents_table.table_name
 
   tags = {
     Project = "cloudpulse"
@@ -92,6 +95,44 @@ module "results_table" {
   }
 }
 
+# production dynamodb tables of the optional features
+# without them the api answers 501 for maintenance windows, slos and incidents
+module "maintenance_table" {
+  source = "../../modules/dynamodb_items"
+
+  table_name_prefix = "cloudpulse-maintenance"
+  env               = "prod"
+
+  tags = {
+    Project = "cloudpulse"
+    Env     = "prod"
+  }
+}
+
+module "slos_table" {
+  source = "../../modules/dynamodb_items"
+
+  table_name_prefix = "cloudpulse-slos"
+  env               = "prod"
+
+  tags = {
+    Project = "cloudpulse"
+    Env     = "prod"
+  }
+}
+
+module "incidents_table" {
+  source = "../../modules/dynamodb_items"
+
+  table_name_prefix = "cloudpulse-incidents"
+  env               = "prod"
+
+  tags = {
+    Project = "cloudpulse"
+    Env     = "prod"
+  }
+}
+
 # production runner lambda for probing targets on a schedule
 # prod probes less frequently than dev for cost and stability
 module "runner" {
@@ -103,8 +144,10 @@ module "runner" {
   runner_image = "123456789012.dkr.ecr.us-east-1.amazonaws.com/cloudpulse-runner:prod"
 
   # pass table names to the lambda environment
-  table_name_targets = module.targets_table.table_name
-  table_name_results = module.results_table.table_name
+  table_name_targets     = module.targets_table.table_name
+  table_name_results     = module.results_table.table_name
+  table_name_maintenance = module.maintenance_table.table_name
+  table_name_incidents   = module.incidents_table.table_name
 
   tags = {
     Project = "cloudpulse"
@@ -0,0 +1,24 @@
+locals {
+  table_name = "${var.table_name_prefix}-${var.env}"
+}
+
+# dynamodb table for a simple entity keyed by id (maintenance windows, slos, incidents)
+# the api lists these with a scan, so there is no sort key or index
+resource "aws_dynamodb_table" "items" {
+  name         = local.table_name
+  billing_mode = "PAY_PER_REQUEST"
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  tags = merge(
+    var.tags,
+    {
+      Name = local.table_name
+    }
+  )
+}
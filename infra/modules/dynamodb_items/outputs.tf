@@ -0,0 +1,9 @@
+output "table_name" {
+  description = "Name of the created DynamoDB table"
+  value       = aws_dynamodb_table.items.name
+}
+
+output "table_arn" {
+  description = "ARN of the created DynamoDB table"
+  value       = aws_dynamodb_table.items.arn
+}
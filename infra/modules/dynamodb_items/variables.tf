@@ -0,0 +1,15 @@
+variable "env" {
+  description = "Environment name (e.g. dev, prod)"
+  type        = string
+}
+
+variable "table_name_prefix" {
+  description = "Prefix for the dynamodb table name (e.g. cloudpulse-maintenance)"
+  type        = string
+}
+
+variable "tags" {
+  description = "Tags to apply to resources"
+  type        = map(string)
+  default     = {}
+}
@@ -205,6 +205,8 @@ resource "aws_iam_role_policy" "dynamodb_access" {
         Action = [
           "dynamodb:PutItem",
           "dynamodb:GetItem",
+          "dynamodb:DeleteItem",
+          "dynamodb:UpdateItem",
           "dynamodb:Query",
           "dynamodb:Scan",
           "dynamodb:BatchWriteItem",
@@ -213,7 +215,10 @@ resource "aws_iam_role_policy" "dynamodb_access" {
         Resource = [
           "arn:aws:dynamodb:*:*:table/${var.table_name_targets}",
           "arn:aws:dynamodb:*:*:table/${var.table_name_results}",
-          "arn:aws:dynamodb:*:*:table/${var.table_name_results}/index/*"
+          "arn:aws:dynamodb:*:*:table/${var.table_name_results}/index/*",
+          "arn:aws:dynamodb:*:*:table/${var.table_name_maintenance}",
+          "arn:aws:dynamodb:*:*:table/${var.table_name_slos}",
+          "arn:aws:dynamodb:*:*:table/${var.table_name_incidents}"
         ]
       }
     ]
@@ -254,6 +259,18 @@ resource "aws_ecs_task_definition" "this" {
         {
           name  = "TABLE_NAME_RESULTS"
           value = var.table_name_results
+        },
+        {
+          name  = "TABLE_NAME_MAINTENANCE"
+          value = var.table_name_maintenance
+        },
+        {
+          name  = "TABLE_NAME_SLOS"
+          value = var.table_name_slos
+        },
+        {
+          name  = "TABLE_NAME_INCIDENTS"
+          value = var.table_name_incidents
         }
       ]
       logConfiguration = {
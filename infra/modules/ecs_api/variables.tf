@@ -46,6 +46,21 @@ variable "table_name_results" {
   type        = string
 }
 
+variable "table_name_maintenance" {
+  description = "Name of the maintenance windows DynamoDB table"
+  type        = string
+}
+
+variable "table_name_slos" {
+  description = "Name of the SLOs DynamoDB table"
+  type        = string
+}
+
+variable "table_name_incidents" {
+  description = "Name of the incidents DynamoDB table"
+  type        = string
+}
+
 variable "tags" {
   description = "Base tags to apply to ECS and ALB resources"
   type        = map(string)
@@ -47,6 +47,8 @@ resource "aws_iam_role_policy" "dynamodb_access" {
         Action = [
           "dynamodb:PutItem",
           "dynamodb:GetItem",
+          "dynamodb:DeleteItem",
+          "dynamodb:UpdateItem",
           "dynamodb:Query",
           "dynamodb:Scan",
           "dynamodb:BatchWriteItem",
@@ -55,7 +57,9 @@ resource "aws_iam_role_policy" "dynamodb_access" {
         Resource = [
           "arn:aws:dynamodb:*:*:table/${var.table_name_targets}",
           "arn:aws:dynamodb:*:*:table/${var.table_name_results}",
-          "arn:aws:dynamodb:*:*:table/${var.table_name_results}/index/*"
+          "arn:aws:dynamodb:*:*:table/${var.table_name_results}/index/*",
+          "arn:aws:dynamodb:*:*:table/${var.table_name_maintenance}",
+          "arn:aws:dynamodb:*:*:table/${var.table_name_incidents}"
         ]
       }
     ]
@@ -71,14 +75,16 @@ resource "aws_lambda_function" "runner" {
   package_type = "Image" # use container image instead of zip
   image_uri    = var.runner_image
 
-  timeout     = 30  # enough time to probe multiple targets
+  timeout     = 90  # probes run concurrently and the api caps each at 60s, retries included
   memory_size = 256 # modest memory footprint
 
   environment {
     variables = {
-      ENV                = var.env
-      TABLE_NAME_TARGETS = var.table_name_targets
-      TABLE_NAME_RESULTS = var.table_name_results
+      ENV                    = var.env
+      TABLE_NAME_TARGETS     = var.table_name_targets
+      TABLE_NAME_RESULTS     = var.table_name_results
+      TABLE_NAME_MAINTENANCE = var.table_name_maintenance
+      TABLE_NAME_INCIDENTS   = var.table_name_incidents
     }
   }
 
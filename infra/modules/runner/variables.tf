@@ -24,6 +24,16 @@ variable "table_name_results" {
   type        = string
 }
 
+variable "table_name_maintenance" {
+  description = "Name of the maintenance windows DynamoDB table"
+  type        = string
+}
+
+variable "table_name_incidents" {
+  description = "Name of the incidents DynamoDB table"
+  type        = string
+}
+
 variable "tags" {
   description = "Base tags to apply to runner resources"
   type        = map(string)
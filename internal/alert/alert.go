@@ -0,0 +1,242 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/slo"
+	"github.com/sspier/cloudpulse/internal/store"
+)
+
+// event kinds
+const (
+	// EventDown fires when a target has failed FailureThreshold probes in a row
+	EventDown = "down"
+	// EventRecovered fires on the first good probe after a down event
+	EventRecovered = "recovered"
+)
+
+// DefaultFailureThreshold is how many consecutive failed probes it takes to report a target down
+const DefaultFailureThreshold = 1
+
+// MaxFailureThreshold bounds the threshold so the recent history we look at always covers it
+const MaxFailureThreshold = 50
+
+// Event is a state transition of a target, it is what notifiers receive
+type Event struct {
+	Kind       string `json:"kind"`
+	TargetID   string `json:"targetId"`
+	TargetName string `json:"targetName"`
+	URL        string `json:"url"`
+
+	// ConsecutiveFailures is the length of the failure streak, for recoveries it is the length of the outage
+	// very long outages are only counted as far back as the recent history goes
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// DownSince is the timestamp of the first failed probe of the streak
+	DownSince int64 `json:"downSince"`
+	// Timestamp is the timestamp of the probe that caused the transition
+	Timestamp int64 `json:"timestamp"`
+
+	// details of the probe that caused the transition
+	Status          string `json:"status"`
+	HTTPStatus      int    `json:"httpStatus,omitempty"`
+	Error           string `json:"error,omitempty"`
+	FailedAssertion string `json:"failedAssertion,omitempty"`
+}
+
+// Summary renders the event as a single line for chat messages and email subjects
+func (event Event) Summary() string {
+	name := event.TargetName
+	if name == "" {
+		name = event.TargetID
+	}
+
+	if event.Kind == EventRecovered {
+		downtime := time.Duration(event.Timestamp-event.DownSince) * time.Second
+		return fmt.Sprintf("%s recovered after %d failed probes (down for %s)", name, event.ConsecutiveFailures, downtime)
+	}
+
+	reason := event.FailedAssertion
+	if event.Error != "" {
+		reason = event.Error
+	}
+	return fmt.Sprintf("%s is DOWN after %d failed probes: %s", name, event.ConsecutiveFailures, reason)
+}
+
+// Detect works out whether the current result changes the state of the target
+// previous is the target's recent history without the current result, in any order
+//
+// detection is stateless so that the api scheduler and the (stateless) runner behave the same:
+// a target is down once its trailing streak of failed probes reaches the threshold,
+// and recovers on the first good probe after such a streak
+// probes taken during maintenance neither count nor break a streak
+func Detect(target model.Target, previous []model.Result, current model.Result, threshold int) (Event, bool) {
+	if current.Status == model.StatusMaintenance {
+		return Event{}, false
+	}
+	if threshold < 1 {
+		threshold = DefaultFailureThreshold
+	}
+
+	history := make([]model.Result, 0, len(previous))
+	for _, result := range previous {
+		if result.Status != model.StatusMaintenance {
+			history = append(history, result)
+		}
+	}
+	sort.SliceStable(history, func(i, j int) bool { return history[i].Timestamp < history[j].Timestamp })
+
+	// length of the failure streak right before the current probe
+	streak := 0
+	for index := len(history) - 1; index >= 0 && !slo.IsGood(history[index]); index-- {
+		streak++
+	}
+
+	event := Event{
+		TargetID:        target.ID,
+		TargetName:      target.Name,
+		URL:             target.URL,
+		Timestamp:       current.Timestamp,
+		Status:          current.Status,
+		HTTPStatus:      current.HTTPStatus,
+		Error:           current.Error,
+		FailedAssertion: current.FailedAssertion,
+	}
+
+	if slo.IsGood(current) {
+		if streak < threshold {
+			return Event{}, false
+		}
+		event.Kind = EventRecovered
+		event.ConsecutiveFailures = streak
+		event.DownSince = history[len(history)-streak].Timestamp
+		return event, true
+	}
+
+	// only the probe that reaches the threshold fires, later failures are part of the same outage
+	if streak+1 != threshold {
+		return Event{}, false
+	}
+	event.Kind = EventDown
+	event.ConsecutiveFailures = threshold
+	event.DownSince = current.Timestamp
+	if streak > 0 {
+		event.DownSince = history[len(history)-streak].Timestamp
+	}
+	return event, true
+}
+
+// Notifier delivers events to people or other systems
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Alerter detects state transitions as results are recorded and hands them to its notifiers
+// a nil Alerter, or one without notifiers, only records results
+type Alerter struct {
+	FailureThreshold int
+	Notifiers        []Notifier
+}
+
+// Enabled reports whether there is anyone to notify
+func (alerter *Alerter) Enabled() bool {
+	return alerter != nil && len(alerter.Notifiers) > 0
+}
+
+// RecordResult stores the result and notifies about the transition it causes, if any
+// only a failure to store the result is returned, notification problems are logged so they never cost a result
+// the transition is detected even when storing fails: Detect only fires on the result that crosses the threshold,
+// so skipping it would lose the alert and the incident for good
+func (alerter *Alerter) RecordResult(ctx context.Context, resultStore store.ResultStore, target model.Target, result model.Result) error {
+	if !alerter.Enabled() {
+		return store.AddResult(ctx, resultStore, target, result)
+	}
+
+	// the history has to be read before the new result is in it
+	previous, historyErr := recentHistory(ctx, resultStore, target.ID, result, alerter.FailureThreshold)
+
+	storeErr := store.AddResult(ctx, resultStore, target, result)
+
+	// without the history we can't tell a new outage from an ongoing one, so stay quiet rather than spam
+	if historyErr != nil {
+		log.Printf("failed to load history of %s, skipping alerting: %v", target.ID, historyErr)
+		return storeErr
+	}
+
+	event, ok := Detect(target, previous, result, alerter.FailureThreshold)
+	if !ok {
+		return storeErr
+	}
+	if err := alerter.notify(ctx, event); err != nil {
+		log.Printf("failed to deliver %s alert for %s: %v", event.Kind, target.ID, err)
+	}
+	return storeErr
+}
+
+// maxHistory bounds how far back recentHistory reads, an outage longer than that is reported as that long
+const maxHistory = store.MaxResultLimit
+
+// recentHistory reads the part of a target's history that Detect needs to classify current, oldest first
+// it pages newest first and stops at the first good result, which ends the streak,
+// or, while the target is failing, once threshold failures are counted, more can't change the outcome
+// a healthy target therefore costs a single small query per probe
+func recentHistory(ctx context.Context, resultStore store.ResultStore, targetID string, current model.Result, threshold int) ([]model.Result, error) {
+	if current.Status == model.StatusMaintenance {
+		return nil, nil
+	}
+	if threshold < 1 {
+		threshold = DefaultFailureThreshold
+	}
+
+	// threshold+1 results settle both cases in one query unless maintenance probes are in the way
+	var history []model.Result
+	failures := 0
+	query := store.ResultQuery{Limit: threshold + 1, Descending: true}
+pages:
+	for len(history) < maxHistory {
+		page, err := resultStore.QueryResults(ctx, targetID, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range page.Results {
+			history = append(history, result)
+			if result.Status == model.StatusMaintenance {
+				continue
+			}
+			if slo.IsGood(result) {
+				break pages
+			}
+			failures++
+			if !slo.IsGood(current) && failures >= threshold {
+				break pages
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		// a streak this long is probably an outage that is about to recover, read the rest of it in fewer queries
+		query.Cursor = page.NextCursor
+		query.Limit = min(query.Limit*2, store.MaxResultLimit)
+	}
+
+	// oldest first, Detect's stable sort then keeps results with the same timestamp in the order they were added
+	slices.Reverse(history)
+	return history, nil
+}
+
+// notify hands the event to every notifier, one failing notifier doesn't stop the others
+func (alerter *Alerter) notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, notifier := range alerter.Notifiers {
+		if err := notifier.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,60 @@
+package alert
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FromEnv builds the alerter from the environment, shared by the api and the runner
+//
+//	ALERT_FAILURE_THRESHOLD   consecutive failed probes before a target is reported down (default 1)
+//	ALERT_WEBHOOK_URL         generic webhook, receives the event as json
+//	ALERT_SLACK_WEBHOOK_URL   Slack-compatible incoming webhook
+//	ALERT_SMTP_ADDR           host:port of the SMTP server, together with ALERT_EMAIL_FROM and ALERT_EMAIL_TO
+//	ALERT_SMTP_USERNAME       optional SMTP credentials
+//	ALERT_SMTP_PASSWORD
+//	ALERT_EMAIL_FROM
+//	ALERT_EMAIL_TO            comma separated recipients
+//
+// without any notifier configured the returned alerter is disabled
+func FromEnv() (*Alerter, error) {
+	alerter := &Alerter{FailureThreshold: DefaultFailureThreshold}
+
+	if thresholdText := os.Getenv("ALERT_FAILURE_THRESHOLD"); thresholdText != "" {
+		threshold, err := strconv.Atoi(thresholdText)
+		if err != nil || threshold < 1 || threshold > MaxFailureThreshold {
+			return nil, fmt.Errorf("ALERT_FAILURE_THRESHOLD must be between 1 and %d", MaxFailureThreshold)
+		}
+		alerter.FailureThreshold = threshold
+	}
+
+	if webhookURL := os.Getenv("ALERT_WEBHOOK_URL"); webhookURL != "" {
+		alerter.Notifiers = append(alerter.Notifiers, &WebhookNotifier{URL: webhookURL})
+	}
+
+	if slackWebhookURL := os.Getenv("ALERT_SLACK_WEBHOOK_URL"); slackWebhookURL != "" {
+		alerter.Notifiers = append(alerter.Notifiers, &SlackNotifier{WebhookURL: slackWebhookURL})
+	}
+
+	if smtpAddr := os.Getenv("ALERT_SMTP_ADDR"); smtpAddr != "" {
+		emailNotifier := &EmailNotifier{
+			Addr:     smtpAddr,
+			Username: os.Getenv("ALERT_SMTP_USERNAME"),
+			Password: os.Getenv("ALERT_SMTP_PASSWORD"),
+			From:     os.Getenv("ALERT_EMAIL_FROM"),
+		}
+		for _, recipient := range strings.Split(os.Getenv("ALERT_EMAIL_TO"), ",") {
+			if recipient = strings.TrimSpace(recipient); recipient != "" {
+				emailNotifier.To = append(emailNotifier.To, recipient)
+			}
+		}
+		if emailNotifier.From == "" || len(emailNotifier.To) == 0 {
+			return nil, fmt.Errorf("ALERT_SMTP_ADDR requires ALERT_EMAIL_FROM and ALERT_EMAIL_TO")
+		}
+		alerter.Notifiers = append(alerter.Notifiers, emailNotifier)
+	}
+
+	return alerter, nil
+}
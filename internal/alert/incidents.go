@@ -0,0 +1,50 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/store"
+)
+
+// IncidentRecorder is a notifier that turns transitions into incidents
+// a down event opens an incident, the following recovery resolves it
+type IncidentRecorder struct {
+	Store store.IncidentStore
+}
+
+// Notify opens or resolves the target's incident
+func (incidentRecorder *IncidentRecorder) Notify(ctx context.Context, event Event) error {
+	if event.Kind == EventDown {
+		_, err := incidentRecorder.Store.AddIncident(ctx, model.Incident{
+			TargetID:        event.TargetID,
+			Status:          model.IncidentOpen,
+			StartTime:       event.DownSince,
+			HTTPStatus:      event.HTTPStatus,
+			Error:           event.Error,
+			FailedAssertion: event.FailedAssertion,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to open incident: %w", err)
+		}
+		return nil
+	}
+
+	incidents, err := incidentRecorder.Store.IncidentsForTarget(ctx, event.TargetID)
+	if err != nil {
+		return fmt.Errorf("failed to load incidents: %w", err)
+	}
+
+	// resolve whatever is open, normally exactly one incident
+	// there is none if recording started in the middle of the outage
+	for _, incident := range incidents {
+		if incident.Status != model.IncidentOpen {
+			continue
+		}
+		if _, err := incidentRecorder.Store.ResolveIncident(ctx, incident.ID, event.Timestamp); err != nil {
+			return fmt.Errorf("failed to resolve incident %s: %w", incident.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// notifyTimeout bounds a single delivery, the runner has to finish its batch within the lambda timeout
+const notifyTimeout = 10 * time.Second
+
+// WebhookNotifier posts the event as json to a url
+type WebhookNotifier struct {
+	URL string
+	// Client is used to send the request, nil means a client with notifyTimeout
+	Client *http.Client
+}
+
+// Notify posts the event
+func (webhookNotifier *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	return postJSON(ctx, webhookNotifier.Client, webhookNotifier.URL, event)
+}
+
+// SlackNotifier posts a message to a Slack incoming webhook
+// any service that accepts Slack's {"text": "..."} payload works too (Mattermost, Rocket.Chat, ...)
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// Notify posts the event summary as a chat message
+func (slackNotifier *SlackNotifier) Notify(ctx context.Context, event Event) error {
+	icon := ":red_circle:"
+	if event.Kind == EventRecovered {
+		icon = ":large_green_circle:"
+	}
+	message := map[string]string{
+		"text": fmt.Sprintf("%s %s (%s)", icon, event.Summary(), event.URL),
+	}
+	return postJSON(ctx, slackNotifier.Client, slackNotifier.WebhookURL, message)
+}
+
+// postJSON posts a json payload and treats any non-2xx answer as an error
+func postJSON(ctx context.Context, client *http.Client, url string, payload any) error {
+	if client == nil {
+		client = &http.Client{Timeout: notifyTimeout}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	httpResponse, err := client.Do(httpRequest)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer httpResponse.Body.Close()
+	// drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(httpResponse.Body, 64<<10))
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %d", httpResponse.StatusCode)
+	}
+	return nil
+}
+
+// EmailNotifier sends a plain text email through an SMTP server
+type EmailNotifier struct {
+	// Addr is the host:port of the SMTP server, STARTTLS is used when the server offers it
+	Addr string
+	// Username and Password enable PLAIN auth, leave them empty for unauthenticated relays
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Notify sends the event as an email
+// net/smtp has no context support, the server address is expected to be local or close by
+func (emailNotifier *EmailNotifier) Notify(_ context.Context, event Event) error {
+	var auth smtp.Auth
+	if emailNotifier.Username != "" {
+		host, _, err := net.SplitHostPort(emailNotifier.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address: %w", err)
+		}
+		auth = smtp.PlainAuth("", emailNotifier.Username, emailNotifier.Password, host)
+	}
+
+	if err := smtp.SendMail(emailNotifier.Addr, auth, emailNotifier.From, emailNotifier.To, emailNotifier.message(event)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// message renders the event as an RFC 5322 message
+func (emailNotifier *EmailNotifier) message(event Event) []byte {
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", emailNotifier.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(emailNotifier.To, ", "))
+	fmt.Fprintf(&message, "Subject: [CloudPulse] %s\r\n", event.Summary())
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&message, "%s\r\n\r\n", event.Summary())
+	fmt.Fprintf(&message, "Target: %s (%s)\r\n", event.TargetName, event.TargetID)
+	fmt.Fprintf(&message, "URL: %s\r\n", event.URL)
+	fmt.Fprintf(&message, "Status: %s\r\n", event.Status)
+	if event.HTTPStatus != 0 {
+		fmt.Fprintf(&message, "HTTP status: %d\r\n", event.HTTPStatus)
+	}
+	if event.Error != "" {
+		fmt.Fprintf(&message, "Error: %s\r\n", event.Error)
+	}
+	if event.FailedAssertion != "" {
+		fmt.Fprintf(&message, "Failed assertion: %s\r\n", event.FailedAssertion)
+	}
+	fmt.Fprintf(&message, "Down since: %s\r\n", time.Unix(event.DownSince, 0).UTC().Format(time.RFC3339))
+	return []byte(message.String())
+}
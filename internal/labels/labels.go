@@ -0,0 +1,167 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// limits on target labels, they end up in urls and dashboards so they are kept short
+const (
+	MaxLabels      = 20
+	MaxKeyLength   = 63
+	MaxValueLength = 63
+)
+
+var (
+	// keys look like "team", "env" or "example.com/owner"
+	keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-/]*$`)
+	// values can't contain "," or "=" so that a selector stays unambiguous
+	valuePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-/:]*$`)
+)
+
+// Validate checks the labels of a target
+func Validate(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("at most %d labels are allowed", MaxLabels)
+	}
+	for key, value := range labels {
+		if len(key) > MaxKeyLength || !keyPattern.MatchString(key) {
+			return fmt.Errorf("invalid label key %q", key)
+		}
+		if len(value) > MaxValueLength || !valuePattern.MatchString(value) {
+			return fmt.Errorf("invalid value %q for label %q", value, key)
+		}
+	}
+	return nil
+}
+
+// requirement is one comma separated term of a selector
+type requirement struct {
+	key      string
+	value    string
+	operator string
+}
+
+// selector operators
+const (
+	operatorEquals    = "="
+	operatorNotEquals = "!="
+	operatorExists    = "exists"
+	operatorNotExists = "!exists"
+)
+
+// Selector is a parsed label selector, every requirement has to match
+type Selector []requirement
+
+// ParseSelector parses a comma separated selector like "team=payments,env!=dev,critical,!legacy"
+//
+//	key=value    the label is set to value
+//	key!=value   the label is not set to value (or not set at all)
+//	key          the label is set, to anything
+//	!key         the label is not set
+//
+// an empty selector matches everything
+func ParseSelector(text string) (Selector, error) {
+	var selector Selector
+	if strings.TrimSpace(text) == "" {
+		return selector, nil
+	}
+
+	for _, term := range strings.Split(text, ",") {
+		term = strings.TrimSpace(term)
+
+		var parsed requirement
+		switch {
+		case strings.Contains(term, "!="):
+			key, value, _ := strings.Cut(term, "!=")
+			parsed = requirement{key: key, value: value, operator: operatorNotEquals}
+		case strings.Contains(term, "="):
+			key, value, _ := strings.Cut(term, "=")
+			parsed = requirement{key: key, value: value, operator: operatorEquals}
+		case strings.HasPrefix(term, "!"):
+			parsed = requirement{key: strings.TrimPrefix(term, "!"), operator: operatorNotExists}
+		default:
+			parsed = requirement{key: term, operator: operatorExists}
+		}
+
+		parsed.key = strings.TrimSpace(parsed.key)
+		parsed.value = strings.TrimSpace(parsed.value)
+		if !keyPattern.MatchString(parsed.key) {
+			return nil, fmt.Errorf("invalid label selector term %q", term)
+		}
+		if !valuePattern.MatchString(parsed.value) {
+			return nil, fmt.Errorf("invalid value in label selector term %q", term)
+		}
+		selector = append(selector, parsed)
+	}
+
+	if len(selector) == 0 {
+		return nil, errors.New("empty label selector")
+	}
+	return selector, nil
+}
+
+// Matches reports whether the labels satisfy every requirement of the selector
+func (selector Selector) Matches(labels map[string]string) bool {
+	for _, requirement := range selector {
+		value, ok := labels[requirement.key]
+		switch requirement.operator {
+		case operatorEquals:
+			if !ok || value != requirement.value {
+				return false
+			}
+		case operatorNotEquals:
+			if ok && value == requirement.value {
+				return false
+			}
+		case operatorExists:
+			if !ok {
+				return false
+			}
+		case operatorNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ValidateTargetSelector checks how a maintenance window or an SLO selects its targets:
+// by target id, by tag or by label selector, exactly one of them
+func ValidateTargetSelector(targetID, tag, selector string) error {
+	selectors := 0
+	for _, value := range []string{targetID, tag, selector} {
+		if value != "" {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return errors.New("exactly one of targetId, tag or labels is required")
+	}
+	if selector != "" {
+		if _, err := ParseSelector(selector); err != nil {
+			return fmt.Errorf("invalid labels: %w", err)
+		}
+	}
+	return nil
+}
+
+// SelectsTarget reports whether a maintenance window or an SLO selects the target, see ValidateTargetSelector
+// a selector that doesn't parse selects nothing
+func SelectsTarget(targetID, tag, selector string, target model.Target) bool {
+	switch {
+	case targetID != "":
+		return targetID == target.ID
+	case tag != "":
+		return slices.Contains(target.Tags, tag)
+	default:
+		parsed, err := ParseSelector(selector)
+		return err == nil && len(parsed) > 0 && parsed.Matches(target.Labels)
+	}
+}
@@ -0,0 +1,138 @@
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the set of allowed values for one field of a cron expression
+type cronField struct {
+	values map[int]bool
+	// wildcard is set for "*" (and "*/n"), cron treats day fields differently when one of them is "*"
+	wildcard bool
+}
+
+// cronSchedule is a parsed 5 field cron expression: minute hour day-of-month month day-of-week
+type cronSchedule struct {
+	minute     cronField
+	hour       cronField
+	dayOfMonth cronField
+	month      cronField
+	dayOfWeek  cronField
+}
+
+// fieldBounds are the allowed ranges of the five fields, in order
+var fieldBounds = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	// 7 is accepted as an alias for sunday
+	{"day of week", 0, 7},
+}
+
+// parseCron parses a standard 5 field cron expression
+// each field supports "*", single values, ranges ("1-5"), lists ("1,15") and steps ("*/15", "0-30/10")
+func parseCron(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(fieldBounds) {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	parsedFields := make([]cronField, len(fields))
+	for index, field := range fields {
+		parsed, err := parseCronField(field, fieldBounds[index].min, fieldBounds[index].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s field %q: %w", fieldBounds[index].name, field, err)
+		}
+		parsedFields[index] = parsed
+	}
+
+	// fold sunday-as-7 into 0 so matching only has to look at time.Weekday
+	if parsedFields[4].values[7] {
+		parsedFields[4].values[0] = true
+		delete(parsedFields[4].values, 7)
+	}
+
+	return &cronSchedule{
+		minute:     parsedFields[0],
+		hour:       parsedFields[1],
+		dayOfMonth: parsedFields[2],
+		month:      parsedFields[3],
+		dayOfWeek:  parsedFields[4],
+	}, nil
+}
+
+// parseCronField parses one comma separated field within the given bounds
+func parseCronField(field string, min, max int) (cronField, error) {
+	parsed := cronField{values: make(map[int]bool)}
+
+	for _, part := range strings.Split(field, ",") {
+		// split off an optional step
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return cronField{}, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		// work out the range the step walks over
+		var start, end int
+		switch {
+		case rangePart == "*":
+			start, end = min, max
+			parsed.wildcard = true
+		case strings.Contains(rangePart, "-"):
+			startText, endText, _ := strings.Cut(rangePart, "-")
+			var startErr, endErr error
+			start, startErr = strconv.Atoi(startText)
+			end, endErr = strconv.Atoi(endText)
+			if startErr != nil || endErr != nil || start > end {
+				return cronField{}, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			value, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return cronField{}, fmt.Errorf("invalid value %q", rangePart)
+			}
+			// "5/10" means "from 5 to the end, every 10"
+			start, end = value, value
+			if hasStep {
+				end = max
+			}
+		}
+
+		if start < min || end > max {
+			return cronField{}, fmt.Errorf("value out of range %d-%d", min, max)
+		}
+		for value := start; value <= end; value += step {
+			parsed.values[value] = true
+		}
+	}
+
+	return parsed, nil
+}
+
+// matches reports whether the schedule fires at the minute containing t
+func (schedule *cronSchedule) matches(t time.Time) bool {
+	if !schedule.minute.values[t.Minute()] || !schedule.hour.values[t.Hour()] || !schedule.month.values[int(t.Month())] {
+		return false
+	}
+
+	dayOfMonthMatches := schedule.dayOfMonth.values[t.Day()]
+	dayOfWeekMatches := schedule.dayOfWeek.values[int(t.Weekday())]
+
+	// classic cron rule: if both day fields are restricted, either one matching is enough
+	if !schedule.dayOfMonth.wildcard && !schedule.dayOfWeek.wildcard {
+		return dayOfMonthMatches || dayOfWeekMatches
+	}
+	return dayOfMonthMatches && dayOfWeekMatches
+}
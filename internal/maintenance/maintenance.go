@@ -0,0 +1,96 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/labels"
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// MaxDuration caps how long a recurring window may stay open
+// it also bounds the work Active does to look back for the window's start
+const MaxDuration = 7 * 24 * time.Hour
+
+// Validate checks that a window has exactly one selector and exactly one kind of schedule
+func Validate(window model.MaintenanceWindow) error {
+	if err := labels.ValidateTargetSelector(window.TargetID, window.Tag, window.Labels); err != nil {
+		return err
+	}
+
+	isOneOff := window.StartTime != 0 || window.EndTime != 0
+	isRecurring := window.Cron != "" || window.DurationMinutes != 0
+
+	switch {
+	case isOneOff && isRecurring:
+		return errors.New("a window is either one-off (startTime/endTime) or recurring (cron/durationMinutes), not both")
+
+	case isOneOff:
+		if window.StartTime <= 0 || window.EndTime <= window.StartTime {
+			return errors.New("endTime must be after startTime")
+		}
+
+	case isRecurring:
+		if _, err := parseCron(window.Cron); err != nil {
+			return fmt.Errorf("invalid cron: %w", err)
+		}
+		maxMinutes := int(MaxDuration / time.Minute)
+		if window.DurationMinutes <= 0 || window.DurationMinutes > maxMinutes {
+			return fmt.Errorf("durationMinutes must be between 1 and %d", maxMinutes)
+		}
+
+	default:
+		return errors.New("either startTime/endTime or cron/durationMinutes is required")
+	}
+
+	return nil
+}
+
+// Applies reports whether the window selects the target, by ID, by tag or by label selector
+func Applies(window model.MaintenanceWindow, target model.Target) bool {
+	return labels.SelectsTarget(window.TargetID, window.Tag, window.Labels, target)
+}
+
+// Active reports whether the window is open at the given time
+// invalid windows are never active
+func Active(window model.MaintenanceWindow, at time.Time) bool {
+	if window.Cron == "" {
+		return !at.Before(time.Unix(window.StartTime, 0)) && at.Before(time.Unix(window.EndTime, 0))
+	}
+
+	schedule, err := parseCron(window.Cron)
+	if err != nil {
+		return false
+	}
+
+	// the window is open if it started at one of the minutes within the last DurationMinutes
+	minute := at.UTC().Truncate(time.Minute)
+	for offset := 0; offset < window.DurationMinutes && offset < int(MaxDuration/time.Minute); offset++ {
+		if schedule.matches(minute.Add(-time.Duration(offset) * time.Minute)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveWindow returns the first window that covers the target at the given time
+func ActiveWindow(windows []model.MaintenanceWindow, target model.Target, at time.Time) (model.MaintenanceWindow, bool) {
+	for _, window := range windows {
+		if Applies(window, target) && Active(window, at) {
+			return window, true
+		}
+	}
+	return model.MaintenanceWindow{}, false
+}
+
+// Apply marks a probe result as taken during maintenance if one of the windows covers it
+// the http status and failure details are kept so the probe is still useful for debugging
+func Apply(windows []model.MaintenanceWindow, target model.Target, result *model.Result) {
+	window, ok := ActiveWindow(windows, target, time.Unix(result.Timestamp, 0))
+	if !ok {
+		return
+	}
+	result.Status = model.StatusMaintenance
+	result.MaintenanceWindowID = window.ID
+}
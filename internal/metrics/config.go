@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"errors"
+	"os"
+)
+
+// ExporterFromEnv builds the exporter for processes that can't be scraped
+//
+//	METRICS_PUSHGATEWAY_URL     push to a Pushgateway, e.g. http://pushgateway:9091
+//	METRICS_REMOTE_WRITE_URL    or send to a remote-write endpoint, e.g. http://prometheus:9090/api/v1/write
+//	METRICS_JOB                 job label, defaults to DefaultJob
+//	METRICS_USERNAME            optional basic auth for either
+//	METRICS_PASSWORD
+//
+// it returns nil when neither is configured
+func ExporterFromEnv() (Exporter, error) {
+	pushgatewayURL := os.Getenv("METRICS_PUSHGATEWAY_URL")
+	remoteWriteURL := os.Getenv("METRICS_REMOTE_WRITE_URL")
+
+	switch {
+	case pushgatewayURL != "" && remoteWriteURL != "":
+		return nil, errors.New("set either METRICS_PUSHGATEWAY_URL or METRICS_REMOTE_WRITE_URL, not both")
+
+	case pushgatewayURL != "":
+		return &PushgatewayExporter{
+			URL:      pushgatewayURL,
+			Job:      os.Getenv("METRICS_JOB"),
+			Username: os.Getenv("METRICS_USERNAME"),
+			Password: os.Getenv("METRICS_PASSWORD"),
+		}, nil
+
+	case remoteWriteURL != "":
+		return &RemoteWriteExporter{
+			URL:      remoteWriteURL,
+			Job:      os.Getenv("METRICS_JOB"),
+			Username: os.Getenv("METRICS_USERNAME"),
+			Password: os.Getenv("METRICS_PASSWORD"),
+		}, nil
+	}
+
+	return nil, nil
+}
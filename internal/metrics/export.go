@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Exporter ships the probe metrics of a batch of targets somewhere they can be scraped or stored
+// short-lived processes like the lambda runner use it instead of being scraped themselves
+type Exporter interface {
+	Export(ctx context.Context, targetIDs []string) error
+}
+
+// DefaultJob is the job label exported metrics carry
+const DefaultJob = "cloudpulse-runner"
+
+// exportTimeout bounds a single export request, the runner has to finish within the lambda timeout
+const exportTimeout = 10 * time.Second
+
+// httpClient returns the configured client, or a default one with exportTimeout
+func httpClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return &http.Client{Timeout: exportTimeout}
+}
+
+// targetFamilies gathers the cloudpulse metrics of a single target
+// the target_id label is dropped when dropTargetLabel is set, the pushgateway adds it back from the grouping key
+func targetFamilies(gatherer prometheus.Gatherer, targetID string, dropTargetLabel bool) ([]*dto.MetricFamily, error) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	var targetFamilies []*dto.MetricFamily
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), "cloudpulse_") {
+			continue
+		}
+
+		var targetMetrics []*dto.Metric
+		for _, metric := range family.GetMetric() {
+			index := slices.IndexFunc(metric.GetLabel(), func(label *dto.LabelPair) bool {
+				return label.GetName() == "target_id" && label.GetValue() == targetID
+			})
+			if index < 0 {
+				continue
+			}
+			if dropTargetLabel {
+				// gathered metrics are fresh copies, but their label slices are not ours to modify
+				metric.Label = slices.Delete(slices.Clone(metric.GetLabel()), index, index+1)
+			}
+			targetMetrics = append(targetMetrics, metric)
+		}
+
+		if len(targetMetrics) > 0 {
+			family.Metric = targetMetrics
+			targetFamilies = append(targetFamilies, family)
+		}
+	}
+	return targetFamilies, nil
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/slo"
+)
+
+// probe metrics are keyed by target_id only, so renaming a target doesn't split its series
+// cloudpulse_target_info carries the descriptive labels and can be joined on target_id
+var (
+	targetInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudpulse_target_info",
+		Help: "Descriptive labels of a monitored target, always 1.",
+	}, []string{"target_id", "name", "url", "type"})
+
+	targetUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudpulse_target_up",
+		Help: "Whether the last probe of the target succeeded (1) or failed (0). Probes during maintenance don't change it.",
+	}, []string{"target_id"})
+
+	probeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloudpulse_probe_duration_seconds",
+		Help:    "Duration of the last attempt of each probe.",
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"target_id"})
+
+	probesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudpulse_probes_total",
+		Help: "Number of probes by resulting status.",
+	}, []string{"target_id", "status"})
+
+	probeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudpulse_probe_errors_total",
+		Help: "Number of failed probes by reason.",
+	}, []string{"target_id", "reason"})
+
+	targetHTTPStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudpulse_target_http_status",
+		Help: "HTTP status code of the last probe, 0 if there was no response.",
+	}, []string{"target_id"})
+
+	certExpiry = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudpulse_target_cert_expiry_timestamp_seconds",
+		Help: "Earliest expiry of the target's certificate chain, as a unix timestamp.",
+	}, []string{"target_id"})
+
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudpulse_api_requests_total",
+		Help: "Number of API requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	apiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloudpulse_api_request_duration_seconds",
+		Help:    "Duration of API requests by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	rollupMergeFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudpulse_rollup_merge_failures_total",
+		Help: "Number of stored results that could not be merged into a rollup bucket, by step.",
+	}, []string{"step"})
+)
+
+// probe error reasons
+const (
+	ReasonDNS               = "dns"
+	ReasonTimeout           = "timeout"
+	ReasonConnectionRefused = "connection_refused"
+	ReasonTLS               = "tls"
+	ReasonStatusCode        = "status_code"
+	ReasonAssertion         = "assertion"
+	ReasonOther             = "other"
+)
+
+// ObserveResult records a probe result of the target
+func ObserveResult(target model.Target, result model.Result) {
+	// replace the info series in case the target was renamed or moved
+	targetInfo.DeletePartialMatch(prometheus.Labels{"target_id": target.ID})
+	targetType := target.Type
+	if targetType == "" {
+		targetType = model.TargetTypeHTTP
+	}
+	targetInfo.WithLabelValues(target.ID, target.Name, target.URL, targetType).Set(1)
+
+	probesTotal.WithLabelValues(target.ID, result.Status).Inc()
+	probeDuration.WithLabelValues(target.ID).Observe(float64(result.LatencyMs) / 1000)
+	targetHTTPStatus.WithLabelValues(target.ID).Set(float64(result.HTTPStatus))
+	if result.TLS != nil {
+		certExpiry.WithLabelValues(target.ID).Set(float64(result.TLS.NotAfter))
+	}
+
+	if result.Status == model.StatusMaintenance {
+		return
+	}
+	if slo.IsGood(result) {
+		targetUp.WithLabelValues(target.ID).Set(1)
+		return
+	}
+	targetUp.WithLabelValues(target.ID).Set(0)
+	probeErrors.WithLabelValues(target.ID, ErrorReason(result)).Inc()
+}
+
+// ErrorReason classifies why a probe failed
+// transport errors are only kept as text, so this goes by the well known fragments of Go's net errors
+func ErrorReason(result model.Result) string {
+	if result.Error == "" {
+		if strings.HasPrefix(result.FailedAssertion, "status code") {
+			return ReasonStatusCode
+		}
+		return ReasonAssertion
+	}
+
+	errorText := strings.ToLower(result.Error)
+	switch {
+	case strings.Contains(errorText, "no such host") || strings.Contains(errorText, "lookup "):
+		return ReasonDNS
+	case strings.Contains(errorText, "timeout") || strings.Contains(errorText, "deadline exceeded"):
+		return ReasonTimeout
+	case strings.Contains(errorText, "connection refused"):
+		return ReasonConnectionRefused
+	case strings.Contains(errorText, "tls") || strings.Contains(errorText, "x509") || strings.Contains(errorText, "certificate"):
+		return ReasonTLS
+	default:
+		return ReasonOther
+	}
+}
+
+// ForgetTarget drops every series of a deleted target
+func ForgetTarget(targetID string) {
+	labels := prometheus.Labels{"target_id": targetID}
+	targetInfo.DeletePartialMatch(labels)
+	targetUp.DeletePartialMatch(labels)
+	probeDuration.DeletePartialMatch(labels)
+	probesTotal.DeletePartialMatch(labels)
+	probeErrors.DeletePartialMatch(labels)
+	targetHTTPStatus.DeletePartialMatch(labels)
+	certExpiry.DeletePartialMatch(labels)
+}
+
+// RollupMergeFailed counts a result that is stored but missing from the rollup of the given step (1m, 1h or 1d)
+func RollupMergeFailed(step string) {
+	rollupMergeFailures.WithLabelValues(step).Inc()
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+// WriteHeader records the status code before passing it on
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	recorder.statusCode = statusCode
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+// InstrumentHandler counts and times the requests served by a ServeMux
+// the route label is the matched pattern (e.g. "/targets/{id}"), not the raw path, to keep cardinality bounded
+func InstrumentHandler(serveMux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		startTime := time.Now()
+		recorder := &statusRecorder{ResponseWriter: responseWriter, statusCode: http.StatusOK}
+
+		serveMux.ServeHTTP(recorder, request)
+
+		// the mux fills in the pattern it matched while serving
+		route := request.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		apiRequests.WithLabelValues(route, request.Method, strconv.Itoa(recorder.statusCode)).Inc()
+		apiRequestDuration.WithLabelValues(route, request.Method).Observe(time.Since(startTime).Seconds())
+	})
+}
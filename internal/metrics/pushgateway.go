@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// PushgatewayExporter pushes the probe metrics to a Prometheus Pushgateway (or anything speaking its api)
+//
+// every target gets its own group (job + target_id), so a run that only probed some targets
+// replaces their metrics without wiping the ones of targets that weren't due
+type PushgatewayExporter struct {
+	URL string
+	// Job defaults to DefaultJob
+	Job string
+	// Username and Password enable basic auth
+	Username string
+	Password string
+	// Gatherer defaults to prometheus.DefaultGatherer, which the probe metrics are registered with
+	Gatherer prometheus.Gatherer
+	// Client defaults to a client with exportTimeout
+	Client *http.Client
+}
+
+// Export replaces the metrics of each target in its group
+func (pushgatewayExporter *PushgatewayExporter) Export(ctx context.Context, targetIDs []string) error {
+	gatherer := pushgatewayExporter.Gatherer
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	job := pushgatewayExporter.Job
+	if job == "" {
+		job = DefaultJob
+	}
+
+	var errs []error
+	for _, targetID := range targetIDs {
+		pusher := push.New(pushgatewayExporter.URL, job).
+			Grouping("target_id", targetID).
+			Client(httpClient(pushgatewayExporter.Client)).
+			Gatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+				return targetFamilies(gatherer, targetID, true)
+			}))
+		if pushgatewayExporter.Username != "" {
+			pusher = pusher.BasicAuth(pushgatewayExporter.Username, pushgatewayExporter.Password)
+		}
+
+		if err := pusher.PushContext(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to push metrics of %s: %w", targetID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
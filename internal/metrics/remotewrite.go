@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWriteExporter sends the probe metrics to a Prometheus remote-write endpoint
+// (Prometheus with --web.enable-remote-write-receiver, Mimir, Thanos receive, Grafana Cloud, ...)
+type RemoteWriteExporter struct {
+	URL string
+	// Job is added as the job label, defaults to DefaultJob
+	Job string
+	// Username and Password enable basic auth
+	Username string
+	Password string
+	// Gatherer defaults to prometheus.DefaultGatherer, which the probe metrics are registered with
+	Gatherer prometheus.Gatherer
+	// Client defaults to a client with exportTimeout
+	Client *http.Client
+}
+
+// remoteWriteSeries is one time series of a remote-write request, labels include __name__
+type remoteWriteSeries struct {
+	labels map[string]string
+	value  float64
+}
+
+// Export sends the current value of every series of the targets, stamped with the current time
+func (remoteWriteExporter *RemoteWriteExporter) Export(ctx context.Context, targetIDs []string) error {
+	gatherer := remoteWriteExporter.Gatherer
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	job := remoteWriteExporter.Job
+	if job == "" {
+		job = DefaultJob
+	}
+
+	var series []remoteWriteSeries
+	for _, targetID := range targetIDs {
+		families, err := targetFamilies(gatherer, targetID, false)
+		if err != nil {
+			return fmt.Errorf("failed to gather metrics of %s: %w", targetID, err)
+		}
+		for _, family := range families {
+			series = append(series, flattenFamily(family, job)...)
+		}
+	}
+	if len(series) == 0 {
+		return nil
+	}
+
+	body := snappy.Encode(nil, encodeWriteRequest(series, time.Now().UnixMilli()))
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, remoteWriteExporter.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build remote write request: %w", err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/x-protobuf")
+	httpRequest.Header.Set("Content-Encoding", "snappy")
+	httpRequest.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if remoteWriteExporter.Username != "" {
+		httpRequest.SetBasicAuth(remoteWriteExporter.Username, remoteWriteExporter.Password)
+	}
+
+	httpResponse, err := httpClient(remoteWriteExporter.Client).Do(httpRequest)
+	if err != nil {
+		return fmt.Errorf("failed to send remote write request: %w", err)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(httpResponse.Body, 1024))
+		return fmt.Errorf("remote write answered %d: %s", httpResponse.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// flattenFamily turns a metric family into plain series, the way Prometheus would after scraping it
+// histograms become their _bucket, _sum and _count series
+func flattenFamily(family *dto.MetricFamily, job string) []remoteWriteSeries {
+	var series []remoteWriteSeries
+	for _, metric := range family.GetMetric() {
+		labels := map[string]string{"job": job}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+
+		add := func(name string, value float64, extraLabels ...string) {
+			seriesLabels := make(map[string]string, len(labels)+2)
+			for labelName, labelValue := range labels {
+				seriesLabels[labelName] = labelValue
+			}
+			for index := 0; index+1 < len(extraLabels); index += 2 {
+				seriesLabels[extraLabels[index]] = extraLabels[index+1]
+			}
+			seriesLabels["__name__"] = name
+			series = append(series, remoteWriteSeries{labels: seriesLabels, value: value})
+		}
+
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			add(family.GetName(), metric.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			add(family.GetName(), metric.GetGauge().GetValue())
+		case dto.MetricType_HISTOGRAM:
+			histogram := metric.GetHistogram()
+			for _, bucket := range histogram.GetBucket() {
+				// the +Inf bucket is implicit, it is added from the sample count below
+				if math.IsInf(bucket.GetUpperBound(), 1) {
+					continue
+				}
+				add(family.GetName()+"_bucket", float64(bucket.GetCumulativeCount()), "le", formatBound(bucket.GetUpperBound()))
+			}
+			add(family.GetName()+"_bucket", float64(histogram.GetSampleCount()), "le", "+Inf")
+			add(family.GetName()+"_sum", histogram.GetSampleSum())
+			add(family.GetName()+"_count", float64(histogram.GetSampleCount()))
+		}
+	}
+	return series
+}
+
+// formatBound renders a bucket bound the way the text exposition format does
+func formatBound(bound float64) string {
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes a prometheus.WriteRequest protobuf by hand, which saves pulling in
+// the whole prometheus module for four small messages:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []remoteWriteSeries, timestampMs int64) []byte {
+	var request []byte
+	for _, oneSeries := range series {
+		// remote write requires labels sorted by name
+		labelNames := make([]string, 0, len(oneSeries.labels))
+		for labelName := range oneSeries.labels {
+			labelNames = append(labelNames, labelName)
+		}
+		sort.Strings(labelNames)
+
+		var timeSeries []byte
+		for _, labelName := range labelNames {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, labelName)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, oneSeries.labels[labelName])
+
+			timeSeries = protowire.AppendTag(timeSeries, 1, protowire.BytesType)
+			timeSeries = protowire.AppendBytes(timeSeries, label)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(oneSeries.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(timestampMs))
+
+		timeSeries = protowire.AppendTag(timeSeries, 2, protowire.BytesType)
+		timeSeries = protowire.AppendBytes(timeSeries, sample)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, timeSeries)
+	}
+	return request
+}
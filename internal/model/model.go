@@ -1,5 +1,34 @@
 package model
 
+import "time"
+
+// defaults used when a target does not set its own timing policy
+const (
+	DefaultTimeout  = 5 * time.Second
+	DefaultInterval = 30 * time.Second
+)
+
+// DefaultCertExpiryWarningDays is how close to expiry a certificate has to be before the target is degraded
+const DefaultCertExpiryWarningDays = 14
+
+// result statuses
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+	// StatusDegraded means the target answered correctly but needs attention, e.g. its certificate expires soon
+	StatusDegraded = "degraded"
+	// StatusMaintenance marks probes taken during a maintenance window, they don't count against uptime
+	StatusMaintenance = "maintenance"
+)
+
+// target types supported by the probe package
+const (
+	// TargetTypeHTTP issues an HTTP request against the target URL
+	TargetTypeHTTP = "http"
+	// TargetTypeTCP opens a TCP connection to the target's host:port
+	TargetTypeTCP = "tcp"
+)
+
 // Target represents a single monitored endpoint
 type Target struct {
 	// field is defined as ID (uppercase) so that it is exported and visible to other packages
@@ -8,14 +37,238 @@ type Target struct {
 	// basically, "take the value from the ID field in Go, but name the attribute id when you talk to DynamoDB."
 	ID   string `json:"id" dynamodbav:"id"`
 	Name string `json:"name" dynamodbav:"name"`
-	URL  string `json:"url" dynamodbav:"url"`
+	// URL is the full http(s) url for http targets, or host:port for tcp targets
+	URL string `json:"url" dynamodbav:"url"`
+	// Type selects the probe to run, targets stored before types existed have it empty and are treated as http
+	Type string `json:"type" dynamodbav:"type"`
+
+	// request settings for http targets, an empty method means GET
+	// a "Host" entry in Headers overrides the host sent to the server
+	Method  string            `json:"method,omitempty" dynamodbav:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty" dynamodbav:"headers,omitempty"`
+	Body    string            `json:"body,omitempty" dynamodbav:"body,omitempty"`
+
+	// Assertions replace the default "2xx/3xx is up" rule when set
+	Assertions *Assertions `json:"assertions,omitempty" dynamodbav:"assertions,omitempty"`
+
+	// timing policy, zero values fall back to DefaultTimeout and DefaultInterval
+	TimeoutSeconds  int `json:"timeoutSeconds,omitempty" dynamodbav:"timeout_seconds,omitempty"`
+	IntervalSeconds int `json:"intervalSeconds,omitempty" dynamodbav:"interval_seconds,omitempty"`
+	// Retries is how many extra attempts a failing probe gets before the target is reported down
+	Retries int `json:"retries,omitempty" dynamodbav:"retries,omitempty"`
+
+	// CertExpiryWarningDays is the window before certificate expiry in which an https target is degraded
+	// zero falls back to DefaultCertExpiryWarningDays
+	CertExpiryWarningDays int `json:"certExpiryWarningDays,omitempty" dynamodbav:"cert_expiry_warning_days,omitempty"`
+
+	// Paused targets are kept (with their history) but not probed until resumed
+	Paused bool `json:"paused" dynamodbav:"paused"`
+
+	// Tags group targets so that e.g. a maintenance window can cover all of them
+	// they predate Labels, which do the same and more, and are kept for the windows and SLOs that select by tag
+	Tags []string `json:"tags,omitempty" dynamodbav:"tags,omitempty"`
+	// Labels are free-form key/value pairs (team=payments, env=prod) used to filter targets and results,
+	// and to select the targets of maintenance windows and SLOs
+	Labels map[string]string `json:"labels,omitempty" dynamodbav:"labels,omitempty"`
+
+	// RetentionDays and MaxResults override the global result retention for this target, zero keeps the global setting
+	RetentionDays int `json:"retentionDays,omitempty" dynamodbav:"retention_days,omitempty"`
+	MaxResults    int `json:"maxResults,omitempty" dynamodbav:"max_results,omitempty"`
+}
+
+// CertExpiryWarning returns the window before certificate expiry in which the target is degraded
+func (t Target) CertExpiryWarning() time.Duration {
+	days := t.CertExpiryWarningDays
+	if days <= 0 {
+		days = DefaultCertExpiryWarningDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Timeout returns how long a single probe attempt may take
+func (t Target) Timeout() time.Duration {
+	if t.TimeoutSeconds <= 0 {
+		return DefaultTimeout
+	}
+	return time.Duration(t.TimeoutSeconds) * time.Second
+}
+
+// Interval returns how often the target should be probed
+func (t Target) Interval() time.Duration {
+	if t.IntervalSeconds <= 0 {
+		return DefaultInterval
+	}
+	return time.Duration(t.IntervalSeconds) * time.Second
+}
+
+// Assertions are declarative checks on a probe response, all of them must pass for the target to be up
+// empty fields are not checked
+type Assertions struct {
+	// StatusCodes is the explicit set of accepted http status codes
+	StatusCodes     []int  `json:"statusCodes,omitempty" dynamodbav:"status_codes,omitempty"`
+	BodyContains    string `json:"bodyContains,omitempty" dynamodbav:"body_contains,omitempty"`
+	BodyNotContains string `json:"bodyNotContains,omitempty" dynamodbav:"body_not_contains,omitempty"`
+	// BodyRegex is a Go regular expression the body has to match
+	BodyRegex string              `json:"bodyRegex,omitempty" dynamodbav:"body_regex,omitempty"`
+	JSONPath  []JSONPathAssertion `json:"jsonPath,omitempty" dynamodbav:"json_path,omitempty"`
+	// MaxLatencyMs fails the probe if the total latency is above it, this one also applies to tcp targets
+	MaxLatencyMs int64 `json:"maxLatencyMs,omitempty" dynamodbav:"max_latency_ms,omitempty"`
+	// Headers lists required response headers, an empty value only requires the header to be present
+	Headers map[string]string `json:"headers,omitempty" dynamodbav:"headers,omitempty"`
+}
+
+// JSONPathAssertion compares the value at a path in a json body, e.g. {"path": "$.status", "equals": "ok"}
+// non-string values are compared by their json encoding, e.g. "true" or "3"
+type JSONPathAssertion struct {
+	Path   string `json:"path" dynamodbav:"path"`
+	Equals string `json:"equals" dynamodbav:"equals"`
 }
 
 // Result represents the outcome of a single uptime probe
 type Result struct {
-	TargetID   string `json:"targetId" dynamodbav:"target_id"`
-	Name       string `json:"name" dynamodbav:"-"`
-	Status     string `json:"status" dynamodbav:"status"`
-	HTTPStatus int    `json:"httpStatus" dynamodbav:"http_status"`
-	Timestamp  int64  `json:"timestamp" dynamodbav:"timestamp"` // added timestamp for history
+	TargetID string `json:"targetId" dynamodbav:"target_id"`
+	Name     string `json:"name" dynamodbav:"-"`
+	// Paused is filled in from the target when listing latest results, so dashboards can tell
+	// "paused while down" apart from "down"
+	Paused bool `json:"paused,omitempty" dynamodbav:"-"`
+	// Labels are filled in from the target as well, so results can be filtered by label selector
+	Labels     map[string]string `json:"labels,omitempty" dynamodbav:"-"`
+	Status     string            `json:"status" dynamodbav:"status"`
+	HTTPStatus int               `json:"httpStatus" dynamodbav:"http_status"`
+	Timestamp  int64             `json:"timestamp" dynamodbav:"timestamp"` // added timestamp for history
+
+	// latency and its breakdown are recorded in milliseconds
+	// phases that did not happen (e.g. TLS for plain http) are left at zero
+	LatencyMs      int64 `json:"latencyMs" dynamodbav:"latency_ms"`
+	DNSLookupMs    int64 `json:"dnsLookupMs" dynamodbav:"dns_lookup_ms"`
+	TCPConnectMs   int64 `json:"tcpConnectMs" dynamodbav:"tcp_connect_ms"`
+	TLSHandshakeMs int64 `json:"tlsHandshakeMs" dynamodbav:"tls_handshake_ms"`
+	FirstByteMs    int64 `json:"firstByteMs" dynamodbav:"first_byte_ms"` // time to first response byte
+
+	// Error is the transport error (dns, connect, timeout, ...) when the probe could not complete
+	Error string `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	// FailedAssertion describes the first assertion that failed, if any
+	FailedAssertion string `json:"failedAssertion,omitempty" dynamodbav:"failed_assertion,omitempty"`
+	// Attempts is how many tries the probe took, more than 1 means retries were needed
+	Attempts int `json:"attempts,omitempty" dynamodbav:"attempts,omitempty"`
+
+	// TLS describes the negotiated connection and certificate chain of https targets
+	TLS *TLSInfo `json:"tls,omitempty" dynamodbav:"tls,omitempty"`
+
+	// MaintenanceWindowID is set when the probe ran during a maintenance window (status "maintenance")
+	MaintenanceWindowID string `json:"maintenanceWindowId,omitempty" dynamodbav:"maintenance_window_id,omitempty"`
+}
+
+// TLSInfo is the part of the tls connection state worth keeping with a result
+type TLSInfo struct {
+	// Version is the negotiated protocol version, e.g. "TLS 1.3"
+	Version string `json:"version" dynamodbav:"version"`
+	// NotAfter is the earliest expiry (unix seconds) across the peer's certificate chain
+	NotAfter     int64 `json:"notAfter" dynamodbav:"not_after"`
+	DaysToExpiry int   `json:"daysToExpiry" dynamodbav:"days_to_expiry"`
+	// Issuer and SANs are taken from the leaf certificate
+	Issuer string   `json:"issuer" dynamodbav:"issuer"`
+	SANs   []string `json:"sans" dynamodbav:"sans"`
+}
+
+// MaintenanceWindow is a period in which probes still run but are recorded with the "maintenance" status
+// it applies to a single target (TargetID), to every target carrying a tag (Tag)
+// or to every target matching a label selector (Labels)
+//
+// a window is either one-off (StartTime/EndTime) or recurring (Cron/DurationMinutes)
+type MaintenanceWindow struct {
+	ID          string `json:"id" dynamodbav:"id"`
+	Description string `json:"description,omitempty" dynamodbav:"description,omitempty"`
+
+	TargetID string `json:"targetId,omitempty" dynamodbav:"target_id,omitempty"`
+	Tag      string `json:"tag,omitempty" dynamodbav:"tag,omitempty"`
+	// Labels is a label selector like the ?labels= parameter of GET /targets, e.g. "team=payments,env=prod"
+	Labels string `json:"labels,omitempty" dynamodbav:"labels,omitempty"`
+
+	// one-off window, unix seconds, the end is exclusive
+	StartTime int64 `json:"startTime,omitempty" dynamodbav:"start_time,omitempty"`
+	EndTime   int64 `json:"endTime,omitempty" dynamodbav:"end_time,omitempty"`
+
+	// recurring window, Cron is a standard 5 field expression (in UTC) for when the window opens
+	Cron            string `json:"cron,omitempty" dynamodbav:"cron,omitempty"`
+	DurationMinutes int    `json:"durationMinutes,omitempty" dynamodbav:"duration_minutes,omitempty"`
+}
+
+// SLO is a service level objective over one target (TargetID), every target carrying a tag (Tag)
+// or every target matching a label selector (Labels)
+type SLO struct {
+	ID   string `json:"id" dynamodbav:"id"`
+	Name string `json:"name" dynamodbav:"name"`
+
+	TargetID string `json:"targetId,omitempty" dynamodbav:"target_id,omitempty"`
+	Tag      string `json:"tag,omitempty" dynamodbav:"tag,omitempty"`
+	// Labels is a label selector like the ?labels= parameter of GET /targets, e.g. "team=payments,env=prod"
+	Labels string `json:"labels,omitempty" dynamodbav:"labels,omitempty"`
+
+	// Objective is the percentage of good probes to aim for, e.g. 99.9
+	Objective float64 `json:"objective" dynamodbav:"objective"`
+	// Window is the rolling window the objective applies to, e.g. "30d"
+	Window string `json:"window" dynamodbav:"window"`
+	// LatencyThresholdMs also counts successful probes slower than this as bad, zero disables it
+	LatencyThresholdMs int64 `json:"latencyThresholdMs,omitempty" dynamodbav:"latency_threshold_ms,omitempty"`
+}
+
+// incident statuses
+const (
+	IncidentOpen     = "open"
+	IncidentResolved = "resolved"
+)
+
+// Incident is a single outage of a target, opened on the first confirmed failure and resolved on recovery
+type Incident struct {
+	ID       string `json:"id" dynamodbav:"id"`
+	TargetID string `json:"targetId" dynamodbav:"target_id"`
+	Status   string `json:"status" dynamodbav:"status"`
+
+	// StartTime is the timestamp of the first failed probe, EndTime the one of the first good probe after it
+	StartTime int64 `json:"startTime" dynamodbav:"start_time"`
+	EndTime   int64 `json:"endTime,omitempty" dynamodbav:"end_time,omitempty"`
+	// DurationSeconds is filled in when the incident is read, for open incidents it is the duration so far
+	DurationSeconds int64 `json:"durationSeconds" dynamodbav:"-"`
+
+	// details of the probe that confirmed the failure
+	HTTPStatus      int    `json:"httpStatus,omitempty" dynamodbav:"http_status,omitempty"`
+	Error           string `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	FailedAssertion string `json:"failedAssertion,omitempty" dynamodbav:"failed_assertion,omitempty"`
+
+	// set by operators through the api
+	AcknowledgedBy string         `json:"acknowledgedBy,omitempty" dynamodbav:"acknowledged_by,omitempty"`
+	AcknowledgedAt int64          `json:"acknowledgedAt,omitempty" dynamodbav:"acknowledged_at,omitempty"`
+	Notes          []IncidentNote `json:"notes,omitempty" dynamodbav:"notes,omitempty"`
+}
+
+// IncidentNote is a free text annotation on an incident
+type IncidentNote struct {
+	Author    string `json:"author,omitempty" dynamodbav:"author,omitempty"`
+	Text      string `json:"text" dynamodbav:"text"`
+	Timestamp int64  `json:"timestamp" dynamodbav:"timestamp"`
+}
+
+// Rollup aggregates the results of one target over a fixed bucket of time (a minute, an hour or a day)
+// rollups are kept next to the raw results so long-range charts don't have to read every probe
+type Rollup struct {
+	// Start is the beginning of the bucket, unix seconds
+	Start int64 `json:"start" dynamodbav:"timestamp"`
+
+	// Count is the number of probes outside maintenance, UpCount how many of them were up (or degraded)
+	Count            int `json:"count" dynamodbav:"count"`
+	UpCount          int `json:"upCount" dynamodbav:"up_count"`
+	MaintenanceCount int `json:"maintenanceCount" dynamodbav:"maintenance_count"`
+
+	// latency of the probes that were up, like the uptime report
+	MinLatencyMs int64 `json:"minLatencyMs" dynamodbav:"min_latency_ms"`
+	MaxLatencyMs int64 `json:"maxLatencyMs" dynamodbav:"max_latency_ms"`
+	LatencySumMs int64 `json:"-" dynamodbav:"latency_sum_ms"`
+	// LatencyHistogram counts latencies per bucket of rollup.LatencyBucketsMs, the p95 is estimated from it
+	LatencyHistogram []int64 `json:"-" dynamodbav:"latency_histogram"`
+
+	// derived when the rollup is read, see rollup.Summarize
+	AvailabilityPercent float64 `json:"availabilityPercent" dynamodbav:"-"`
+	AvgLatencyMs        float64 `json:"avgLatencyMs" dynamodbav:"-"`
+	P95LatencyMs        int64   `json:"p95LatencyMs" dynamodbav:"-"`
 }
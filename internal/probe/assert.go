@@ -0,0 +1,236 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// response holds the parts of a probe response that assertions look at
+type response struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	latencyMs  int64
+}
+
+// ValidateAssertions checks that a set of assertions can be evaluated
+// the api calls it before storing a target so bad regexes or paths are rejected up front
+func ValidateAssertions(assertions *model.Assertions) error {
+	if assertions == nil {
+		return nil
+	}
+
+	for _, statusCode := range assertions.StatusCodes {
+		if statusCode < 100 || statusCode > 599 {
+			return fmt.Errorf("invalid status code %d", statusCode)
+		}
+	}
+
+	if assertions.BodyRegex != "" {
+		if _, err := regexp.Compile(assertions.BodyRegex); err != nil {
+			return fmt.Errorf("invalid body regex: %w", err)
+		}
+	}
+
+	for _, jsonPathAssertion := range assertions.JSONPath {
+		if _, err := parseJSONPath(jsonPathAssertion.Path); err != nil {
+			return fmt.Errorf("invalid json path %q: %w", jsonPathAssertion.Path, err)
+		}
+	}
+
+	if assertions.MaxLatencyMs < 0 {
+		return errors.New("invalid max latency: must not be negative")
+	}
+
+	for headerName := range assertions.Headers {
+		if headerName == "" {
+			return errors.New("invalid header assertion: header name is empty")
+		}
+	}
+
+	return nil
+}
+
+// evaluate runs the assertions against a response and returns a description of the first failure
+// an empty string means every assertion passed
+func evaluate(assertions *model.Assertions, probeResponse response) string {
+	// without an explicit list, any 2xx or 3xx status is accepted
+	if len(assertions.StatusCodes) > 0 {
+		if !slices.Contains(assertions.StatusCodes, probeResponse.statusCode) {
+			return fmt.Sprintf("status code %d not in %v", probeResponse.statusCode, assertions.StatusCodes)
+		}
+	} else if probeResponse.statusCode < 200 || probeResponse.statusCode >= 400 {
+		return fmt.Sprintf("status code %d not in 200-399", probeResponse.statusCode)
+	}
+
+	if assertions.BodyContains != "" && !bytes.Contains(probeResponse.body, []byte(assertions.BodyContains)) {
+		return fmt.Sprintf("body does not contain %q", assertions.BodyContains)
+	}
+
+	if assertions.BodyNotContains != "" && bytes.Contains(probeResponse.body, []byte(assertions.BodyNotContains)) {
+		return fmt.Sprintf("body contains %q", assertions.BodyNotContains)
+	}
+
+	if assertions.BodyRegex != "" {
+		// already validated when the target was stored, but be defensive about old records
+		bodyRegex, err := regexp.Compile(assertions.BodyRegex)
+		if err != nil {
+			return fmt.Sprintf("invalid body regex: %v", err)
+		}
+		if !bodyRegex.Match(probeResponse.body) {
+			return fmt.Sprintf("body does not match /%s/", assertions.BodyRegex)
+		}
+	}
+
+	if len(assertions.JSONPath) > 0 {
+		if failure := evaluateJSONPaths(assertions.JSONPath, probeResponse.body); failure != "" {
+			return failure
+		}
+	}
+
+	if assertions.MaxLatencyMs > 0 && probeResponse.latencyMs > assertions.MaxLatencyMs {
+		return fmt.Sprintf("latency %dms exceeds %dms", probeResponse.latencyMs, assertions.MaxLatencyMs)
+	}
+
+	// in name order, so a response that fails several header assertions always reports the same one
+	for _, headerName := range slices.Sorted(maps.Keys(assertions.Headers)) {
+		expectedValue := assertions.Headers[headerName]
+		values := probeResponse.header.Values(headerName)
+		if len(values) == 0 {
+			return fmt.Sprintf("header %q missing", headerName)
+		}
+		if expectedValue != "" && !slices.Contains(values, expectedValue) {
+			return fmt.Sprintf("header %q is %q, want %q", headerName, values[0], expectedValue)
+		}
+	}
+
+	return ""
+}
+
+// evaluateJSONPaths decodes the body once and checks every path assertion against it
+func evaluateJSONPaths(jsonPathAssertions []model.JSONPathAssertion, body []byte) string {
+	// UseNumber keeps numbers in their original form, so "3" matches 3 and not 3.0
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return "body is not valid json"
+	}
+
+	for _, jsonPathAssertion := range jsonPathAssertions {
+		segments, err := parseJSONPath(jsonPathAssertion.Path)
+		if err != nil {
+			return fmt.Sprintf("invalid json path %q: %v", jsonPathAssertion.Path, err)
+		}
+
+		value, found := lookupJSONPath(document, segments)
+		if !found {
+			return fmt.Sprintf("json path %s not found", jsonPathAssertion.Path)
+		}
+
+		actual := jsonValueString(value)
+		if actual != jsonPathAssertion.Equals {
+			return fmt.Sprintf("json path %s is %q, want %q", jsonPathAssertion.Path, actual, jsonPathAssertion.Equals)
+		}
+	}
+
+	return ""
+}
+
+// pathSegment is one step of a json path, either an object key or an array index
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath parses a small subset of json path: $.a.b[0].c
+// the leading "$" and "." are optional, so "a.b[0].c" works too
+func parseJSONPath(path string) ([]pathSegment, error) {
+	remaining := strings.TrimPrefix(path, "$")
+	remaining = strings.TrimPrefix(remaining, ".")
+	if remaining == "" {
+		return nil, errors.New("path is empty")
+	}
+
+	var segments []pathSegment
+	for remaining != "" {
+		switch remaining[0] {
+		case '[':
+			closing := strings.IndexByte(remaining, ']')
+			if closing < 0 {
+				return nil, errors.New("missing closing bracket")
+			}
+			index, err := strconv.Atoi(remaining[1:closing])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid array index %q", remaining[1:closing])
+			}
+			segments = append(segments, pathSegment{index: index, isIndex: true})
+			remaining = remaining[closing+1:]
+
+		case '.':
+			remaining = remaining[1:]
+			if remaining == "" || remaining[0] == '.' || remaining[0] == '[' {
+				return nil, errors.New("empty key")
+			}
+
+		default:
+			end := strings.IndexAny(remaining, ".[")
+			if end < 0 {
+				end = len(remaining)
+			}
+			segments = append(segments, pathSegment{key: remaining[:end]})
+			remaining = remaining[end:]
+		}
+	}
+
+	return segments, nil
+}
+
+// lookupJSONPath walks a decoded json document along the path segments
+func lookupJSONPath(document any, segments []pathSegment) (any, bool) {
+	current := document
+	for _, segment := range segments {
+		if segment.isIndex {
+			array, ok := current.([]any)
+			if !ok || segment.index >= len(array) {
+				return nil, false
+			}
+			current = array[segment.index]
+			continue
+		}
+
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[segment.key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// jsonValueString renders a json value for comparison: strings as-is, everything else as json
+func jsonValueString(value any) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
@@ -2,50 +2,267 @@ package probe
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
 	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// check performs a single probe of the target url
+// maxBodyBytes caps how much of the response body we read for latency and assertions
+const maxBodyBytes = 1 << 20
+
+// timings collects the phase durations reported by httptrace for a single request
+type timings struct {
+	// hooks fire from the transport's dial goroutines, so writes and reads are guarded
+	mutex        sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+}
+
+// mark records the current time into the given phase field
+// if onlyFirst is set, an already recorded instant is kept
+func (phaseTimings *timings) mark(field *time.Time, onlyFirst bool) {
+	phaseTimings.mutex.Lock()
+	defer phaseTimings.mutex.Unlock()
+
+	if onlyFirst && !field.IsZero() {
+		return
+	}
+	*field = time.Now()
+}
+
+// clientTrace returns the httptrace hooks that fill in the timings
+func (phaseTimings *timings) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { phaseTimings.mark(&phaseTimings.dnsStart, false) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { phaseTimings.mark(&phaseTimings.dnsDone, false) },
+		// with multiple addresses (e.g. ipv4 and ipv6) only the first attempt starts the clock
+		ConnectStart: func(_, _ string) { phaseTimings.mark(&phaseTimings.connectStart, true) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				phaseTimings.mark(&phaseTimings.connectDone, true)
+			}
+		},
+		TLSHandshakeStart:    func() { phaseTimings.mark(&phaseTimings.tlsStart, false) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { phaseTimings.mark(&phaseTimings.tlsDone, false) },
+		GotFirstResponseByte: func() { phaseTimings.mark(&phaseTimings.firstByte, false) },
+	}
+}
+
+// millisBetween returns the milliseconds between two instants, or 0 if either is unset
+func millisBetween(start, end time.Time) int64 {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start).Milliseconds()
+}
+
+// retryDelay is the pause between attempts of a failing probe
+const retryDelay = time.Second
+
+// MaxDuration is the longest Check can take for the target: every attempt timing out, with the pauses between them
+func MaxDuration(t model.Target) time.Duration {
+	attempts := time.Duration(t.Retries + 1)
+	return attempts*t.Timeout() + (attempts-1)*retryDelay
+}
+
+// Check probes the target, retrying up to t.Retries times before reporting it down
+// both the api scheduler and the runner call this, so new probe types only need wiring here
 func Check(ctx context.Context, t model.Target) model.Result {
+	ctx, span := tracing.Tracer().Start(ctx, "probe.Check", trace.WithAttributes(
+		attribute.String("cloudpulse.target.id", t.ID),
+		attribute.String("cloudpulse.target.type", t.Type),
+		attribute.String("cloudpulse.target.url", t.URL),
+	))
+	defer span.End()
+
+	var result model.Result
+	// the deferred call sees the final result, whichever return is taken
+	defer func() { annotateSpan(span, result) }()
+
+	for attempt := 1; attempt <= t.Retries+1; attempt++ {
+		// wait before retrying, but give up early if the caller is done with us
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return result
+			case <-time.After(retryDelay):
+			}
+		}
+
+		result = checkOnce(ctx, t)
+		result.Attempts = attempt
+		// only hard failures are retried, a degraded target answered fine
+		if result.Status != model.StatusDown {
+			break
+		}
+	}
+
+	return result
+}
+
+// annotateSpan records the outcome and timing breakdown of a probe on its span
+func annotateSpan(span trace.Span, result model.Result) {
+	span.SetAttributes(
+		attribute.String("cloudpulse.probe.status", result.Status),
+		attribute.Int("cloudpulse.probe.attempts", result.Attempts),
+		attribute.Int("cloudpulse.probe.http_status", result.HTTPStatus),
+		attribute.Int64("cloudpulse.probe.latency_ms", result.LatencyMs),
+		attribute.Int64("cloudpulse.probe.dns_lookup_ms", result.DNSLookupMs),
+		attribute.Int64("cloudpulse.probe.tcp_connect_ms", result.TCPConnectMs),
+		attribute.Int64("cloudpulse.probe.tls_handshake_ms", result.TLSHandshakeMs),
+		attribute.Int64("cloudpulse.probe.first_byte_ms", result.FirstByteMs),
+	)
+
+	// a down target is the expected outcome of monitoring, but it's what people search traces for
+	if result.Status == model.StatusDown {
+		reason := result.Error
+		if reason == "" {
+			reason = result.FailedAssertion
+		}
+		span.SetStatus(codes.Error, reason)
+	}
+}
+
+// checkOnce performs a single probe attempt, dispatching on the target type
+func checkOnce(ctx context.Context, t model.Target) model.Result {
+	switch t.Type {
+	case model.TargetTypeTCP:
+		return checkTCP(ctx, t)
+	default:
+		// empty type means the target was stored before types existed, which were all http
+		return checkHTTP(ctx, t)
+	}
+}
+
+// checkHTTP performs a single http probe of the target url
+func checkHTTP(ctx context.Context, t model.Target) model.Result {
 	startTime := time.Now()
 
-	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	// attach the trace hooks to the request context so we get the timing breakdown
+	phaseTimings := &timings{}
+	traceCtx := httptrace.WithClientTrace(ctx, phaseTimings.clientTrace())
+
+	// targets without a method are probed with GET
+	method := t.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	// only send a body if one is configured
+	var requestBody io.Reader
+	if t.Body != "" {
+		requestBody = strings.NewReader(t.Body)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(traceCtx, method, t.URL, requestBody)
 	// if creating request fails, it's a hard failure
 	if err != nil {
 		return model.Result{
 			TargetID:   t.ID,
-			Status:     "down",
+			Status:     model.StatusDown,
 			HTTPStatus: 0,
 			Timestamp:  startTime.Unix(),
+			Error:      err.Error(),
+		}
+	}
+
+	// apply the custom headers, the Host header has to go through request.Host to take effect
+	for headerName, headerValue := range t.Headers {
+		if strings.EqualFold(headerName, "Host") {
+			httpRequest.Host = headerValue
+			continue
 		}
+		httpRequest.Header.Set(headerName, headerValue)
 	}
 
-	// perform the probe with a timeout of 5 seconds
+	// use a dedicated transport without keep-alives so every probe pays for
+	// dns, connect and tls, otherwise pooled connections would report zero for those phases
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = true
+
+	// perform the probe with the target's timeout (5 seconds unless configured)
 	client := &http.Client{
-		Timeout: 5 * time.Second,
+		Timeout:   t.Timeout(),
+		Transport: transport,
+	}
+
+	result := model.Result{
+		TargetID:  t.ID,
+		Status:    model.StatusDown,
+		Timestamp: startTime.Unix(),
 	}
 
 	// response from the probe
 	httpResponse, err := client.Do(httpRequest)
-	status := "down"
-	httpStatus := 0
-
-	if err == nil {
-		httpStatus = httpResponse.StatusCode
-		// if the http status is between 200 and 400, the target is up
-		if httpResponse.StatusCode >= 200 && httpResponse.StatusCode < 400 {
-			status = "up"
-		}
+	var responseBody []byte
+
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.HTTPStatus = httpResponse.StatusCode
+		// read (a bounded amount of) the body so latency covers the full response
+		// and the assertions can inspect it
+		responseBody, err = io.ReadAll(io.LimitReader(httpResponse.Body, maxBodyBytes))
 		httpResponse.Body.Close()
+		if err != nil {
+			result.Error = err.Error()
+		}
+	}
+
+	result.LatencyMs = time.Since(startTime).Milliseconds()
+
+	// take the lock so late hooks from abandoned dials can't race with the reads below
+	phaseTimings.mutex.Lock()
+	result.DNSLookupMs = millisBetween(phaseTimings.dnsStart, phaseTimings.dnsDone)
+	result.TCPConnectMs = millisBetween(phaseTimings.connectStart, phaseTimings.connectDone)
+	result.TLSHandshakeMs = millisBetween(phaseTimings.tlsStart, phaseTimings.tlsDone)
+	result.FirstByteMs = millisBetween(startTime, phaseTimings.firstByte)
+	phaseTimings.mutex.Unlock()
+
+	// no response means nothing to assert on, the target stays down
+	if result.Error != "" {
+		return result
+	}
+
+	// keep the certificate details even if an assertion fails, the expiry is still worth knowing
+	result.TLS = tlsInfo(httpResponse.TLS, time.Now())
+
+	// targets without assertions use the default rule: any 2xx or 3xx status is up
+	assertions := t.Assertions
+	if assertions == nil {
+		assertions = &model.Assertions{}
 	}
 
-	return model.Result{
-		TargetID:   t.ID,
-		Status:     status,
-		HTTPStatus: httpStatus,
-		Timestamp:  startTime.Unix(),
+	result.FailedAssertion = evaluate(assertions, response{
+		statusCode: httpResponse.StatusCode,
+		header:     httpResponse.Header,
+		body:       responseBody,
+		latencyMs:  result.LatencyMs,
+	})
+	if result.FailedAssertion != "" {
+		return result
 	}
+	result.Status = model.StatusUp
+
+	// a certificate about to expire doesn't make the target down, but someone should look at it
+	if result.TLS != nil && time.Until(time.Unix(result.TLS.NotAfter, 0)) < t.CertExpiryWarning() {
+		result.Status = model.StatusDegraded
+	}
+
+	return result
 }
@@ -0,0 +1,49 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// checkTCP performs a single tcp connect probe of the target's host:port
+// the target is up if the connection can be established, nothing is sent over it
+func checkTCP(ctx context.Context, t model.Target) model.Result {
+	startTime := time.Now()
+
+	// same timeout as the http probe
+	dialer := &net.Dialer{
+		Timeout: t.Timeout(),
+	}
+
+	// the dial includes name resolution, so the connect time covers both
+	connection, err := dialer.DialContext(ctx, "tcp", t.URL)
+	connectDuration := time.Since(startTime).Milliseconds()
+
+	// tcp probes have no http status, so it always stays at 0
+	result := model.Result{
+		TargetID:     t.ID,
+		Status:       model.StatusDown,
+		Timestamp:    startTime.Unix(),
+		LatencyMs:    connectDuration,
+		TCPConnectMs: connectDuration,
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	connection.Close()
+
+	// max latency is the only assertion that makes sense without a response
+	if t.Assertions != nil && t.Assertions.MaxLatencyMs > 0 && connectDuration > t.Assertions.MaxLatencyMs {
+		result.FailedAssertion = fmt.Sprintf("latency %dms exceeds %dms", connectDuration, t.Assertions.MaxLatencyMs)
+		return result
+	}
+
+	result.Status = model.StatusUp
+	return result
+}
@@ -0,0 +1,39 @@
+package probe
+
+import (
+	"crypto/tls"
+	"math"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// tlsInfo extracts the certificate details we keep from a tls connection state
+// it returns nil for plain http responses
+func tlsInfo(connectionState *tls.ConnectionState, now time.Time) *model.TLSInfo {
+	if connectionState == nil || len(connectionState.PeerCertificates) == 0 {
+		return nil
+	}
+
+	// the chain is only as good as its first certificate to expire, intermediates included
+	notAfter := connectionState.PeerCertificates[0].NotAfter
+	for _, certificate := range connectionState.PeerCertificates[1:] {
+		if certificate.NotAfter.Before(notAfter) {
+			notAfter = certificate.NotAfter
+		}
+	}
+
+	leaf := connectionState.PeerCertificates[0]
+	subjectAltNames := append([]string{}, leaf.DNSNames...)
+	for _, ipAddress := range leaf.IPAddresses {
+		subjectAltNames = append(subjectAltNames, ipAddress.String())
+	}
+
+	return &model.TLSInfo{
+		Version:      tls.VersionName(connectionState.Version),
+		NotAfter:     notAfter.Unix(),
+		DaysToExpiry: int(math.Floor(notAfter.Sub(now).Hours() / 24)),
+		Issuer:       leaf.Issuer.String(),
+		SANs:         subjectAltNames,
+	}
+}
@@ -0,0 +1,107 @@
+package retention
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// limits of the retention settings, the same for the environment and for targets
+const (
+	MaxDays       = 10 * 365
+	MaxMaxResults = 10_000_000
+)
+
+// Default keeps results for 30 days, what the DynamoDB ttl always was
+var Default = Policy{MaxAge: 30 * 24 * time.Hour}
+
+// Policy limits how much probe history of a target is kept
+// zero values don't limit: a policy without MaxAge keeps results forever unless MaxResults is reached
+type Policy struct {
+	// MaxAge is how long a result is kept after it was probed
+	MaxAge time.Duration
+	// MaxResults is how many of the newest results are kept
+	MaxResults int
+}
+
+// FromEnv reads the global policy, shared by the api and the runner
+//
+//	RESULT_RETENTION_DAYS   days results are kept, 0 keeps them forever (default 30)
+//	RESULT_MAX_RESULTS      results kept per target, 0 for no limit (default 0)
+//
+// targets can override both with retentionDays and maxResults
+func FromEnv() (Policy, error) {
+	policy := Default
+
+	if daysText := os.Getenv("RESULT_RETENTION_DAYS"); daysText != "" {
+		days, err := strconv.Atoi(daysText)
+		if err != nil || days < 0 || days > MaxDays {
+			return Policy{}, fmt.Errorf("RESULT_RETENTION_DAYS must be between 0 and %d", MaxDays)
+		}
+		policy.MaxAge = time.Duration(days) * 24 * time.Hour
+	}
+
+	if maxResultsText := os.Getenv("RESULT_MAX_RESULTS"); maxResultsText != "" {
+		maxResults, err := strconv.Atoi(maxResultsText)
+		if err != nil || maxResults < 0 || maxResults > MaxMaxResults {
+			return Policy{}, fmt.Errorf("RESULT_MAX_RESULTS must be between 0 and %d", MaxMaxResults)
+		}
+		policy.MaxResults = maxResults
+	}
+
+	return policy, nil
+}
+
+// ForTarget returns the policy of a target: its own settings where it has them, the global ones otherwise
+func (policy Policy) ForTarget(target model.Target) Policy {
+	if target.RetentionDays > 0 {
+		policy.MaxAge = time.Duration(target.RetentionDays) * 24 * time.Hour
+	}
+	if target.MaxResults > 0 {
+		policy.MaxResults = target.MaxResults
+	}
+	return policy
+}
+
+// Cutoff returns the oldest timestamp that is still kept at now, unix seconds
+func (policy Policy) Cutoff(now time.Time) int64 {
+	if policy.MaxAge <= 0 {
+		return math.MinInt64
+	}
+	return now.Add(-policy.MaxAge).Unix()
+}
+
+// Expired returns how many results at the front of a history (oldest first) the policy no longer keeps at now
+func (policy Policy) Expired(results []model.Result, now time.Time) int {
+	cutoff := policy.Cutoff(now)
+	drop := 0
+	for drop < len(results) && results[drop].Timestamp < cutoff {
+		drop++
+	}
+	if policy.MaxResults > 0 && len(results)-drop > policy.MaxResults {
+		drop = len(results) - policy.MaxResults
+	}
+	return drop
+}
+
+// Expiry returns when a result should expire for stores that delete by time only, like DynamoDB with its ttl
+// the count limit becomes an age: a target probed every interval reaches MaxResults after MaxResults intervals
+// the zero time means the result never expires
+func (policy Policy) Expiry(result model.Result, interval time.Duration) time.Time {
+	maxAge := policy.MaxAge
+	// past math.MaxInt64 the count limit is so far away it doesn't matter
+	if policy.MaxResults > 0 && interval > 0 && interval <= math.MaxInt64/time.Duration(policy.MaxResults) {
+		countAge := time.Duration(policy.MaxResults) * interval
+		if maxAge <= 0 || countAge < maxAge {
+			maxAge = countAge
+		}
+	}
+	if maxAge <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(result.Timestamp, 0).Add(maxAge)
+}
@@ -0,0 +1,158 @@
+package rollup
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/slo"
+)
+
+// Steps are the bucket sizes every result is rolled up into
+var Steps = []time.Duration{time.Minute, time.Hour, 24 * time.Hour}
+
+// stepNames are how steps are written in urls and storage keys
+var stepNames = map[time.Duration]string{
+	time.Minute:    "1m",
+	time.Hour:      "1h",
+	24 * time.Hour: "1d",
+}
+
+// LatencyBucketsMs are the upper bounds of the latency histogram, the last histogram bucket counts everything above
+var LatencyBucketsMs = []int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// ParseStep parses "1m", "1h" or "1d"
+func ParseStep(text string) (time.Duration, error) {
+	for step, name := range stepNames {
+		if name == text {
+			return step, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid step %q: must be 1m, 1h or 1d", text)
+}
+
+// StepName returns the name of one of the Steps, e.g. "1h"
+func StepName(step time.Duration) string {
+	return stepNames[step]
+}
+
+// Key identifies the rollups of a target for one step, e.g. "abc123#1h"
+// DynamoDB uses it as the partition key so rollups can share the results table
+func Key(targetID string, step time.Duration) string {
+	return targetID + "#" + StepName(step)
+}
+
+// Retention is how long a store should keep the rollups of a step
+// finer steps are only useful for recent history
+func Retention(step time.Duration) time.Duration {
+	switch step {
+	case time.Minute:
+		return 7 * 24 * time.Hour
+	case time.Hour:
+		return 90 * 24 * time.Hour
+	default:
+		return 2 * 365 * 24 * time.Hour
+	}
+}
+
+// Expiry returns when a bucket that starts at start (unix seconds) is past its step's retention
+// the retention counts from when the bucket closes
+func Expiry(start int64, step time.Duration) time.Time {
+	return time.Unix(start, 0).Add(step + Retention(step))
+}
+
+// BucketStart returns the start of the bucket a timestamp falls in, in unix seconds
+func BucketStart(timestamp int64, step time.Duration) int64 {
+	seconds := int64(step / time.Second)
+	return timestamp - ((timestamp%seconds)+seconds)%seconds
+}
+
+// FromResult returns the rollup of a single result, stores merge it into the bucket they already have
+func FromResult(result model.Result, step time.Duration) model.Rollup {
+	rollup := model.Rollup{
+		Start:            BucketStart(result.Timestamp, step),
+		LatencyHistogram: make([]int64, len(LatencyBucketsMs)+1),
+	}
+
+	if result.Status == model.StatusMaintenance {
+		rollup.MaintenanceCount = 1
+		return rollup
+	}
+
+	rollup.Count = 1
+	if slo.IsGood(result) {
+		rollup.UpCount = 1
+		rollup.MinLatencyMs = result.LatencyMs
+		rollup.MaxLatencyMs = result.LatencyMs
+		rollup.LatencySumMs = result.LatencyMs
+		rollup.LatencyHistogram[histogramIndex(result.LatencyMs)] = 1
+	}
+	return rollup
+}
+
+// histogramIndex returns the histogram bucket a latency is counted in
+func histogramIndex(latencyMs int64) int {
+	for index, upperBound := range LatencyBucketsMs {
+		if latencyMs <= upperBound {
+			return index
+		}
+	}
+	return len(LatencyBucketsMs)
+}
+
+// Merge adds other to into, both must cover the same bucket
+func Merge(into *model.Rollup, other model.Rollup) {
+	// min and max only make sense over probes that had a latency
+	switch {
+	case other.UpCount == 0:
+	case into.UpCount == 0:
+		into.MinLatencyMs = other.MinLatencyMs
+		into.MaxLatencyMs = other.MaxLatencyMs
+	default:
+		into.MinLatencyMs = min(into.MinLatencyMs, other.MinLatencyMs)
+		into.MaxLatencyMs = max(into.MaxLatencyMs, other.MaxLatencyMs)
+	}
+
+	into.Count += other.Count
+	into.UpCount += other.UpCount
+	into.MaintenanceCount += other.MaintenanceCount
+	into.LatencySumMs += other.LatencySumMs
+
+	// histograms written before a bucket bound was added are shorter, grow them
+	if len(into.LatencyHistogram) < len(other.LatencyHistogram) {
+		into.LatencyHistogram = append(into.LatencyHistogram, make([]int64, len(other.LatencyHistogram)-len(into.LatencyHistogram))...)
+	}
+	for index, count := range other.LatencyHistogram {
+		into.LatencyHistogram[index] += count
+	}
+}
+
+// Summarize fills in the fields derived from the stored counters
+// the p95 is the upper bound of the histogram bucket holding the 95th percentile, clamped to min and max
+func Summarize(rollup *model.Rollup) {
+	rollup.AvailabilityPercent = 100
+	if rollup.Count > 0 {
+		rollup.AvailabilityPercent = math.Round(float64(rollup.UpCount)/float64(rollup.Count)*100*10000) / 10000
+	}
+
+	rollup.AvgLatencyMs, rollup.P95LatencyMs = 0, 0
+	if rollup.UpCount == 0 {
+		return
+	}
+	rollup.AvgLatencyMs = math.Round(float64(rollup.LatencySumMs)/float64(rollup.UpCount)*100) / 100
+
+	rank := int64(math.Ceil(0.95 * float64(rollup.UpCount)))
+	var seen int64
+	rollup.P95LatencyMs = rollup.MaxLatencyMs
+	for index, count := range rollup.LatencyHistogram {
+		seen += count
+		if seen >= rank {
+			if index < len(LatencyBucketsMs) {
+				rollup.P95LatencyMs = LatencyBucketsMs[index]
+			}
+			break
+		}
+	}
+	rollup.P95LatencyMs = min(max(rollup.P95LatencyMs, rollup.MinLatencyMs), rollup.MaxLatencyMs)
+}
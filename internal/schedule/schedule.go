@@ -0,0 +1,22 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// Due reports whether a target should be probed at now, given when it was last probed
+// paused targets are never due, a zero lastRun means the target was never probed, so it is due right away
+//
+// slack lets a scheduler that wakes up on a fixed tick run a probe slightly early
+// instead of skipping a whole tick because of a few hundred milliseconds of jitter
+func Due(target model.Target, lastRun, now time.Time, slack time.Duration) bool {
+	if target.Paused {
+		return false
+	}
+	if lastRun.IsZero() {
+		return true
+	}
+	return !now.Add(slack).Before(lastRun.Add(target.Interval()))
+}
@@ -0,0 +1,166 @@
+package slo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/labels"
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// SLO statuses reported by Evaluate
+const (
+	StatusOK       = "ok"
+	StatusAtRisk   = "at_risk"
+	StatusBreached = "breached"
+)
+
+// burnRateWindows are the look-back windows burn rates are reported for, sorted by duration
+// each comes with the burn rate above which the budget is at risk: burning 2% of a 30 day
+// budget in 1 hour is a rate of 14.4, 5% in 6 hours is 6, 10% in 3 days is 1 (the classic multiwindow alerts)
+var burnRateWindows = []struct {
+	name      string
+	duration  time.Duration
+	threshold float64
+}{
+	{"1h", time.Hour, 14.4},
+	{"6h", 6 * time.Hour, 6},
+	{"24h", 24 * time.Hour, 3},
+	{"3d", 72 * time.Hour, 1},
+}
+
+// BurnRate is how fast the error budget is being consumed over a window
+// 1 means the budget would be exactly used up by the end of the SLO window
+type BurnRate struct {
+	Window      string  `json:"window"`
+	TotalEvents int     `json:"totalEvents"`
+	BadEvents   int     `json:"badEvents"`
+	Rate        float64 `json:"rate"`
+	Threshold   float64 `json:"threshold"`
+}
+
+// Report is the state of an SLO computed from the stored probe history
+type Report struct {
+	SLO       model.SLO `json:"slo"`
+	TargetIDs []string  `json:"targetIds"`
+	From      int64     `json:"from"`
+	To        int64     `json:"to"`
+
+	TotalEvents int `json:"totalEvents"`
+	GoodEvents  int `json:"goodEvents"`
+	// SLIPercent is the observed percentage of good events, 100 without any events
+	SLIPercent float64 `json:"sliPercent"`
+	// ErrorBudgetRemainingPercent is 100 for an untouched budget and negative once the SLO is breached
+	ErrorBudgetRemainingPercent float64 `json:"errorBudgetRemainingPercent"`
+
+	BurnRates []BurnRate `json:"burnRates"`
+	Status    string     `json:"status"`
+}
+
+// Validate checks an SLO definition
+func Validate(objective model.SLO) error {
+	if err := labels.ValidateTargetSelector(objective.TargetID, objective.Tag, objective.Labels); err != nil {
+		return err
+	}
+	if objective.Objective <= 0 || objective.Objective >= 100 {
+		return errors.New("objective must be a percentage between 0 and 100 (exclusive)")
+	}
+	if _, err := ParseWindow(objective.Window); err != nil {
+		return err
+	}
+	if objective.LatencyThresholdMs < 0 {
+		return errors.New("latencyThresholdMs must not be negative")
+	}
+	return nil
+}
+
+// isGoodEvent reports whether a result is a good event for the SLO
+// results are expected to exclude maintenance probes already
+func isGoodEvent(objective model.SLO, result model.Result) bool {
+	if !IsGood(result) {
+		return false
+	}
+	return objective.LatencyThresholdMs == 0 || result.LatencyMs <= objective.LatencyThresholdMs
+}
+
+// Evaluate computes the SLO report from the results of the selected targets over the SLO window
+// results older than the window are ignored
+func Evaluate(objective model.SLO, targetIDs []string, results []model.Result, now time.Time) (Report, error) {
+	window, err := ParseWindow(objective.Window)
+	if err != nil {
+		return Report{}, fmt.Errorf("invalid slo window: %w", err)
+	}
+
+	report := Report{
+		SLO:       objective,
+		TargetIDs: targetIDs,
+		From:      now.Add(-window).Unix(),
+		To:        now.Unix(),
+		BurnRates: []BurnRate{},
+	}
+	if report.TargetIDs == nil {
+		report.TargetIDs = []string{}
+	}
+
+	// allowed fraction of bad events, e.g. 0.001 for 99.9%
+	errorBudget := 1 - objective.Objective/100
+
+	burnRates := make([]BurnRate, 0, len(burnRateWindows))
+	for _, burnRateWindow := range burnRateWindows {
+		// only report windows that fit inside the slo window, they are sorted so these form a prefix
+		if burnRateWindow.duration > window {
+			break
+		}
+		burnRates = append(burnRates, BurnRate{Window: burnRateWindow.name, Threshold: burnRateWindow.threshold})
+	}
+
+	for _, result := range results {
+		if result.Timestamp < report.From || result.Timestamp > report.To || result.Status == model.StatusMaintenance {
+			continue
+		}
+
+		good := isGoodEvent(objective, result)
+		report.TotalEvents++
+		if good {
+			report.GoodEvents++
+		}
+
+		age := now.Sub(time.Unix(result.Timestamp, 0))
+		for index, burnRateWindow := range burnRateWindows[:len(burnRates)] {
+			if age > burnRateWindow.duration {
+				continue
+			}
+			burnRates[index].TotalEvents++
+			if !good {
+				burnRates[index].BadEvents++
+			}
+		}
+	}
+
+	report.SLIPercent = 100
+	report.ErrorBudgetRemainingPercent = 100
+	if report.TotalEvents > 0 {
+		badFraction := float64(report.TotalEvents-report.GoodEvents) / float64(report.TotalEvents)
+		report.SLIPercent = roundTo((1-badFraction)*100, 4)
+		report.ErrorBudgetRemainingPercent = roundTo((1-badFraction/errorBudget)*100, 2)
+	}
+
+	report.Status = StatusOK
+	for index := range burnRates {
+		if burnRates[index].TotalEvents > 0 {
+			badFraction := float64(burnRates[index].BadEvents) / float64(burnRates[index].TotalEvents)
+			burnRates[index].Rate = roundTo(badFraction/errorBudget, 2)
+		}
+		if burnRates[index].Rate > burnRates[index].Threshold {
+			report.Status = StatusAtRisk
+		}
+	}
+	report.BurnRates = burnRates
+
+	if report.ErrorBudgetRemainingPercent < 0 {
+		report.Status = StatusBreached
+	}
+
+	return report, nil
+}
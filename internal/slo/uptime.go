@@ -0,0 +1,141 @@
+package slo
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// MaxWindow is the longest window we compute statistics over
+const MaxWindow = 90 * 24 * time.Hour
+
+// Uptime summarizes the availability and latency of a target over a window
+type Uptime struct {
+	TargetID string `json:"targetId"`
+	Window   string `json:"window"`
+	From     int64  `json:"from"`
+	To       int64  `json:"to"`
+
+	// Checks counts the probes that count towards availability, maintenance probes are excluded
+	Checks            int `json:"checks"`
+	UpChecks          int `json:"upChecks"`
+	MaintenanceChecks int `json:"maintenanceChecks"`
+
+	// AvailabilityPercent is 100 when there are no counted checks, nothing was observed down
+	AvailabilityPercent float64 `json:"availabilityPercent"`
+	DowntimeSeconds     int64   `json:"downtimeSeconds"`
+
+	// latency statistics over the probes that got a good answer (up or degraded)
+	MeanLatencyMs float64 `json:"meanLatencyMs"`
+	P50LatencyMs  int64   `json:"p50LatencyMs"`
+	P95LatencyMs  int64   `json:"p95LatencyMs"`
+	P99LatencyMs  int64   `json:"p99LatencyMs"`
+}
+
+// ParseWindow parses a window such as "24h", "7d" or "30d"
+// days are not supported by time.ParseDuration, so "d" is handled here
+func ParseWindow(window string) (time.Duration, error) {
+	var duration time.Duration
+	if days, ok := strings.CutSuffix(window, "d"); ok {
+		dayCount, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.New("invalid window: expected e.g. 24h, 7d or 30d")
+		}
+		duration = time.Duration(dayCount) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(window)
+		if err != nil {
+			return 0, errors.New("invalid window: expected e.g. 24h, 7d or 30d")
+		}
+		duration = parsed
+	}
+
+	if duration <= 0 || duration > MaxWindow {
+		return 0, errors.New("invalid window: must be positive and at most 90d")
+	}
+	return duration, nil
+}
+
+// IsGood reports whether a result counts as available
+// degraded targets answered correctly, so they count as up
+func IsGood(result model.Result) bool {
+	return result.Status == model.StatusUp || result.Status == model.StatusDegraded
+}
+
+// ComputeUptime summarizes the results of one target between from and to
+// interval is the target's probe interval, it caps how much time a single down probe accounts for
+// so a gap in the history (e.g. the scheduler was not running) isn't counted as downtime
+func ComputeUptime(results []model.Result, from, to time.Time, interval time.Duration) Uptime {
+	uptime := Uptime{
+		From: from.Unix(),
+		To:   to.Unix(),
+	}
+
+	// work on a chronologically sorted copy, stores don't all agree on ordering
+	sorted := slices.Clone(results)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp < sorted[j].Timestamp })
+
+	var latencies []int64
+	for index, result := range sorted {
+		if result.Timestamp < uptime.From || result.Timestamp > uptime.To {
+			continue
+		}
+
+		if result.Status == model.StatusMaintenance {
+			uptime.MaintenanceChecks++
+			continue
+		}
+
+		uptime.Checks++
+		if IsGood(result) {
+			uptime.UpChecks++
+			latencies = append(latencies, result.LatencyMs)
+			continue
+		}
+
+		// a down probe accounts for the time until the next probe, at most one interval
+		nextTimestamp := uptime.To
+		if index+1 < len(sorted) {
+			nextTimestamp = min(sorted[index+1].Timestamp, uptime.To)
+		}
+		uptime.DowntimeSeconds += min(nextTimestamp-result.Timestamp, int64(interval/time.Second))
+	}
+
+	uptime.AvailabilityPercent = 100
+	if uptime.Checks > 0 {
+		uptime.AvailabilityPercent = roundTo(float64(uptime.UpChecks)/float64(uptime.Checks)*100, 4)
+	}
+
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+
+		var total int64
+		for _, latency := range latencies {
+			total += latency
+		}
+		uptime.MeanLatencyMs = roundTo(float64(total)/float64(len(latencies)), 2)
+		uptime.P50LatencyMs = percentile(latencies, 50)
+		uptime.P95LatencyMs = percentile(latencies, 95)
+		uptime.P99LatencyMs = percentile(latencies, 99)
+	}
+
+	return uptime
+}
+
+// percentile returns the nearest-rank percentile of an ascending slice
+func percentile(sorted []int64, percent float64) int64 {
+	rank := int(math.Ceil(percent / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// roundTo rounds a value to the given number of decimal places, so json output stays readable
+func roundTo(value float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,677 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/retention"
+	"github.com/sspier/cloudpulse/internal/rollup"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStore persists everything to a single local file with bbolt, an embedded key/value database
+// it is meant for single-box deployments that should survive restarts without DynamoDB
+//
+// layout, every value is json:
+//
+//	targets, maintenance, slos, incidents   id -> item
+//	latest                                  target id -> newest result
+//	results/<target id>                     timestamp + sequence -> result, oldest first
+//	rollups/<rollup.Key>                    bucket start -> rollup, oldest first
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// top level buckets
+var (
+	boltTargetsBucket     = []byte("targets")
+	boltResultsBucket     = []byte("results")
+	boltLatestBucket      = []byte("latest")
+	boltRollupsBucket     = []byte("rollups")
+	boltMaintenanceBucket = []byte("maintenance")
+	boltSLOsBucket        = []byte("slos")
+	boltIncidentsBucket   = []byte("incidents")
+)
+
+// NewBoltStore opens (or creates) the database file at path
+// only one process can have the file open, a second one waits up to a few seconds and then fails
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltTargetsBucket, boltResultsBucket, boltLatestBucket, boltRollupsBucket, boltMaintenanceBucket, boltSLOsBucket, boltIncidentsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets in %s: %w", path, err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Close releases the database file
+func (boltStore *BoltStore) Close() error {
+	return boltStore.db.Close()
+}
+
+// AddTarget stores a new target with a generated ID
+func (boltStore *BoltStore) AddTarget(ctx context.Context, target model.Target) (model.Target, error) {
+	err := boltStore.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltTargetsBucket)
+		target.ID = nextBoltID(bucket)
+		return putBoltJSON(bucket, target.ID, target)
+	})
+	return target, err
+}
+
+// ListTargets returns every target
+func (boltStore *BoltStore) ListTargets(ctx context.Context) ([]model.Target, error) {
+	return listBoltJSON[model.Target](boltStore.db, boltTargetsBucket)
+}
+
+// GetTarget returns a single target, or ErrNotFound
+func (boltStore *BoltStore) GetTarget(ctx context.Context, id string) (model.Target, error) {
+	return getBoltJSON[model.Target](boltStore.db, boltTargetsBucket, id)
+}
+
+// UpdateTarget replaces an existing target, or returns ErrNotFound
+func (boltStore *BoltStore) UpdateTarget(ctx context.Context, target model.Target) (model.Target, error) {
+	err := boltStore.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltTargetsBucket)
+		if bucket.Get([]byte(target.ID)) == nil {
+			return ErrNotFound
+		}
+		return putBoltJSON(bucket, target.ID, target)
+	})
+	if err != nil {
+		return model.Target{}, err
+	}
+	return target, nil
+}
+
+// DeleteTarget removes a target and, if purgeResults is set, its results, latest result and rollups
+func (boltStore *BoltStore) DeleteTarget(ctx context.Context, id string, purgeResults bool) error {
+	return boltStore.db.Update(func(tx *bolt.Tx) error {
+		targets := tx.Bucket(boltTargetsBucket)
+		if targets.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		if err := targets.Delete([]byte(id)); err != nil {
+			return err
+		}
+
+		if !purgeResults {
+			return nil
+		}
+		if err := deleteBoltBucket(tx.Bucket(boltResultsBucket), id); err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltLatestBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		for _, step := range rollup.Steps {
+			if err := deleteBoltBucket(tx.Bucket(boltRollupsBucket), rollup.Key(id, step)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddResult stores a result, updates the latest result of its target and merges it into the rollups
+// all in one transaction
+func (boltStore *BoltStore) AddResult(ctx context.Context, result model.Result) error {
+	// filled in from the target when reading, like the dynamodbav:"-" fields of the DynamoDB store
+	result.Name, result.Paused, result.Labels = "", false, nil
+
+	return boltStore.db.Update(func(tx *bolt.Tx) error {
+		results, err := tx.Bucket(boltResultsBucket).CreateBucketIfNotExists([]byte(result.TargetID))
+		if err != nil {
+			return err
+		}
+		// the sequence keeps results with the same timestamp apart, in the order they were added
+		sequence, err := results.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := putBoltJSON(results, string(boltResultKey(result.Timestamp, sequence)), result); err != nil {
+			return err
+		}
+
+		// a late result must not replace a newer one
+		latestBucket := tx.Bucket(boltLatestBucket)
+		var latest model.Result
+		if encoded := latestBucket.Get([]byte(result.TargetID)); encoded == nil || (json.Unmarshal(encoded, &latest) == nil && latest.Timestamp <= result.Timestamp) {
+			if err := putBoltJSON(latestBucket, result.TargetID, result); err != nil {
+				return err
+			}
+		}
+
+		for _, step := range rollup.Steps {
+			if err := mergeBoltRollup(tx, rollup.Key(result.TargetID, step), rollup.FromResult(result, step)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LatestResults returns the newest result of each target
+func (boltStore *BoltStore) LatestResults(ctx context.Context) ([]model.Result, error) {
+	latestResults := []model.Result{}
+	err := boltStore.db.View(func(tx *bolt.Tx) error {
+		latestBucket := tx.Bucket(boltLatestBucket)
+		return tx.Bucket(boltTargetsBucket).ForEach(func(id, encodedTarget []byte) error {
+			encodedResult := latestBucket.Get(id)
+			if encodedResult == nil {
+				// no results for this target
+				return nil
+			}
+
+			var target model.Target
+			var result model.Result
+			if err := json.Unmarshal(encodedTarget, &target); err != nil {
+				return fmt.Errorf("failed to decode target %s: %w", id, err)
+			}
+			if err := json.Unmarshal(encodedResult, &result); err != nil {
+				return fmt.Errorf("failed to decode latest result of %s: %w", id, err)
+			}
+			result.Name = target.Name
+			result.Paused = target.Paused
+			result.Labels = target.Labels
+			latestResults = append(latestResults, result)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return latestResults, nil
+}
+
+// ResultsForTarget returns the full probe history of a target, oldest first
+func (boltStore *BoltStore) ResultsForTarget(ctx context.Context, targetID string) ([]model.Result, error) {
+	return boltStore.resultsBetween(targetID, boltResultKey(minTimestamp, 0), func(model.Result) bool { return true }, 0)
+}
+
+// ResultsBetween returns every result of a target with from <= timestamp <= to, oldest first
+func (boltStore *BoltStore) ResultsBetween(ctx context.Context, targetID string, from, to time.Time) ([]model.Result, error) {
+	return boltStore.resultsBetween(targetID, boltResultKey(from.Unix(), 0), func(result model.Result) bool {
+		return result.Timestamp <= to.Unix()
+	}, 0)
+}
+
+// QueryResults returns one page of a target's results
+// only the results the page can possibly need are read, PageResults then applies the query to them
+func (boltStore *BoltStore) QueryResults(ctx context.Context, targetID string, query ResultQuery) (ResultPage, error) {
+	position, hasCursor, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return ResultPage{}, err
+	}
+
+	// results with the cursor's timestamp that were already returned, plus the page, plus one to detect the next page
+	limit := query.PageSize() + 1
+	if hasCursor {
+		limit += position.Skip
+	}
+
+	lower, upper := int64(minTimestamp), int64(maxTimestamp)
+	if !query.From.IsZero() {
+		lower = query.From.Unix()
+	}
+	if !query.To.IsZero() {
+		upper = query.To.Unix()
+	}
+
+	var results []model.Result
+	if query.Descending {
+		if hasCursor {
+			upper = min(upper, position.Timestamp)
+		}
+		results, err = boltStore.resultsBackwards(targetID, upper, lower, limit)
+	} else {
+		if hasCursor {
+			lower = max(lower, position.Timestamp)
+		}
+		results, err = boltStore.resultsBetween(targetID, boltResultKey(lower, 0), func(result model.Result) bool {
+			return result.Timestamp <= upper
+		}, limit)
+	}
+	if err != nil {
+		return ResultPage{}, err
+	}
+	return PageResults(results, query)
+}
+
+// resultsBetween reads a target's results from the start key on while keep holds, at most limit of them (0 for all)
+func (boltStore *BoltStore) resultsBetween(targetID string, start []byte, keep func(model.Result) bool, limit int) ([]model.Result, error) {
+	results := []model.Result{}
+	err := boltStore.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltResultsBucket).Bucket([]byte(targetID))
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for key, value := cursor.Seek(start); key != nil; key, value = cursor.Next() {
+			var result model.Result
+			if err := json.Unmarshal(value, &result); err != nil {
+				return fmt.Errorf("failed to decode result of %s: %w", targetID, err)
+			}
+			if !keep(result) || (limit > 0 && len(results) == limit) {
+				return nil
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// resultsBackwards reads up to limit of a target's newest results with lower <= timestamp <= upper,
+// and returns them oldest first
+func (boltStore *BoltStore) resultsBackwards(targetID string, upper, lower int64, limit int) ([]model.Result, error) {
+	results := []model.Result{}
+	err := boltStore.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltResultsBucket).Bucket([]byte(targetID))
+		if bucket == nil {
+			return nil
+		}
+
+		// position on the last key with a timestamp <= upper
+		cursor := bucket.Cursor()
+		var key, value []byte
+		if upper == maxTimestamp {
+			key, value = cursor.Last()
+		} else if key, value = cursor.Seek(boltResultKey(upper+1, 0)); key == nil {
+			key, value = cursor.Last()
+		} else {
+			key, value = cursor.Prev()
+		}
+
+		for ; key != nil && len(results) < limit; key, value = cursor.Prev() {
+			var result model.Result
+			if err := json.Unmarshal(value, &result); err != nil {
+				return fmt.Errorf("failed to decode result of %s: %w", targetID, err)
+			}
+			if result.Timestamp < lower {
+				break
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	slices.Reverse(results)
+	return results, err
+}
+
+// Rollups returns the aggregates of a target for one step within a time range, oldest first
+func (boltStore *BoltStore) Rollups(ctx context.Context, targetID string, step time.Duration, from, to time.Time) ([]model.Rollup, error) {
+	rollups := []model.Rollup{}
+	err := boltStore.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltRollupsBucket).Bucket([]byte(rollup.Key(targetID, step)))
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for key, value := cursor.Seek(boltTimestampKey(from.Unix())); key != nil; key, value = cursor.Next() {
+			stored, err := decodeRollup(value)
+			if err != nil {
+				return err
+			}
+			if stored.Start > to.Unix() {
+				break
+			}
+			rollups = append(rollups, stored)
+		}
+		return nil
+	})
+	return rollups, err
+}
+
+// mergeBoltRollup merges a single result's bucket into the stored one
+func mergeBoltRollup(tx *bolt.Tx, key string, bucket model.Rollup) error {
+	rollups, err := tx.Bucket(boltRollupsBucket).CreateBucketIfNotExists([]byte(key))
+	if err != nil {
+		return err
+	}
+
+	startKey := boltTimestampKey(bucket.Start)
+	merged := bucket
+	if encoded := rollups.Get(startKey); encoded != nil {
+		if merged, err = decodeRollup(encoded); err != nil {
+			return err
+		}
+		rollup.Merge(&merged, bucket)
+	}
+
+	encoded, err := encodeRollup(merged)
+	if err != nil {
+		return err
+	}
+	return rollups.Put(startKey, encoded)
+}
+
+// Compact deletes the results the retention policy of their target no longer keeps and the expired rollups
+func (boltStore *BoltStore) Compact(ctx context.Context, defaults retention.Policy, now time.Time) (int, error) {
+	deleted := 0
+	err := boltStore.db.Update(func(tx *bolt.Tx) error {
+		targets := tx.Bucket(boltTargetsBucket)
+		resultBuckets := tx.Bucket(boltResultsBucket)
+
+		// collect the names first, buckets must not change while ForEachBucket walks them
+		var targetIDs []string
+		err := resultBuckets.ForEachBucket(func(name []byte) error {
+			targetIDs = append(targetIDs, string(name))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range targetIDs {
+			// results of a target deleted without purge follow the global policy
+			var target model.Target
+			if encoded := targets.Get([]byte(id)); encoded != nil {
+				if err := json.Unmarshal(encoded, &target); err != nil {
+					return fmt.Errorf("failed to decode target %s: %w", id, err)
+				}
+			}
+			policy := defaults.ForTarget(target)
+
+			expired, err := deleteOldestBolt(resultBuckets.Bucket([]byte(id)), boltTimestampKey(policy.Cutoff(now)), policy.MaxResults)
+			if err != nil {
+				return err
+			}
+			deleted += expired
+		}
+
+		// every target that ever had a result has a latest result, so these are all the rollups
+		var rollupTargetIDs []string
+		err = tx.Bucket(boltLatestBucket).ForEach(func(id, _ []byte) error {
+			rollupTargetIDs = append(rollupTargetIDs, string(id))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range rollupTargetIDs {
+			for _, step := range rollup.Steps {
+				rollups := tx.Bucket(boltRollupsBucket).Bucket([]byte(rollup.Key(id, step)))
+				if rollups == nil {
+					continue
+				}
+				// a bucket is expired once now reaches rollup.Expiry, i.e. when it started at or before this
+				lastExpired := now.Add(-step - rollup.Retention(step)).Unix()
+				if _, err := deleteOldestBolt(rollups, boltTimestampKey(lastExpired+1), 0); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// deleteOldestBolt deletes the keys of a bucket that sort before limit,
+// and the oldest of the others while more than keep remain (0 keeps them all)
+func deleteOldestBolt(bucket *bolt.Bucket, limit []byte, keep int) (int, error) {
+	remaining := bucket.Stats().KeyN
+
+	// collect first, deleting while the cursor walks the bucket skips keys
+	var expiredKeys [][]byte
+	cursor := bucket.Cursor()
+	for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+		if bytes.Compare(key, limit) >= 0 && (keep <= 0 || remaining <= keep) {
+			break
+		}
+		expiredKeys = append(expiredKeys, bytes.Clone(key))
+		remaining--
+	}
+
+	for _, key := range expiredKeys {
+		if err := bucket.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	return len(expiredKeys), nil
+}
+
+// AddMaintenanceWindow stores a new window with a generated ID
+func (boltStore *BoltStore) AddMaintenanceWindow(ctx context.Context, window model.MaintenanceWindow) (model.MaintenanceWindow, error) {
+	err := boltStore.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltMaintenanceBucket)
+		window.ID = nextBoltID(bucket)
+		return putBoltJSON(bucket, window.ID, window)
+	})
+	return window, err
+}
+
+// ListMaintenanceWindows returns every maintenance window
+func (boltStore *BoltStore) ListMaintenanceWindows(ctx context.Context) ([]model.MaintenanceWindow, error) {
+	return listBoltJSON[model.MaintenanceWindow](boltStore.db, boltMaintenanceBucket)
+}
+
+// GetMaintenanceWindow returns a single window, or ErrNotFound
+func (boltStore *BoltStore) GetMaintenanceWindow(ctx context.Context, id string) (model.MaintenanceWindow, error) {
+	return getBoltJSON[model.MaintenanceWindow](boltStore.db, boltMaintenanceBucket, id)
+}
+
+// DeleteMaintenanceWindow removes a window, or returns ErrNotFound
+func (boltStore *BoltStore) DeleteMaintenanceWindow(ctx context.Context, id string) error {
+	return deleteBoltItem(boltStore.db, boltMaintenanceBucket, id)
+}
+
+// AddSLO stores a new SLO with a generated ID
+func (boltStore *BoltStore) AddSLO(ctx context.Context, objective model.SLO) (model.SLO, error) {
+	err := boltStore.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltSLOsBucket)
+		objective.ID = nextBoltID(bucket)
+		return putBoltJSON(bucket, objective.ID, objective)
+	})
+	return objective, err
+}
+
+// ListSLOs returns every SLO
+func (boltStore *BoltStore) ListSLOs(ctx context.Context) ([]model.SLO, error) {
+	return listBoltJSON[model.SLO](boltStore.db, boltSLOsBucket)
+}
+
+// GetSLO returns a single SLO, or ErrNotFound
+func (boltStore *BoltStore) GetSLO(ctx context.Context, id string) (model.SLO, error) {
+	return getBoltJSON[model.SLO](boltStore.db, boltSLOsBucket, id)
+}
+
+// DeleteSLO removes an SLO, or returns ErrNotFound
+func (boltStore *BoltStore) DeleteSLO(ctx context.Context, id string) error {
+	return deleteBoltItem(boltStore.db, boltSLOsBucket, id)
+}
+
+// AddIncident stores a new incident with a generated ID
+func (boltStore *BoltStore) AddIncident(ctx context.Context, incident model.Incident) (model.Incident, error) {
+	err := boltStore.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltIncidentsBucket)
+		incident.ID = nextBoltID(bucket)
+		return putBoltJSON(bucket, incident.ID, incident)
+	})
+	return incident, err
+}
+
+// ListIncidents returns every incident, newest first
+func (boltStore *BoltStore) ListIncidents(ctx context.Context) ([]model.Incident, error) {
+	incidents, err := listBoltJSON[model.Incident](boltStore.db, boltIncidentsBucket)
+	if err != nil {
+		return nil, err
+	}
+	SortIncidents(incidents)
+	return incidents, nil
+}
+
+// IncidentsForTarget returns the incidents of a single target, newest first
+func (boltStore *BoltStore) IncidentsForTarget(ctx context.Context, targetID string) ([]model.Incident, error) {
+	incidents, err := boltStore.ListIncidents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(incidents, func(incident model.Incident) bool { return incident.TargetID != targetID }), nil
+}
+
+// GetIncident returns a single incident, or ErrNotFound
+func (boltStore *BoltStore) GetIncident(ctx context.Context, id string) (model.Incident, error) {
+	return getBoltJSON[model.Incident](boltStore.db, boltIncidentsBucket, id)
+}
+
+// ResolveIncident marks an incident as resolved at endTime, or returns ErrNotFound
+func (boltStore *BoltStore) ResolveIncident(ctx context.Context, id string, endTime int64) (model.Incident, error) {
+	return boltStore.updateIncident(id, func(incident *model.Incident) {
+		incident.Status = model.IncidentResolved
+		incident.EndTime = endTime
+	})
+}
+
+// AcknowledgeIncident records who acknowledged an incident and when, or returns ErrNotFound
+func (boltStore *BoltStore) AcknowledgeIncident(ctx context.Context, id, acknowledgedBy string, acknowledgedAt int64) (model.Incident, error) {
+	return boltStore.updateIncident(id, func(incident *model.Incident) {
+		incident.AcknowledgedBy = acknowledgedBy
+		incident.AcknowledgedAt = acknowledgedAt
+	})
+}
+
+// AddIncidentNote appends a note to an incident, or returns ErrNotFound
+func (boltStore *BoltStore) AddIncidentNote(ctx context.Context, id string, note model.IncidentNote) (model.Incident, error) {
+	return boltStore.updateIncident(id, func(incident *model.Incident) {
+		incident.Notes = append(incident.Notes, note)
+	})
+}
+
+// updateIncident reads, changes and writes back an incident in one transaction
+func (boltStore *BoltStore) updateIncident(id string, update func(*model.Incident)) (model.Incident, error) {
+	var incident model.Incident
+	err := boltStore.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltIncidentsBucket)
+		encoded := bucket.Get([]byte(id))
+		if encoded == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(encoded, &incident); err != nil {
+			return fmt.Errorf("failed to decode incident %s: %w", id, err)
+		}
+		update(&incident)
+		return putBoltJSON(bucket, id, incident)
+	})
+	if err != nil {
+		return model.Incident{}, err
+	}
+	return incident, nil
+}
+
+// the helpers below cover the "bucket keyed by id" entities, like their DynamoDB counterparts
+
+// nextBoltID generates an ID the same way the DynamoDB store does, the current time in nanoseconds
+// bumped if that is already taken, callers must be in an update transaction
+func nextBoltID(bucket *bolt.Bucket) string {
+	id := timeNow().UnixNano()
+	for bucket.Get([]byte(strconv.FormatInt(id, 10))) != nil {
+		id++
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// putBoltJSON encodes an item as json and stores it under key
+func putBoltJSON(bucket *bolt.Bucket, key string, item any) error {
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode item %s: %w", key, err)
+	}
+	return bucket.Put([]byte(key), encoded)
+}
+
+// getBoltJSON reads and decodes the item with the given id, or returns ErrNotFound
+func getBoltJSON[T any](db *bolt.DB, bucketName []byte, id string) (T, error) {
+	var item T
+	err := db.View(func(tx *bolt.Tx) error {
+		encoded := tx.Bucket(bucketName).Get([]byte(id))
+		if encoded == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(encoded, &item); err != nil {
+			return fmt.Errorf("failed to decode %s item %s: %w", bucketName, id, err)
+		}
+		return nil
+	})
+	return item, err
+}
+
+// listBoltJSON reads and decodes every item of a bucket
+func listBoltJSON[T any](db *bolt.DB, bucketName []byte) ([]T, error) {
+	items := []T{}
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).ForEach(func(id, encoded []byte) error {
+			var item T
+			if err := json.Unmarshal(encoded, &item); err != nil {
+				return fmt.Errorf("failed to decode %s item %s: %w", bucketName, id, err)
+			}
+			items = append(items, item)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// deleteBoltItem deletes the item with the given id, or returns ErrNotFound
+func deleteBoltItem(db *bolt.DB, bucketName []byte, id string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// deleteBoltBucket deletes a nested bucket, a missing one is fine
+func deleteBoltBucket(parent *bolt.Bucket, name string) error {
+	if err := parent.DeleteBucket([]byte(name)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return err
+	}
+	return nil
+}
+
+// the full range of timestamps a key can hold
+const (
+	minTimestamp = -1 << 63
+	maxTimestamp = 1<<63 - 1
+)
+
+// boltTimestampKey encodes a timestamp so that keys sort like the timestamps, negative ones included
+func boltTimestampKey(timestamp int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(timestamp)^(1<<63))
+}
+
+// boltResultKey is the timestamp key followed by the sequence number of the result
+func boltResultKey(timestamp int64, sequence uint64) []byte {
+	return binary.BigEndian.AppendUint64(boltTimestampKey(timestamp), sequence)
+}
@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"sort"
 	"strconv"
@@ -14,15 +16,60 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/retention"
+	"github.com/sspier/cloudpulse/internal/rollup"
+	"github.com/sspier/cloudpulse/internal/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type DynamoDBStore struct {
 	client       *dynamodb.Client
 	targetsTable string
 	resultsTable string
+
+	// optional tables, features backed by them return ErrTableNotConfigured when unset
+	maintenanceTable string
+	slosTable        string
+	incidentsTable   string
+
+	// retention sets the ttl of results, targets can override it
+	retention retention.Policy
+}
+
+// DynamoDBOption configures optional parts of the DynamoDB store
+type DynamoDBOption func(*DynamoDBStore)
+
+// WithMaintenanceTable sets the table that holds maintenance windows (hash key: id)
+func WithMaintenanceTable(tableName string) DynamoDBOption {
+	return func(dynamoDBStore *DynamoDBStore) {
+		dynamoDBStore.maintenanceTable = tableName
+	}
+}
+
+// WithSLOsTable sets the table that holds SLO definitions (hash key: id)
+func WithSLOsTable(tableName string) DynamoDBOption {
+	return func(dynamoDBStore *DynamoDBStore) {
+		dynamoDBStore.slosTable = tableName
+	}
+}
+
+// WithIncidentsTable sets the table that holds incidents (hash key: id)
+func WithIncidentsTable(tableName string) DynamoDBOption {
+	return func(dynamoDBStore *DynamoDBStore) {
+		dynamoDBStore.incidentsTable = tableName
+	}
+}
+
+// WithRetention sets the global retention of results, retention.Default without it
+// DynamoDB deletes expired items through the ttl attribute, a count limit is turned into an age (see retention.Policy.Expiry)
+func WithRetention(policy retention.Policy) DynamoDBOption {
+	return func(dynamoDBStore *DynamoDBStore) {
+		dynamoDBStore.retention = policy
+	}
 }
 
-func NewDynamoDBStore(ctx context.Context, region, targetsTable, resultsTable string) (*DynamoDBStore, error) {
+func NewDynamoDBStore(ctx context.Context, region, targetsTable, resultsTable string, storeOptions ...DynamoDBOption) (*DynamoDBStore, error) {
 	// load the default config for the region
 	awsConfig, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
 	if err != nil {
@@ -38,24 +85,40 @@ func NewDynamoDBStore(ctx context.Context, region, targetsTable, resultsTable st
 	}
 
 	// create a new DynamoDB client from the config
-	return &DynamoDBStore{
+	dynamoDBStore := &DynamoDBStore{
 		client:       dynamodb.NewFromConfig(awsConfig, opts...),
 		targetsTable: targetsTable,
 		resultsTable: resultsTable,
-	}, nil
+		retention:    retention.Default,
+	}
+
+	// apply the optional settings
+	for _, storeOption := range storeOptions {
+		storeOption(dynamoDBStore)
+	}
+
+	return dynamoDBStore, nil
+}
+
+// startSpan opens the span of a store call, callers defer tracing.End(span, &err) to record its error
+func startSpan(ctx context.Context, operation, tableName string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "DynamoDBStore."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemDynamoDB,
+			semconv.DBOperationName(operation),
+			semconv.AWSDynamoDBTableNames(tableName),
+		),
+	)
 }
 
 // AddTarget adds a target to the targets table
-func (dynamoDBStore *DynamoDBStore) AddTarget(ctx context.Context, name, url string) (model.Target, error) {
-	// use the timestamp as ID, similar to the in-memory store
-	targetId := strconv.FormatInt(timeNow().UnixNano(), 10)
+func (dynamoDBStore *DynamoDBStore) AddTarget(ctx context.Context, target model.Target) (_ model.Target, err error) {
+	ctx, span := startSpan(ctx, "AddTarget", dynamoDBStore.targetsTable)
+	defer tracing.End(span, &err)
 
-	// create the target
-	target := model.Target{
-		ID:   targetId,
-		Name: name,
-		URL:  url,
-	}
+	// use the timestamp as ID, similar to the in-memory store
+	target.ID = newID()
 
 	// convert the target to a map for DynamoDB
 	attributeValue, err := attributevalue.MarshalMap(target)
@@ -81,8 +144,11 @@ func (dynamoDBStore *DynamoDBStore) AddTarget(ctx context.Context, name, url str
 }
 
 // ListTargets scans the targets table and returns all targets
-func (dynamoDBStore *DynamoDBStore) ListTargets(ctx context.Context) ([]model.Target, error) {
-	var targets []model.Target
+func (dynamoDBStore *DynamoDBStore) ListTargets(ctx context.Context) (_ []model.Target, err error) {
+	ctx, span := startSpan(ctx, "ListTargets", dynamoDBStore.targetsTable)
+	defer tracing.End(span, &err)
+
+	targets := []model.Target{}
 	// create a paginator to scan the targets table
 	paginator := dynamodb.NewScanPaginator(dynamoDBStore.client, &dynamodb.ScanInput{
 		TableName: aws.String(dynamoDBStore.targetsTable),
@@ -109,91 +175,413 @@ func (dynamoDBStore *DynamoDBStore) ListTargets(ctx context.Context) ([]model.Ta
 	return targets, nil
 }
 
+// GetTarget fetches a single target from the targets table
+func (dynamoDBStore *DynamoDBStore) GetTarget(ctx context.Context, id string) (_ model.Target, err error) {
+	ctx, span := startSpan(ctx, "GetTarget", dynamoDBStore.targetsTable)
+	defer tracing.End(span, &err)
+
+	awsGetItemOutput, err := dynamoDBStore.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(dynamoDBStore.targetsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return model.Target{}, fmt.Errorf("failed to get target: %w", err)
+	}
+
+	// GetItem returns no item (rather than an error) when the key doesn't exist
+	if awsGetItemOutput.Item == nil {
+		return model.Target{}, ErrNotFound
+	}
+
+	var target model.Target
+	if err := attributevalue.UnmarshalMap(awsGetItemOutput.Item, &target); err != nil {
+		return model.Target{}, fmt.Errorf("failed to unmarshal target: %w", err)
+	}
+	return target, nil
+}
+
+// UpdateTarget replaces an existing target in the targets table
+func (dynamoDBStore *DynamoDBStore) UpdateTarget(ctx context.Context, target model.Target) (_ model.Target, err error) {
+	ctx, span := startSpan(ctx, "UpdateTarget", dynamoDBStore.targetsTable)
+	defer tracing.End(span, &err)
+
+	attributeValue, err := attributevalue.MarshalMap(target)
+	if err != nil {
+		return model.Target{}, fmt.Errorf("failed to marshal target: %w", err)
+	}
+
+	// the condition turns the put into an update: it fails instead of creating a new item
+	_, err = dynamoDBStore.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(dynamoDBStore.targetsTable),
+		Item:                attributeValue,
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return model.Target{}, ErrNotFound
+		}
+		return model.Target{}, fmt.Errorf("failed to put item to dynamodb: %w", err)
+	}
+
+	return target, nil
+}
+
+// DeleteTarget removes a target from the targets table and optionally purges its results
+func (dynamoDBStore *DynamoDBStore) DeleteTarget(ctx context.Context, id string, purgeResults bool) (err error) {
+	ctx, span := startSpan(ctx, "DeleteTarget", dynamoDBStore.targetsTable)
+	defer tracing.End(span, &err)
+
+	_, err = dynamoDBStore.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(dynamoDBStore.targetsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete target: %w", err)
+	}
+
+	if !purgeResults {
+		return nil
+	}
+	return dynamoDBStore.purgeResults(ctx, id)
+}
+
+// purgeResults deletes every result, the latest result item and the rollups of a target
+// DynamoDB has no "delete by partition key", so we query the keys and batch delete them
+func (dynamoDBStore *DynamoDBStore) purgeResults(ctx context.Context, targetID string) error {
+	partitionKeys := []string{targetID, targetID + latestResultSuffix}
+	for _, step := range rollup.Steps {
+		partitionKeys = append(partitionKeys, rollup.Key(targetID, step))
+	}
+
+	for _, partitionKey := range partitionKeys {
+		// only fetch the key attributes, timestamp is a reserved word so it needs a placeholder
+		paginator := dynamodb.NewQueryPaginator(dynamoDBStore.client, &dynamodb.QueryInput{
+			TableName:              aws.String(dynamoDBStore.resultsTable),
+			KeyConditionExpression: aws.String("target_id = :tid"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":tid": &types.AttributeValueMemberS{Value: partitionKey},
+			},
+			ProjectionExpression:     aws.String("target_id, #ts"),
+			ExpressionAttributeNames: map[string]string{"#ts": "timestamp"},
+		})
+
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to query results for purge: %w", err)
+			}
+			if err := dynamoDBStore.batchDelete(ctx, dynamoDBStore.resultsTable, page.Items); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// batchDelete deletes the given keys from a table, 25 at a time (the BatchWriteItem limit)
+func (dynamoDBStore *DynamoDBStore) batchDelete(ctx context.Context, tableName string, keys []map[string]types.AttributeValue) error {
+	const batchSize = 25
+
+	for start := 0; start < len(keys); start += batchSize {
+		end := min(start+batchSize, len(keys))
+
+		writeRequests := make([]types.WriteRequest, 0, end-start)
+		for _, key := range keys[start:end] {
+			writeRequests = append(writeRequests, types.WriteRequest{
+				DeleteRequest: &types.DeleteRequest{Key: key},
+			})
+		}
+
+		// BatchWriteItem may leave some items unprocessed under throttling, resubmit them
+		pending := map[string][]types.WriteRequest{tableName: writeRequests}
+		err := retryUnprocessed(ctx, func() (bool, error) {
+			awsBatchWriteOutput, err := dynamoDBStore.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: pending,
+			})
+			if err != nil {
+				return false, err
+			}
+			pending = awsBatchWriteOutput.UnprocessedItems
+			return len(pending) == 0, nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to batch delete from %s: %w", tableName, err)
+		}
+	}
+	return nil
+}
+
+// maxBatchAttempts bounds how often a batch call is made for the same items, a table throttled for longer is an error
+const maxBatchAttempts = 8
+
+// retryUnprocessed makes a batch call until it reports that no items are left unprocessed
+// it backs off a little more on every retry so we don't hammer a throttled table,
+// and gives up after maxBatchAttempts calls or as soon as ctx is done
+func retryUnprocessed(ctx context.Context, batch func() (done bool, err error)) error {
+	for attempt := 1; ; attempt++ {
+		done, err := batch()
+		if err != nil || done {
+			return err
+		}
+		if attempt == maxBatchAttempts {
+			return fmt.Errorf("items still unprocessed after %d attempts", maxBatchAttempts)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+		}
+	}
+}
+
+// isConditionalCheckFailed reports whether a write was rejected by its condition expression
+func isConditionalCheckFailed(err error) bool {
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	return errors.As(err, &conditionalCheckFailed)
+}
+
 // AddResult adds a result to the results table
+// it looks the target up for its retention policy, AddTargetResult skips that read
 func (dynamoDBStore *DynamoDBStore) AddResult(ctx context.Context, result model.Result) error {
+	// the ttl follows the retention policy of the target, a deleted or unreadable target falls back to the global policy
+	// dropping the result over a failed lookup would lose more than a ttl that is off
+	target, err := dynamoDBStore.GetTarget(ctx, result.TargetID)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			log.Printf("failed to load target %s, storing its result with the global retention: %v", result.TargetID, err)
+		}
+		target = model.Target{ID: result.TargetID}
+	}
+	return dynamoDBStore.AddTargetResult(ctx, target, result)
+}
+
+// AddTargetResult adds a result of target to the results table, with a ttl from the retention policy of target
+func (dynamoDBStore *DynamoDBStore) AddTargetResult(ctx context.Context, target model.Target, result model.Result) (err error) {
+	ctx, span := startSpan(ctx, "AddResult", dynamoDBStore.resultsTable)
+	defer tracing.End(span, &err)
+
 	// convert the result to a map for DynamoDB
 	attributeValue, err := attributevalue.MarshalMap(result)
 	if err != nil {
 		return fmt.Errorf("failed to marshal result: %w", err)
 	}
 
-	// add TTL if needed
-	attributeValue["ttl"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(timeNow().Add(30*24*time.Hour).Unix(), 10)}
+	if expiry := dynamoDBStore.retention.ForTarget(target).Expiry(result, target.Interval()); !expiry.IsZero() {
+		attributeValue["ttl"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiry.Unix(), 10)}
+	}
 
 	// insert the result into the results table
+	// the key is target and timestamp in seconds, so this replaces a result of the same target in the same second
 	_, err = dynamoDBStore.client.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName: aws.String(dynamoDBStore.resultsTable),
 		Item:      attributeValue,
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	if err := dynamoDBStore.putLatestResult(ctx, result); err != nil {
+		return err
+	}
+
+	// the raw result is stored either way, a failed rollup only leaves a gap in the long-range charts
+	dynamoDBStore.mergeRollups(ctx, result)
+	return nil
 }
 
-// ResultsForTarget queries the results table for a specific target
-func (dynamoDBStore *DynamoDBStore) ResultsForTarget(ctx context.Context, targetID string) ([]model.Result, error) {
-	// query the results table for a specific target
-	// awsQueryInput is a pointer to a QueryInput struct from the AWS SDK
-	awsQueryInput := &dynamodb.QueryInput{
+// ResultsForTarget queries the full history of a target, oldest first
+func (dynamoDBStore *DynamoDBStore) ResultsForTarget(ctx context.Context, targetID string) (_ []model.Result, err error) {
+	ctx, span := startSpan(ctx, "ResultsForTarget", dynamoDBStore.resultsTable)
+	defer tracing.End(span, &err)
+
+	// query the results table for a specific target, a query returns at most 1MB so follow the pages
+	paginator := dynamodb.NewQueryPaginator(dynamoDBStore.client, &dynamodb.QueryInput{
 		TableName:              aws.String(dynamoDBStore.resultsTable),
 		KeyConditionExpression: aws.String("target_id = :tid"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":tid": &types.AttributeValueMemberS{Value: targetID},
 		},
-		ScanIndexForward: aws.Bool(false), // descending order
-		Limit:            aws.Int32(100),  // limit to last 100 results for now
+		ScanIndexForward: aws.Bool(true), // ascending order, like every other store
+	})
+
+	results := []model.Result{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query results: %w", err)
+		}
+
+		var pageResults []model.Result
+		// unmarshal the results into a list of results
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageResults); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal results: %w", err)
+		}
+		results = append(results, pageResults...)
 	}
 
-	// query the results table
-	// output is a pointer to a QueryOutput struct from the AWS SDK
-	awsQueryOutput, err := dynamoDBStore.client.Query(ctx, awsQueryInput)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query results: %w", err)
+	return results, nil
+}
+
+// ResultsBetween queries the results of a target within a time range, oldest first
+func (dynamoDBStore *DynamoDBStore) ResultsBetween(ctx context.Context, targetID string, from, to time.Time) (_ []model.Result, err error) {
+	ctx, span := startSpan(ctx, "ResultsBetween", dynamoDBStore.resultsTable)
+	defer tracing.End(span, &err)
+
+	// timestamp is a reserved word in DynamoDB expressions, so it needs a placeholder
+	paginator := dynamodb.NewQueryPaginator(dynamoDBStore.client, &dynamodb.QueryInput{
+		TableName:              aws.String(dynamoDBStore.resultsTable),
+		KeyConditionExpression: aws.String("target_id = :tid AND #ts BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#ts": "timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":tid":  &types.AttributeValueMemberS{Value: targetID},
+			":from": &types.AttributeValueMemberN{Value: strconv.FormatInt(from.Unix(), 10)},
+			":to":   &types.AttributeValueMemberN{Value: strconv.FormatInt(to.Unix(), 10)},
+		},
+		ScanIndexForward: aws.Bool(true), // ascending order
+	})
+
+	results := []model.Result{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query results: %w", err)
+		}
+
+		var pageResults []model.Result
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageResults); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal results: %w", err)
+		}
+		results = append(results, pageResults...)
 	}
 
-	var results []model.Result
-	// unmarshal the results into a list of results
-	err = attributevalue.UnmarshalListOfMaps(awsQueryOutput.Items, &results)
+	return results, nil
+}
+
+// QueryResults queries one page of a target's results
+// the cursor maps to DynamoDB's ExclusiveStartKey, one extra item is read to know whether another page exists
+func (dynamoDBStore *DynamoDBStore) QueryResults(ctx context.Context, targetID string, query ResultQuery) (_ ResultPage, err error) {
+	ctx, span := startSpan(ctx, "QueryResults", dynamoDBStore.resultsTable)
+	defer tracing.End(span, &err)
+
+	position, hasCursor, err := decodeCursor(query.Cursor)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal results: %w", err)
+		return ResultPage{}, err
 	}
 
-	return results, nil
+	// timestamp is a reserved word in DynamoDB expressions, so it needs a placeholder
+	keyCondition := "target_id = :tid"
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{
+		":tid": &types.AttributeValueMemberS{Value: targetID},
+	}
+	from, to := query.From, query.To
+	switch {
+	case !from.IsZero() && !to.IsZero():
+		keyCondition += " AND #ts BETWEEN :from AND :to"
+	case !from.IsZero():
+		keyCondition += " AND #ts >= :from"
+	case !to.IsZero():
+		keyCondition += " AND #ts <= :to"
+	}
+	if !from.IsZero() {
+		names["#ts"] = "timestamp"
+		values[":from"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(from.Unix(), 10)}
+	}
+	if !to.IsZero() {
+		names["#ts"] = "timestamp"
+		values[":to"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(to.Unix(), 10)}
+	}
+
+	pageSize := query.PageSize()
+	awsQueryInput := &dynamodb.QueryInput{
+		TableName:                 aws.String(dynamoDBStore.resultsTable),
+		KeyConditionExpression:    aws.String(keyCondition),
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(!query.Descending),
+		Limit:                     aws.Int32(int32(pageSize + 1)),
+	}
+	if len(names) > 0 {
+		awsQueryInput.ExpressionAttributeNames = names
+	}
+	if hasCursor {
+		// (target_id, timestamp) is the primary key, so the cursor's timestamp identifies the last item
+		awsQueryInput.ExclusiveStartKey = map[string]types.AttributeValue{
+			"target_id": &types.AttributeValueMemberS{Value: targetID},
+			"timestamp": &types.AttributeValueMemberN{Value: strconv.FormatInt(position.Timestamp, 10)},
+		}
+	}
+
+	// a query stops at 1MB, keep going until the page (plus the extra item) is full or the results run out
+	results := []model.Result{}
+	for {
+		awsQueryOutput, err := dynamoDBStore.client.Query(ctx, awsQueryInput)
+		if err != nil {
+			return ResultPage{}, fmt.Errorf("failed to query results: %w", err)
+		}
+
+		var pageResults []model.Result
+		if err := attributevalue.UnmarshalListOfMaps(awsQueryOutput.Items, &pageResults); err != nil {
+			return ResultPage{}, fmt.Errorf("failed to unmarshal results: %w", err)
+		}
+		results = append(results, pageResults...)
+
+		if len(results) > pageSize || len(awsQueryOutput.LastEvaluatedKey) == 0 {
+			break
+		}
+		awsQueryInput.ExclusiveStartKey = awsQueryOutput.LastEvaluatedKey
+		awsQueryInput.Limit = aws.Int32(int32(pageSize + 1 - len(results)))
+	}
+
+	page := ResultPage{Results: results}
+	if len(results) > pageSize {
+		page.Results = results[:pageSize]
+		page.NextCursor = encodeCursor(pageEnd(page.Results))
+	}
+	return page, nil
 }
 
 // LatestResults gets the latest result for each target
-// for now, we fetch all targets and query one latest result for each
-func (dynamoDBStore *DynamoDBStore) LatestResults(ctx context.Context) ([]model.Result, error) {
+// one scan of the targets table plus a batch get of the latest result items that AddResult maintains
+func (dynamoDBStore *DynamoDBStore) LatestResults(ctx context.Context) (_ []model.Result, err error) {
+	ctx, span := startSpan(ctx, "LatestResults", dynamoDBStore.resultsTable)
+	defer tracing.End(span, &err)
+
 	// list all listOfTargets
 	listOfTargets, err := dynamoDBStore.ListTargets(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var latestResults []model.Result
+	targetIDs := make([]string, 0, len(listOfTargets))
 	for _, target := range listOfTargets {
-		// query just 1 item
-		awsQueryInput := &dynamodb.QueryInput{
-			TableName:              aws.String(dynamoDBStore.resultsTable),
-			KeyConditionExpression: aws.String("target_id = :tid"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":tid": &types.AttributeValueMemberS{Value: target.ID},
-			},
-			ScanIndexForward: aws.Bool(false),
-			Limit:            aws.Int32(1),
-		}
+		targetIDs = append(targetIDs, target.ID)
+	}
+	resultsByTarget, err := dynamoDBStore.batchGetLatestResults(ctx, targetIDs)
+	if err != nil {
+		return nil, err
+	}
 
-		awsQueryOutput, err := dynamoDBStore.client.Query(ctx, awsQueryInput)
-		if err != nil {
-			// LOG but continue?
+	latestResults := make([]model.Result, 0, len(resultsByTarget))
+	for _, target := range listOfTargets {
+		result, ok := resultsByTarget[target.ID]
+		if !ok {
+			// not probed since it was added
 			continue
 		}
-
-		if len(awsQueryOutput.Items) > 0 {
-			var result model.Result
-			_ = attributevalue.UnmarshalMap(awsQueryOutput.Items[0], &result)
-			result.Name = target.Name
-			latestResults = append(latestResults, result)
-		}
+		result.Name = target.Name
+		result.Paused = target.Paused
+		result.Labels = target.Labels
+		latestResults = append(latestResults, result)
 	}
 	return latestResults, nil
 }
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/tracing"
+)
+
+// AddIncident adds an incident to the incidents table
+func (dynamoDBStore *DynamoDBStore) AddIncident(ctx context.Context, incident model.Incident) (_ model.Incident, err error) {
+	ctx, span := startSpan(ctx, "AddIncident", dynamoDBStore.incidentsTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.incidentsTable == "" {
+		return model.Incident{}, ErrTableNotConfigured
+	}
+
+	// use the timestamp as ID, same as targets
+	incident.ID = newID()
+
+	if err := dynamoDBStore.putItem(ctx, dynamoDBStore.incidentsTable, incident); err != nil {
+		return model.Incident{}, err
+	}
+	return incident, nil
+}
+
+// ListIncidents scans the incidents table, newest first
+func (dynamoDBStore *DynamoDBStore) ListIncidents(ctx context.Context) (_ []model.Incident, err error) {
+	ctx, span := startSpan(ctx, "ListIncidents", dynamoDBStore.incidentsTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.incidentsTable == "" {
+		return nil, ErrTableNotConfigured
+	}
+
+	incidents, err := scanItems[model.Incident](ctx, dynamoDBStore, dynamoDBStore.incidentsTable)
+	if err != nil {
+		return nil, err
+	}
+	SortIncidents(incidents)
+	return incidents, nil
+}
+
+// IncidentsForTarget returns the incidents of a single target, newest first
+// outages are rare enough that scanning the table is cheaper than maintaining an index on target_id
+func (dynamoDBStore *DynamoDBStore) IncidentsForTarget(ctx context.Context, targetID string) (_ []model.Incident, err error) {
+	ctx, span := startSpan(ctx, "IncidentsForTarget", dynamoDBStore.incidentsTable)
+	defer tracing.End(span, &err)
+
+	incidents, err := dynamoDBStore.ListIncidents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	targetIncidents := []model.Incident{}
+	for _, incident := range incidents {
+		if incident.TargetID == targetID {
+			targetIncidents = append(targetIncidents, incident)
+		}
+	}
+	return targetIncidents, nil
+}
+
+// GetIncident fetches a single incident from the incidents table
+func (dynamoDBStore *DynamoDBStore) GetIncident(ctx context.Context, id string) (_ model.Incident, err error) {
+	ctx, span := startSpan(ctx, "GetIncident", dynamoDBStore.incidentsTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.incidentsTable == "" {
+		return model.Incident{}, ErrTableNotConfigured
+	}
+	return getItemByID[model.Incident](ctx, dynamoDBStore, dynamoDBStore.incidentsTable, id)
+}
+
+// ResolveIncident sets the status and end time of an incident
+func (dynamoDBStore *DynamoDBStore) ResolveIncident(ctx context.Context, id string, endTime int64) (_ model.Incident, err error) {
+	ctx, span := startSpan(ctx, "ResolveIncident", dynamoDBStore.incidentsTable)
+	defer tracing.End(span, &err)
+
+	// status is a reserved word in DynamoDB, hence the placeholder
+	names := map[string]string{"#status": "status"}
+	return dynamoDBStore.updateIncident(ctx, id, "SET #status = :status, end_time = :end_time", names, map[string]types.AttributeValue{
+		":status":   &types.AttributeValueMemberS{Value: model.IncidentResolved},
+		":end_time": &types.AttributeValueMemberN{Value: strconv.FormatInt(endTime, 10)},
+	})
+}
+
+// AcknowledgeIncident sets who acknowledged an incident and when
+func (dynamoDBStore *DynamoDBStore) AcknowledgeIncident(ctx context.Context, id, acknowledgedBy string, acknowledgedAt int64) (_ model.Incident, err error) {
+	ctx, span := startSpan(ctx, "AcknowledgeIncident", dynamoDBStore.incidentsTable)
+	defer tracing.End(span, &err)
+
+	return dynamoDBStore.updateIncident(ctx, id, "SET acknowledged_by = :acknowledged_by, acknowledged_at = :acknowledged_at", nil, map[string]types.AttributeValue{
+		":acknowledged_by": &types.AttributeValueMemberS{Value: acknowledgedBy},
+		":acknowledged_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(acknowledgedAt, 10)},
+	})
+}
+
+// AddIncidentNote appends a note to the incident's notes list
+func (dynamoDBStore *DynamoDBStore) AddIncidentNote(ctx context.Context, id string, note model.IncidentNote) (_ model.Incident, err error) {
+	ctx, span := startSpan(ctx, "AddIncidentNote", dynamoDBStore.incidentsTable)
+	defer tracing.End(span, &err)
+
+	noteValue, err := attributevalue.Marshal(note)
+	if err != nil {
+		return model.Incident{}, fmt.Errorf("failed to marshal incident note: %w", err)
+	}
+
+	return dynamoDBStore.updateIncident(ctx, id, "SET notes = list_append(if_not_exists(notes, :empty), :note)", nil, map[string]types.AttributeValue{
+		":empty": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+		":note":  &types.AttributeValueMemberL{Value: []types.AttributeValue{noteValue}},
+	})
+}
+
+// updateIncident runs an update expression against an existing incident and returns the updated item
+// updating single attributes (rather than putting the whole item) keeps concurrent updates from losing each other's changes
+func (dynamoDBStore *DynamoDBStore) updateIncident(ctx context.Context, id, updateExpression string, names map[string]string, values map[string]types.AttributeValue) (model.Incident, error) {
+	if dynamoDBStore.incidentsTable == "" {
+		return model.Incident{}, ErrTableNotConfigured
+	}
+
+	updateInput := &dynamodb.UpdateItemInput{
+		TableName: aws.String(dynamoDBStore.incidentsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String("attribute_exists(id)"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	awsUpdateItemOutput, err := dynamoDBStore.client.UpdateItem(ctx, updateInput)
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return model.Incident{}, ErrNotFound
+		}
+		return model.Incident{}, fmt.Errorf("failed to update incident: %w", err)
+	}
+
+	var incident model.Incident
+	if err := attributevalue.UnmarshalMap(awsUpdateItemOutput.Attributes, &incident); err != nil {
+		return model.Incident{}, fmt.Errorf("failed to unmarshal incident: %w", err)
+	}
+	return incident, nil
+}
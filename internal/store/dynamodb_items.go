@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// the helpers below cover the simple "table keyed by id" entities (maintenance windows, slos, ...)
+// targets and results predate them and keep their own, more specific code
+
+// putItem marshals an item and writes it to the table
+func (dynamoDBStore *DynamoDBStore) putItem(ctx context.Context, tableName string, item any) error {
+	attributeValue, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal item for %s: %w", tableName, err)
+	}
+
+	_, err = dynamoDBStore.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item:      attributeValue,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put item to %s: %w", tableName, err)
+	}
+	return nil
+}
+
+// getItemByID fetches and unmarshals the item with the given id, or returns ErrNotFound
+func getItemByID[T any](ctx context.Context, dynamoDBStore *DynamoDBStore, tableName, id string) (T, error) {
+	var item T
+
+	awsGetItemOutput, err := dynamoDBStore.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return item, fmt.Errorf("failed to get item from %s: %w", tableName, err)
+	}
+	if awsGetItemOutput.Item == nil {
+		return item, ErrNotFound
+	}
+
+	if err := attributevalue.UnmarshalMap(awsGetItemOutput.Item, &item); err != nil {
+		return item, fmt.Errorf("failed to unmarshal item from %s: %w", tableName, err)
+	}
+	return item, nil
+}
+
+// scanItems reads and unmarshals every item of the table
+func scanItems[T any](ctx context.Context, dynamoDBStore *DynamoDBStore, tableName string) ([]T, error) {
+	items := []T{}
+	paginator := dynamodb.NewScanPaginator(dynamoDBStore.client, &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", tableName, err)
+		}
+
+		var pageItems []T
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageItems); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal items from %s: %w", tableName, err)
+		}
+		items = append(items, pageItems...)
+	}
+	return items, nil
+}
+
+// deleteItemByID deletes the item with the given id, or returns ErrNotFound
+func (dynamoDBStore *DynamoDBStore) deleteItemByID(ctx context.Context, tableName, id string) error {
+	_, err := dynamoDBStore.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete item from %s: %w", tableName, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// the most recent result of each target is kept in one extra item of the results table,
+// partition key "<target-id>#latest" and timestamp 0, so listing them is a batch get instead of a query per target
+
+// latestResultSuffix is appended to the target id to form the partition key of the latest result item
+const latestResultSuffix = "#latest"
+
+// latestResultItem is how the latest result is stored, the result itself is nested so its timestamp
+// doesn't collide with the sort key
+type latestResultItem struct {
+	TargetID string       `dynamodbav:"target_id"`
+	Result   model.Result `dynamodbav:"result"`
+	// ResultTimestamp duplicates Result.Timestamp at the top level so conditions can use it
+	ResultTimestamp int64 `dynamodbav:"result_timestamp"`
+}
+
+// latestResultKey returns the key of a target's latest result item
+func latestResultKey(targetID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"target_id": &types.AttributeValueMemberS{Value: targetID + latestResultSuffix},
+		"timestamp": &types.AttributeValueMemberN{Value: "0"},
+	}
+}
+
+// putLatestResult replaces the latest result item of a target, unless it already holds a newer result
+// results can arrive out of order when the runner and a manual check overlap
+func (dynamoDBStore *DynamoDBStore) putLatestResult(ctx context.Context, result model.Result) error {
+	attributeValue, err := attributevalue.MarshalMap(latestResultItem{
+		TargetID:        result.TargetID + latestResultSuffix,
+		Result:          result,
+		ResultTimestamp: result.Timestamp,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal latest result: %w", err)
+	}
+	attributeValue["timestamp"] = latestResultKey(result.TargetID)["timestamp"]
+
+	_, err = dynamoDBStore.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(dynamoDBStore.resultsTable),
+		Item:                attributeValue,
+		ConditionExpression: aws.String("attribute_not_exists(result_timestamp) OR result_timestamp <= :ts"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ts": &types.AttributeValueMemberN{Value: strconv.FormatInt(result.Timestamp, 10)},
+		},
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			// a newer result is already recorded, nothing to do
+			return nil
+		}
+		return fmt.Errorf("failed to put latest result: %w", err)
+	}
+	return nil
+}
+
+// batchGetLatestResults reads the latest result items of the given targets, keyed by target id
+// targets that never got a result are missing from the map
+func (dynamoDBStore *DynamoDBStore) batchGetLatestResults(ctx context.Context, targetIDs []string) (map[string]model.Result, error) {
+	// BatchGetItem takes at most 100 keys per call
+	const batchSize = 100
+
+	latestResults := make(map[string]model.Result, len(targetIDs))
+	for start := 0; start < len(targetIDs); start += batchSize {
+		end := min(start+batchSize, len(targetIDs))
+
+		keys := make([]map[string]types.AttributeValue, 0, end-start)
+		for _, targetID := range targetIDs[start:end] {
+			keys = append(keys, latestResultKey(targetID))
+		}
+
+		// like BatchWriteItem, BatchGetItem may leave keys unprocessed under throttling, ask for them again
+		pending := map[string]types.KeysAndAttributes{dynamoDBStore.resultsTable: {Keys: keys}}
+		err := retryUnprocessed(ctx, func() (bool, error) {
+			awsBatchGetOutput, err := dynamoDBStore.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: pending,
+			})
+			if err != nil {
+				return false, err
+			}
+
+			var items []latestResultItem
+			if err := attributevalue.UnmarshalListOfMaps(awsBatchGetOutput.Responses[dynamoDBStore.resultsTable], &items); err != nil {
+				return false, fmt.Errorf("failed to unmarshal latest results: %w", err)
+			}
+			for _, item := range items {
+				latestResults[strings.TrimSuffix(item.TargetID, latestResultSuffix)] = item.Result
+			}
+			pending = awsBatchGetOutput.UnprocessedKeys
+			return len(pending) == 0, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to batch get latest results: %w", err)
+		}
+	}
+	return latestResults, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/tracing"
+)
+
+// AddMaintenanceWindow adds a window to the maintenance table
+func (dynamoDBStore *DynamoDBStore) AddMaintenanceWindow(ctx context.Context, window model.MaintenanceWindow) (_ model.MaintenanceWindow, err error) {
+	ctx, span := startSpan(ctx, "AddMaintenanceWindow", dynamoDBStore.maintenanceTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.maintenanceTable == "" {
+		return model.MaintenanceWindow{}, ErrTableNotConfigured
+	}
+
+	// use the timestamp as ID, same as targets
+	window.ID = newID()
+
+	if err := dynamoDBStore.putItem(ctx, dynamoDBStore.maintenanceTable, window); err != nil {
+		return model.MaintenanceWindow{}, err
+	}
+	return window, nil
+}
+
+// ListMaintenanceWindows scans the maintenance table
+// without a maintenance table there are simply no windows, so probes are never suppressed
+func (dynamoDBStore *DynamoDBStore) ListMaintenanceWindows(ctx context.Context) (_ []model.MaintenanceWindow, err error) {
+	ctx, span := startSpan(ctx, "ListMaintenanceWindows", dynamoDBStore.maintenanceTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.maintenanceTable == "" {
+		return []model.MaintenanceWindow{}, nil
+	}
+	return scanItems[model.MaintenanceWindow](ctx, dynamoDBStore, dynamoDBStore.maintenanceTable)
+}
+
+// GetMaintenanceWindow fetches a single window from the maintenance table
+func (dynamoDBStore *DynamoDBStore) GetMaintenanceWindow(ctx context.Context, id string) (_ model.MaintenanceWindow, err error) {
+	ctx, span := startSpan(ctx, "GetMaintenanceWindow", dynamoDBStore.maintenanceTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.maintenanceTable == "" {
+		return model.MaintenanceWindow{}, ErrTableNotConfigured
+	}
+	return getItemByID[model.MaintenanceWindow](ctx, dynamoDBStore, dynamoDBStore.maintenanceTable, id)
+}
+
+// DeleteMaintenanceWindow removes a window from the maintenance table
+func (dynamoDBStore *DynamoDBStore) DeleteMaintenanceWindow(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteMaintenanceWindow", dynamoDBStore.maintenanceTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.maintenanceTable == "" {
+		return ErrTableNotConfigured
+	}
+	return dynamoDBStore.deleteItemByID(ctx, dynamoDBStore.maintenanceTable, id)
+}
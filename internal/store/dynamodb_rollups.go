@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/sspier/cloudpulse/internal/metrics"
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/rollup"
+	"github.com/sspier/cloudpulse/internal/tracing"
+)
+
+// rollups live in the results table under the partition key rollup.Key(targetID, step)
+// and the bucket start as the timestamp, so they expire through the same ttl attribute as results
+
+// maxRollupAttempts bounds the retries when another writer updated the same bucket concurrently
+const maxRollupAttempts = 10
+
+// errRollupConflict is returned when a bucket kept changing under us
+var errRollupConflict = errors.New("rollup bucket was updated concurrently")
+
+// mergeRollups merges a result into every step's bucket
+// it runs after the raw result is stored, so failures are logged and counted rather than returned:
+// a caller seeing an error would treat the result as lost, while it only leaves a gap in the long-range charts
+func (dynamoDBStore *DynamoDBStore) mergeRollups(ctx context.Context, result model.Result) {
+	for _, step := range rollup.Steps {
+		if err := dynamoDBStore.mergeRollup(ctx, rollup.Key(result.TargetID, step), step, rollup.FromResult(result, step)); err != nil {
+			log.Printf("failed to update %s rollup of %s: %v", rollup.StepName(step), result.TargetID, err)
+			metrics.RollupMergeFailed(rollup.StepName(step))
+		}
+	}
+}
+
+// mergeRollup reads a bucket, merges into it and writes it back
+// min and max can't be expressed as an atomic update, so writes are guarded by a version attribute instead
+func (dynamoDBStore *DynamoDBStore) mergeRollup(ctx context.Context, key string, step time.Duration, bucket model.Rollup) error {
+	itemKey := map[string]types.AttributeValue{
+		"target_id": &types.AttributeValueMemberS{Value: key},
+		"timestamp": &types.AttributeValueMemberN{Value: strconv.FormatInt(bucket.Start, 10)},
+	}
+
+	for attempt := 0; attempt < maxRollupAttempts; attempt++ {
+		// writers that lost a race back off for a random time, so they don't all retry in lockstep
+		if attempt > 0 {
+			time.Sleep(rand.N(time.Duration(attempt) * 20 * time.Millisecond))
+		}
+		awsGetItemOutput, err := dynamoDBStore.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(dynamoDBStore.resultsTable),
+			Key:            itemKey,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get rollup: %w", err)
+		}
+
+		merged := bucket
+		var version int64
+		if awsGetItemOutput.Item != nil {
+			var stored struct {
+				model.Rollup
+				Version int64 `dynamodbav:"version"`
+			}
+			if err := attributevalue.UnmarshalMap(awsGetItemOutput.Item, &stored); err != nil {
+				return fmt.Errorf("failed to unmarshal rollup: %w", err)
+			}
+			merged = stored.Rollup
+			version = stored.Version
+			rollup.Merge(&merged, bucket)
+		}
+
+		attributeValue, err := attributevalue.MarshalMap(merged)
+		if err != nil {
+			return fmt.Errorf("failed to marshal rollup: %w", err)
+		}
+		attributeValue["target_id"] = itemKey["target_id"]
+		attributeValue["version"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(version+1, 10)}
+		// keep the bucket for the step's retention after it closes
+		expiry := rollup.Expiry(bucket.Start, step)
+		attributeValue["ttl"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiry.Unix(), 10)}
+
+		// only write if nobody else did since we read, a missing version means a new bucket
+		condition := "attribute_not_exists(version)"
+		values := map[string]types.AttributeValue(nil)
+		if awsGetItemOutput.Item != nil {
+			condition = "version = :version"
+			values = map[string]types.AttributeValue{
+				":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+			}
+		}
+
+		_, err = dynamoDBStore.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                 aws.String(dynamoDBStore.resultsTable),
+			Item:                      attributeValue,
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeValues: values,
+		})
+		if err == nil {
+			return nil
+		}
+		if !isConditionalCheckFailed(err) {
+			return fmt.Errorf("failed to put rollup: %w", err)
+		}
+	}
+	return errRollupConflict
+}
+
+// Rollups queries the aggregates of a target for one step within a time range, oldest first
+func (dynamoDBStore *DynamoDBStore) Rollups(ctx context.Context, targetID string, step time.Duration, from, to time.Time) (_ []model.Rollup, err error) {
+	ctx, span := startSpan(ctx, "Rollups", dynamoDBStore.resultsTable)
+	defer tracing.End(span, &err)
+
+	// timestamp is a reserved word in DynamoDB expressions, so it needs a placeholder
+	paginator := dynamodb.NewQueryPaginator(dynamoDBStore.client, &dynamodb.QueryInput{
+		TableName:              aws.String(dynamoDBStore.resultsTable),
+		KeyConditionExpression: aws.String("target_id = :key AND #ts BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#ts": "timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":key":  &types.AttributeValueMemberS{Value: rollup.Key(targetID, step)},
+			":from": &types.AttributeValueMemberN{Value: strconv.FormatInt(from.Unix(), 10)},
+			":to":   &types.AttributeValueMemberN{Value: strconv.FormatInt(to.Unix(), 10)},
+		},
+		ScanIndexForward: aws.Bool(true), // ascending order
+	})
+
+	rollups := []model.Rollup{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query rollups: %w", err)
+		}
+
+		var pageRollups []model.Rollup
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageRollups); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal rollups: %w", err)
+		}
+		rollups = append(rollups, pageRollups...)
+	}
+
+	return rollups, nil
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/tracing"
+)
+
+// AddSLO adds an SLO definition to the slos table
+func (dynamoDBStore *DynamoDBStore) AddSLO(ctx context.Context, objective model.SLO) (_ model.SLO, err error) {
+	ctx, span := startSpan(ctx, "AddSLO", dynamoDBStore.slosTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.slosTable == "" {
+		return model.SLO{}, ErrTableNotConfigured
+	}
+
+	// use the timestamp as ID, same as targets
+	objective.ID = newID()
+
+	if err := dynamoDBStore.putItem(ctx, dynamoDBStore.slosTable, objective); err != nil {
+		return model.SLO{}, err
+	}
+	return objective, nil
+}
+
+// ListSLOs scans the slos table
+func (dynamoDBStore *DynamoDBStore) ListSLOs(ctx context.Context) (_ []model.SLO, err error) {
+	ctx, span := startSpan(ctx, "ListSLOs", dynamoDBStore.slosTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.slosTable == "" {
+		return nil, ErrTableNotConfigured
+	}
+	return scanItems[model.SLO](ctx, dynamoDBStore, dynamoDBStore.slosTable)
+}
+
+// GetSLO fetches a single SLO from the slos table
+func (dynamoDBStore *DynamoDBStore) GetSLO(ctx context.Context, id string) (_ model.SLO, err error) {
+	ctx, span := startSpan(ctx, "GetSLO", dynamoDBStore.slosTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.slosTable == "" {
+		return model.SLO{}, ErrTableNotConfigured
+	}
+	return getItemByID[model.SLO](ctx, dynamoDBStore, dynamoDBStore.slosTable, id)
+}
+
+// DeleteSLO removes an SLO from the slos table
+func (dynamoDBStore *DynamoDBStore) DeleteSLO(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteSLO", dynamoDBStore.slosTable)
+	defer tracing.End(span, &err)
+
+	if dynamoDBStore.slosTable == "" {
+		return ErrTableNotConfigured
+	}
+	return dynamoDBStore.deleteItemByID(ctx, dynamoDBStore.slosTable, id)
+}
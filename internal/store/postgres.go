@@ -0,0 +1,744 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/retention"
+	"github.com/sspier/cloudpulse/internal/rollup"
+	"github.com/sspier/cloudpulse/internal/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// postgresDeleteBatch is how many rows a single delete removes, so a large backlog doesn't hold long locks
+const postgresDeleteBatch = 10000
+
+// postgresMigrationLock is the advisory lock key that serializes migrations when the api and the runner start together
+const postgresMigrationLock = 7_401_220_001
+
+// postgresMigrations are applied in order and recorded in schema_migrations, never edit one that has shipped
+//
+// targets, maintenance windows, slos and incidents are stored as json documents keyed by id, like in DynamoDB
+// results are queried by target and time, so those get real columns and an index
+var postgresMigrations = []string{
+	// 1: targets and results
+	`CREATE TABLE targets (
+		id   TEXT PRIMARY KEY,
+		data JSONB NOT NULL
+	);
+	CREATE TABLE results (
+		id         BIGSERIAL PRIMARY KEY,
+		target_id  TEXT NOT NULL,
+		timestamp  BIGINT NOT NULL,
+		data       JSONB NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL
+	);
+	-- history is always read per target in time order, the id keeps equal timestamps in insertion order
+	CREATE INDEX results_target_time ON results (target_id, timestamp, id);
+	CREATE INDEX results_expires_at ON results (expires_at);
+	CREATE TABLE latest_results (
+		target_id TEXT PRIMARY KEY,
+		timestamp BIGINT NOT NULL,
+		data      JSONB NOT NULL
+	);`,
+
+	// 2: rollups, maintenance windows, slos and incidents
+	`CREATE TABLE rollups (
+		target_id    TEXT NOT NULL,
+		step_seconds INTEGER NOT NULL,
+		start        BIGINT NOT NULL,
+		data         JSONB NOT NULL,
+		expires_at   TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (target_id, step_seconds, start)
+	);
+	CREATE INDEX rollups_expires_at ON rollups (expires_at);
+	CREATE TABLE maintenance_windows (
+		id   TEXT PRIMARY KEY,
+		data JSONB NOT NULL
+	);
+	CREATE TABLE slos (
+		id   TEXT PRIMARY KEY,
+		data JSONB NOT NULL
+	);
+	CREATE TABLE incidents (
+		id        TEXT PRIMARY KEY,
+		target_id TEXT NOT NULL,
+		data      JSONB NOT NULL
+	);
+	CREATE INDEX incidents_target ON incidents (target_id);`,
+
+	// 3: results are deleted by the retention policy of their target, not a fixed expiry set when they were written
+	`DROP INDEX results_expires_at;
+	ALTER TABLE results DROP COLUMN expires_at;`,
+}
+
+// PostgresStore persists everything to PostgreSQL
+// old results and rollups are deleted by Compact, the equivalent of the DynamoDB ttl
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStore connects to the database and applies pending migrations
+// databaseURL is a postgres:// url or a key=value connection string
+func NewPostgresStore(ctx context.Context, databaseURL string) (*PostgresStore, error) {
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+
+	postgresStore := &PostgresStore{pool: pool}
+	if err := postgresStore.migrate(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return postgresStore, nil
+}
+
+// Close closes the connections
+func (postgresStore *PostgresStore) Close() {
+	postgresStore.pool.Close()
+}
+
+// migrate applies the migrations the database doesn't have yet, all in one transaction
+func (postgresStore *PostgresStore) migrate(ctx context.Context) error {
+	return pgx.BeginFunc(ctx, postgresStore.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", postgresMigrationLock); err != nil {
+			return fmt.Errorf("failed to lock migrations: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+
+		var current int
+		if err := tx.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+			return fmt.Errorf("failed to read schema version: %w", err)
+		}
+		if current > len(postgresMigrations) {
+			return fmt.Errorf("database schema version %d is newer than this build (%d)", current, len(postgresMigrations))
+		}
+
+		for index, migration := range postgresMigrations[current:] {
+			version := current + index + 1
+			if _, err := tx.Exec(ctx, migration); err != nil {
+				return fmt.Errorf("failed to apply migration %d: %w", version, err)
+			}
+			if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", version, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Compact deletes the results the retention policy of their target no longer keeps and the expired rollups
+func (postgresStore *PostgresStore) Compact(ctx context.Context, defaults retention.Policy, now time.Time) (_ int, err error) {
+	ctx, span := startPostgresSpan(ctx, "Compact", "results")
+	defer tracing.End(span, &err)
+
+	// every target that ever had a result has a latest result, targets deleted without purge included
+	// their results follow the global policy
+	rows, err := postgresStore.pool.Query(ctx, "SELECT latest_results.target_id, targets.data FROM latest_results LEFT JOIN targets ON targets.id = latest_results.target_id")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list targets with results: %w", err)
+	}
+	policies := map[string]retention.Policy{}
+	var targetID string
+	var target *model.Target
+	_, err = pgx.ForEachRow(rows, []any{&targetID, &target}, func() error {
+		if target == nil {
+			policies[targetID] = defaults
+			return nil
+		}
+		policies[targetID] = defaults.ForTarget(*target)
+		// json would decode the next row into this target, keeping fields the next one omits
+		target = nil
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read targets with results: %w", err)
+	}
+
+	var deleted int64
+	for targetID, policy := range policies {
+		if policy.MaxAge > 0 {
+			expired, err := postgresStore.deleteInBatches(ctx,
+				"DELETE FROM results WHERE id IN (SELECT id FROM results WHERE target_id = $1 AND timestamp < $2 LIMIT $3)",
+				targetID, policy.Cutoff(now))
+			deleted += expired
+			if err != nil {
+				return int(deleted), err
+			}
+		}
+		if policy.MaxResults > 0 {
+			commandTag, err := postgresStore.pool.Exec(ctx,
+				"DELETE FROM results WHERE id IN (SELECT id FROM results WHERE target_id = $1 ORDER BY timestamp DESC, id DESC OFFSET $2)",
+				targetID, policy.MaxResults)
+			if err != nil {
+				return int(deleted), fmt.Errorf("failed to delete results over the limit: %w", err)
+			}
+			deleted += commandTag.RowsAffected()
+		}
+	}
+
+	_, err = postgresStore.deleteInBatches(ctx,
+		"DELETE FROM rollups WHERE (target_id, step_seconds, start) IN (SELECT target_id, step_seconds, start FROM rollups WHERE expires_at <= $1 LIMIT $2)",
+		now)
+	return int(deleted), err
+}
+
+// deleteInBatches runs a delete whose last parameter is its batch size until a batch comes back short
+func (postgresStore *PostgresStore) deleteInBatches(ctx context.Context, statement string, arguments ...any) (int64, error) {
+	arguments = append(arguments, postgresDeleteBatch)
+
+	var deleted int64
+	for {
+		commandTag, err := postgresStore.pool.Exec(ctx, statement, arguments...)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete expired rows: %w", err)
+		}
+		deleted += commandTag.RowsAffected()
+		if commandTag.RowsAffected() < postgresDeleteBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// startPostgresSpan starts the client span around a single store call
+func startPostgresSpan(ctx context.Context, operation, tableName string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "PostgresStore."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(tableName),
+		),
+	)
+}
+
+// AddTarget stores a new target with a generated ID
+func (postgresStore *PostgresStore) AddTarget(ctx context.Context, target model.Target) (_ model.Target, err error) {
+	ctx, span := startPostgresSpan(ctx, "AddTarget", "targets")
+	defer tracing.End(span, &err)
+
+	target.ID = newID()
+	if err := insertPostgresJSON(ctx, postgresStore.pool, "targets", target.ID, target); err != nil {
+		return model.Target{}, err
+	}
+	return target, nil
+}
+
+// ListTargets returns every target
+func (postgresStore *PostgresStore) ListTargets(ctx context.Context) (_ []model.Target, err error) {
+	ctx, span := startPostgresSpan(ctx, "ListTargets", "targets")
+	defer tracing.End(span, &err)
+
+	return listPostgresJSON[model.Target](ctx, postgresStore.pool, "SELECT data FROM targets")
+}
+
+// GetTarget returns a single target, or ErrNotFound
+func (postgresStore *PostgresStore) GetTarget(ctx context.Context, id string) (_ model.Target, err error) {
+	ctx, span := startPostgresSpan(ctx, "GetTarget", "targets")
+	defer tracing.End(span, &err)
+
+	return getPostgresJSON[model.Target](ctx, postgresStore.pool, "targets", id)
+}
+
+// UpdateTarget replaces an existing target, or returns ErrNotFound
+func (postgresStore *PostgresStore) UpdateTarget(ctx context.Context, target model.Target) (_ model.Target, err error) {
+	ctx, span := startPostgresSpan(ctx, "UpdateTarget", "targets")
+	defer tracing.End(span, &err)
+
+	encoded, err := json.Marshal(target)
+	if err != nil {
+		return model.Target{}, fmt.Errorf("failed to encode target: %w", err)
+	}
+	commandTag, err := postgresStore.pool.Exec(ctx, "UPDATE targets SET data = $2 WHERE id = $1", target.ID, encoded)
+	if err != nil {
+		return model.Target{}, fmt.Errorf("failed to update target: %w", err)
+	}
+	if commandTag.RowsAffected() == 0 {
+		return model.Target{}, ErrNotFound
+	}
+	return target, nil
+}
+
+// DeleteTarget removes a target and, if purgeResults is set, its results, latest result and rollups
+func (postgresStore *PostgresStore) DeleteTarget(ctx context.Context, id string, purgeResults bool) (err error) {
+	ctx, span := startPostgresSpan(ctx, "DeleteTarget", "targets")
+	defer tracing.End(span, &err)
+
+	return pgx.BeginFunc(ctx, postgresStore.pool, func(tx pgx.Tx) error {
+		if err := deletePostgresRow(ctx, tx, "targets", id); err != nil {
+			return err
+		}
+		if !purgeResults {
+			return nil
+		}
+
+		for _, statement := range []string{
+			"DELETE FROM results WHERE target_id = $1",
+			"DELETE FROM latest_results WHERE target_id = $1",
+			"DELETE FROM rollups WHERE target_id = $1",
+		} {
+			if _, err := tx.Exec(ctx, statement, id); err != nil {
+				return fmt.Errorf("failed to purge results: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// AddResult stores a result, updates the latest result of its target and merges it into the rollups
+// all in one transaction
+func (postgresStore *PostgresStore) AddResult(ctx context.Context, result model.Result) (err error) {
+	ctx, span := startPostgresSpan(ctx, "AddResult", "results")
+	defer tracing.End(span, &err)
+
+	// filled in from the target when reading, like the dynamodbav:"-" fields of the DynamoDB store
+	result.Name, result.Paused, result.Labels = "", false, nil
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	return pgx.BeginFunc(ctx, postgresStore.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "INSERT INTO results (target_id, timestamp, data) VALUES ($1, $2, $3)",
+			result.TargetID, result.Timestamp, encoded)
+		if err != nil {
+			return fmt.Errorf("failed to insert result: %w", err)
+		}
+
+		// a late result must not replace a newer one
+		_, err = tx.Exec(ctx, `INSERT INTO latest_results (target_id, timestamp, data) VALUES ($1, $2, $3)
+			ON CONFLICT (target_id) DO UPDATE SET timestamp = EXCLUDED.timestamp, data = EXCLUDED.data
+			WHERE latest_results.timestamp <= EXCLUDED.timestamp`,
+			result.TargetID, result.Timestamp, encoded)
+		if err != nil {
+			return fmt.Errorf("failed to update latest result: %w", err)
+		}
+
+		for _, step := range rollup.Steps {
+			if err := mergePostgresRollup(ctx, tx, result.TargetID, step, rollup.FromResult(result, step)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// mergePostgresRollup merges a single result's bucket into the stored one
+// the row is locked while merging, so concurrent results for the same bucket are applied one after the other
+func mergePostgresRollup(ctx context.Context, tx pgx.Tx, targetID string, step time.Duration, bucket model.Rollup) error {
+	stepSeconds := int64(step / time.Second)
+
+	// two attempts: if a concurrent transaction inserted the bucket between our select and insert, the second
+	// attempt finds and locks it
+	for attempt := 0; attempt < 2; attempt++ {
+		var encoded []byte
+		err := tx.QueryRow(ctx, "SELECT data FROM rollups WHERE target_id = $1 AND step_seconds = $2 AND start = $3 FOR UPDATE",
+			targetID, stepSeconds, bucket.Start).Scan(&encoded)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to read rollup: %w", err)
+		}
+
+		if err == nil {
+			merged, err := decodeRollup(encoded)
+			if err != nil {
+				return err
+			}
+			rollup.Merge(&merged, bucket)
+			if encoded, err = encodeRollup(merged); err != nil {
+				return err
+			}
+			_, err = tx.Exec(ctx, "UPDATE rollups SET data = $4 WHERE target_id = $1 AND step_seconds = $2 AND start = $3",
+				targetID, stepSeconds, bucket.Start, encoded)
+			if err != nil {
+				return fmt.Errorf("failed to update rollup: %w", err)
+			}
+			return nil
+		}
+
+		if encoded, err = encodeRollup(bucket); err != nil {
+			return err
+		}
+		expiresAt := rollup.Expiry(bucket.Start, step)
+		commandTag, err := tx.Exec(ctx, `INSERT INTO rollups (target_id, step_seconds, start, data, expires_at) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT DO NOTHING`, targetID, stepSeconds, bucket.Start, encoded, expiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to insert rollup: %w", err)
+		}
+		if commandTag.RowsAffected() == 1 {
+			return nil
+		}
+	}
+	return errors.New("failed to merge rollup: bucket was inserted concurrently")
+}
+
+// LatestResults returns the newest result of each target, with one join
+func (postgresStore *PostgresStore) LatestResults(ctx context.Context) (_ []model.Result, err error) {
+	ctx, span := startPostgresSpan(ctx, "LatestResults", "latest_results")
+	defer tracing.End(span, &err)
+
+	rows, err := postgresStore.pool.Query(ctx, "SELECT targets.data, latest_results.data FROM targets JOIN latest_results ON latest_results.target_id = targets.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest results: %w", err)
+	}
+
+	latestResults, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Result, error) {
+		var target model.Target
+		var result model.Result
+		if err := row.Scan(&target, &result); err != nil {
+			return model.Result{}, err
+		}
+		result.Name = target.Name
+		result.Paused = target.Paused
+		result.Labels = target.Labels
+		return result, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read latest results: %w", err)
+	}
+	return latestResults, nil
+}
+
+// ResultsForTarget returns the full probe history of a target, oldest first
+func (postgresStore *PostgresStore) ResultsForTarget(ctx context.Context, targetID string) (_ []model.Result, err error) {
+	ctx, span := startPostgresSpan(ctx, "ResultsForTarget", "results")
+	defer tracing.End(span, &err)
+
+	return listPostgresJSON[model.Result](ctx, postgresStore.pool, "SELECT data FROM results WHERE target_id = $1 ORDER BY timestamp, id", targetID)
+}
+
+// ResultsBetween returns every result of a target with from <= timestamp <= to, oldest first
+func (postgresStore *PostgresStore) ResultsBetween(ctx context.Context, targetID string, from, to time.Time) (_ []model.Result, err error) {
+	ctx, span := startPostgresSpan(ctx, "ResultsBetween", "results")
+	defer tracing.End(span, &err)
+
+	return listPostgresJSON[model.Result](ctx, postgresStore.pool,
+		"SELECT data FROM results WHERE target_id = $1 AND timestamp BETWEEN $2 AND $3 ORDER BY timestamp, id",
+		targetID, from.Unix(), to.Unix())
+}
+
+// QueryResults returns one page of a target's results
+// only the rows the page can possibly need are read, PageResults then applies the query to them
+func (postgresStore *PostgresStore) QueryResults(ctx context.Context, targetID string, query ResultQuery) (_ ResultPage, err error) {
+	ctx, span := startPostgresSpan(ctx, "QueryResults", "results")
+	defer tracing.End(span, &err)
+
+	position, hasCursor, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return ResultPage{}, err
+	}
+
+	// results with the cursor's timestamp that were already returned, plus the page, plus one to detect the next page
+	limit := query.PageSize() + 1
+	if hasCursor {
+		limit += position.Skip
+	}
+
+	lower, upper := int64(minTimestamp), int64(maxTimestamp)
+	if !query.From.IsZero() {
+		lower = query.From.Unix()
+	}
+	if !query.To.IsZero() {
+		upper = query.To.Unix()
+	}
+
+	statement := "SELECT data FROM results WHERE target_id = $1 AND timestamp BETWEEN $2 AND $3 ORDER BY timestamp, id LIMIT $4"
+	if query.Descending {
+		statement = "SELECT data FROM results WHERE target_id = $1 AND timestamp BETWEEN $2 AND $3 ORDER BY timestamp DESC, id DESC LIMIT $4"
+		if hasCursor {
+			upper = min(upper, position.Timestamp)
+		}
+	} else if hasCursor {
+		lower = max(lower, position.Timestamp)
+	}
+
+	results, err := listPostgresJSON[model.Result](ctx, postgresStore.pool, statement, targetID, lower, upper, limit)
+	if err != nil {
+		return ResultPage{}, err
+	}
+	if query.Descending {
+		// PageResults expects the results oldest first
+		slices.Reverse(results)
+	}
+	return PageResults(results, query)
+}
+
+// Rollups returns the aggregates of a target for one step within a time range, oldest first
+func (postgresStore *PostgresStore) Rollups(ctx context.Context, targetID string, step time.Duration, from, to time.Time) (_ []model.Rollup, err error) {
+	ctx, span := startPostgresSpan(ctx, "Rollups", "rollups")
+	defer tracing.End(span, &err)
+
+	rows, err := postgresStore.pool.Query(ctx,
+		"SELECT data FROM rollups WHERE target_id = $1 AND step_seconds = $2 AND start BETWEEN $3 AND $4 ORDER BY start",
+		targetID, int64(step/time.Second), from.Unix(), to.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollups: %w", err)
+	}
+	rollups, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Rollup, error) {
+		var encoded []byte
+		if err := row.Scan(&encoded); err != nil {
+			return model.Rollup{}, err
+		}
+		return decodeRollup(encoded)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollups: %w", err)
+	}
+	return rollups, nil
+}
+
+// AddMaintenanceWindow stores a new window with a generated ID
+func (postgresStore *PostgresStore) AddMaintenanceWindow(ctx context.Context, window model.MaintenanceWindow) (_ model.MaintenanceWindow, err error) {
+	ctx, span := startPostgresSpan(ctx, "AddMaintenanceWindow", "maintenance_windows")
+	defer tracing.End(span, &err)
+
+	window.ID = newID()
+	if err := insertPostgresJSON(ctx, postgresStore.pool, "maintenance_windows", window.ID, window); err != nil {
+		return model.MaintenanceWindow{}, err
+	}
+	return window, nil
+}
+
+// ListMaintenanceWindows returns every maintenance window
+func (postgresStore *PostgresStore) ListMaintenanceWindows(ctx context.Context) (_ []model.MaintenanceWindow, err error) {
+	ctx, span := startPostgresSpan(ctx, "ListMaintenanceWindows", "maintenance_windows")
+	defer tracing.End(span, &err)
+
+	return listPostgresJSON[model.MaintenanceWindow](ctx, postgresStore.pool, "SELECT data FROM maintenance_windows")
+}
+
+// GetMaintenanceWindow returns a single window, or ErrNotFound
+func (postgresStore *PostgresStore) GetMaintenanceWindow(ctx context.Context, id string) (_ model.MaintenanceWindow, err error) {
+	ctx, span := startPostgresSpan(ctx, "GetMaintenanceWindow", "maintenance_windows")
+	defer tracing.End(span, &err)
+
+	return getPostgresJSON[model.MaintenanceWindow](ctx, postgresStore.pool, "maintenance_windows", id)
+}
+
+// DeleteMaintenanceWindow removes a window, or returns ErrNotFound
+func (postgresStore *PostgresStore) DeleteMaintenanceWindow(ctx context.Context, id string) (err error) {
+	ctx, span := startPostgresSpan(ctx, "DeleteMaintenanceWindow", "maintenance_windows")
+	defer tracing.End(span, &err)
+
+	return deletePostgresRow(ctx, postgresStore.pool, "maintenance_windows", id)
+}
+
+// AddSLO stores a new SLO with a generated ID
+func (postgresStore *PostgresStore) AddSLO(ctx context.Context, objective model.SLO) (_ model.SLO, err error) {
+	ctx, span := startPostgresSpan(ctx, "AddSLO", "slos")
+	defer tracing.End(span, &err)
+
+	objective.ID = newID()
+	if err := insertPostgresJSON(ctx, postgresStore.pool, "slos", objective.ID, objective); err != nil {
+		return model.SLO{}, err
+	}
+	return objective, nil
+}
+
+// ListSLOs returns every SLO
+func (postgresStore *PostgresStore) ListSLOs(ctx context.Context) (_ []model.SLO, err error) {
+	ctx, span := startPostgresSpan(ctx, "ListSLOs", "slos")
+	defer tracing.End(span, &err)
+
+	return listPostgresJSON[model.SLO](ctx, postgresStore.pool, "SELECT data FROM slos")
+}
+
+// GetSLO returns a single SLO, or ErrNotFound
+func (postgresStore *PostgresStore) GetSLO(ctx context.Context, id string) (_ model.SLO, err error) {
+	ctx, span := startPostgresSpan(ctx, "GetSLO", "slos")
+	defer tracing.End(span, &err)
+
+	return getPostgresJSON[model.SLO](ctx, postgresStore.pool, "slos", id)
+}
+
+// DeleteSLO removes an SLO, or returns ErrNotFound
+func (postgresStore *PostgresStore) DeleteSLO(ctx context.Context, id string) (err error) {
+	ctx, span := startPostgresSpan(ctx, "DeleteSLO", "slos")
+	defer tracing.End(span, &err)
+
+	return deletePostgresRow(ctx, postgresStore.pool, "slos", id)
+}
+
+// AddIncident stores a new incident with a generated ID
+func (postgresStore *PostgresStore) AddIncident(ctx context.Context, incident model.Incident) (_ model.Incident, err error) {
+	ctx, span := startPostgresSpan(ctx, "AddIncident", "incidents")
+	defer tracing.End(span, &err)
+
+	incident.ID = newID()
+	encoded, err := json.Marshal(incident)
+	if err != nil {
+		return model.Incident{}, fmt.Errorf("failed to encode incident: %w", err)
+	}
+	if _, err := postgresStore.pool.Exec(ctx, "INSERT INTO incidents (id, target_id, data) VALUES ($1, $2, $3)", incident.ID, incident.TargetID, encoded); err != nil {
+		return model.Incident{}, fmt.Errorf("failed to insert incident: %w", err)
+	}
+	return incident, nil
+}
+
+// ListIncidents returns every incident, newest first
+func (postgresStore *PostgresStore) ListIncidents(ctx context.Context) (_ []model.Incident, err error) {
+	ctx, span := startPostgresSpan(ctx, "ListIncidents", "incidents")
+	defer tracing.End(span, &err)
+
+	incidents, err := listPostgresJSON[model.Incident](ctx, postgresStore.pool, "SELECT data FROM incidents")
+	if err != nil {
+		return nil, err
+	}
+	SortIncidents(incidents)
+	return incidents, nil
+}
+
+// IncidentsForTarget returns the incidents of a single target, newest first
+func (postgresStore *PostgresStore) IncidentsForTarget(ctx context.Context, targetID string) (_ []model.Incident, err error) {
+	ctx, span := startPostgresSpan(ctx, "IncidentsForTarget", "incidents")
+	defer tracing.End(span, &err)
+
+	incidents, err := listPostgresJSON[model.Incident](ctx, postgresStore.pool, "SELECT data FROM incidents WHERE target_id = $1", targetID)
+	if err != nil {
+		return nil, err
+	}
+	SortIncidents(incidents)
+	return incidents, nil
+}
+
+// GetIncident returns a single incident, or ErrNotFound
+func (postgresStore *PostgresStore) GetIncident(ctx context.Context, id string) (_ model.Incident, err error) {
+	ctx, span := startPostgresSpan(ctx, "GetIncident", "incidents")
+	defer tracing.End(span, &err)
+
+	return getPostgresJSON[model.Incident](ctx, postgresStore.pool, "incidents", id)
+}
+
+// ResolveIncident marks an incident as resolved at endTime, or returns ErrNotFound
+func (postgresStore *PostgresStore) ResolveIncident(ctx context.Context, id string, endTime int64) (_ model.Incident, err error) {
+	ctx, span := startPostgresSpan(ctx, "ResolveIncident", "incidents")
+	defer tracing.End(span, &err)
+
+	return postgresStore.updateIncident(ctx, id, func(incident *model.Incident) {
+		incident.Status = model.IncidentResolved
+		incident.EndTime = endTime
+	})
+}
+
+// AcknowledgeIncident records who acknowledged an incident and when, or returns ErrNotFound
+func (postgresStore *PostgresStore) AcknowledgeIncident(ctx context.Context, id, acknowledgedBy string, acknowledgedAt int64) (_ model.Incident, err error) {
+	ctx, span := startPostgresSpan(ctx, "AcknowledgeIncident", "incidents")
+	defer tracing.End(span, &err)
+
+	return postgresStore.updateIncident(ctx, id, func(incident *model.Incident) {
+		incident.AcknowledgedBy = acknowledgedBy
+		incident.AcknowledgedAt = acknowledgedAt
+	})
+}
+
+// AddIncidentNote appends a note to an incident, or returns ErrNotFound
+func (postgresStore *PostgresStore) AddIncidentNote(ctx context.Context, id string, note model.IncidentNote) (_ model.Incident, err error) {
+	ctx, span := startPostgresSpan(ctx, "AddIncidentNote", "incidents")
+	defer tracing.End(span, &err)
+
+	return postgresStore.updateIncident(ctx, id, func(incident *model.Incident) {
+		incident.Notes = append(incident.Notes, note)
+	})
+}
+
+// updateIncident locks, changes and writes back an incident in one transaction
+func (postgresStore *PostgresStore) updateIncident(ctx context.Context, id string, update func(*model.Incident)) (model.Incident, error) {
+	var incident model.Incident
+	err := pgx.BeginFunc(ctx, postgresStore.pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, "SELECT data FROM incidents WHERE id = $1 FOR UPDATE", id).Scan(&incident)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read incident: %w", err)
+		}
+
+		update(&incident)
+		encoded, err := json.Marshal(incident)
+		if err != nil {
+			return fmt.Errorf("failed to encode incident: %w", err)
+		}
+		if _, err := tx.Exec(ctx, "UPDATE incidents SET data = $2 WHERE id = $1", id, encoded); err != nil {
+			return fmt.Errorf("failed to update incident: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return model.Incident{}, err
+	}
+	return incident, nil
+}
+
+// the helpers below cover the "table keyed by id" entities, like their DynamoDB counterparts
+// table names always come from this file, never from input
+
+// postgresExecutor is what the helpers need from a pool or a transaction
+type postgresExecutor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, arguments ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, arguments ...any) pgx.Row
+}
+
+// insertPostgresJSON inserts an item as a json document under id
+func insertPostgresJSON(ctx context.Context, executor postgresExecutor, tableName, id string, item any) error {
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode item for %s: %w", tableName, err)
+	}
+	if _, err := executor.Exec(ctx, "INSERT INTO "+tableName+" (id, data) VALUES ($1, $2)", id, encoded); err != nil {
+		return fmt.Errorf("failed to insert item into %s: %w", tableName, err)
+	}
+	return nil
+}
+
+// getPostgresJSON reads and decodes the item with the given id, or returns ErrNotFound
+func getPostgresJSON[T any](ctx context.Context, executor postgresExecutor, tableName, id string) (T, error) {
+	var item T
+	err := executor.QueryRow(ctx, "SELECT data FROM "+tableName+" WHERE id = $1", id).Scan(&item)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return item, ErrNotFound
+	}
+	if err != nil {
+		return item, fmt.Errorf("failed to get item from %s: %w", tableName, err)
+	}
+	return item, nil
+}
+
+// listPostgresJSON runs a query returning a single json column and decodes every row
+func listPostgresJSON[T any](ctx context.Context, executor postgresExecutor, statement string, arguments ...any) ([]T, error) {
+	rows, err := executor.Query(ctx, statement, arguments...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	items, err := pgx.CollectRows(rows, pgx.RowTo[T])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	return items, nil
+}
+
+// deletePostgresRow deletes the row with the given id, or returns ErrNotFound
+func deletePostgresRow(ctx context.Context, executor postgresExecutor, tableName, id string) error {
+	commandTag, err := executor.Exec(ctx, "DELETE FROM "+tableName+" WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete item from %s: %w", tableName, err)
+	}
+	if commandTag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
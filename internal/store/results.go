@@ -0,0 +1,176 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+)
+
+// limits on a single page of results
+const (
+	DefaultResultLimit = 100
+	MaxResultLimit     = 1000
+)
+
+// ErrInvalidCursor is returned when a cursor can't be decoded, e.g. because it was tampered with
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ResultQuery selects a page of a target's results
+type ResultQuery struct {
+	// From and To bound the timestamps (both inclusive), zero values leave that side open
+	From time.Time
+	To   time.Time
+	// Limit is the page size, zero means DefaultResultLimit, anything above MaxResultLimit is capped
+	Limit int
+	// Descending returns the newest results first
+	Descending bool
+	// Cursor continues after the last page, it is the NextCursor of that page
+	Cursor string
+}
+
+// PageSize returns the number of results a page of this query holds at most
+func (query ResultQuery) PageSize() int {
+	if query.Limit <= 0 {
+		return DefaultResultLimit
+	}
+	return min(query.Limit, MaxResultLimit)
+}
+
+// ResultPage is one page of results, NextCursor is empty on the last page
+type ResultPage struct {
+	Results    []model.Result
+	NextCursor string
+}
+
+// cursor is where the previous page stopped
+// timestamps are not unique in every store, so it also counts how many results with the last timestamp
+// were already returned
+type cursor struct {
+	Timestamp int64 `json:"t"`
+	Skip      int   `json:"s"`
+}
+
+// encodeCursor turns the position after the last result of a page into an opaque string
+func encodeCursor(position cursor) string {
+	encoded, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor reverses encodeCursor, an empty string is the start of the results
+func decodeCursor(text string) (cursor, bool, error) {
+	if text == "" {
+		return cursor{}, false, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(text)
+	if err != nil {
+		return cursor{}, false, ErrInvalidCursor
+	}
+	var position cursor
+	if err := json.Unmarshal(decoded, &position); err != nil || position.Skip < 1 {
+		return cursor{}, false, ErrInvalidCursor
+	}
+	return position, true, nil
+}
+
+// PageResults applies a query to the complete, oldest first history of a target
+// it is meant for stores that keep results in memory and can't page natively
+func PageResults(results []model.Result, query ResultQuery) (ResultPage, error) {
+	position, hasCursor, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return ResultPage{}, err
+	}
+
+	// before reports whether a timestamp comes before the cursor in the requested order
+	before := func(timestamp int64) bool {
+		if query.Descending {
+			return timestamp > position.Timestamp
+		}
+		return timestamp < position.Timestamp
+	}
+
+	pageSize := query.PageSize()
+	page := ResultPage{Results: []model.Result{}}
+	skipped := 0
+	for index := range results {
+		result := results[index]
+		if query.Descending {
+			result = results[len(results)-1-index]
+		}
+
+		if !query.From.IsZero() && result.Timestamp < query.From.Unix() {
+			continue
+		}
+		if !query.To.IsZero() && result.Timestamp > query.To.Unix() {
+			continue
+		}
+		if hasCursor {
+			if before(result.Timestamp) {
+				continue
+			}
+			if result.Timestamp == position.Timestamp && skipped < position.Skip {
+				skipped++
+				continue
+			}
+		}
+
+		if len(page.Results) == pageSize {
+			// there is at least one more result, remember where this page ended
+			page.NextCursor = encodeCursor(pageEnd(page.Results))
+			break
+		}
+		page.Results = append(page.Results, result)
+	}
+
+	// the cursor counts results with the last timestamp across pages
+	if page.NextCursor != "" && hasCursor {
+		end := pageEnd(page.Results)
+		if end.Timestamp == position.Timestamp {
+			end.Skip += position.Skip
+			page.NextCursor = encodeCursor(end)
+		}
+	}
+
+	return page, nil
+}
+
+// pageEnd returns the cursor pointing behind the last result of a page
+func pageEnd(results []model.Result) cursor {
+	last := results[len(results)-1].Timestamp
+	end := cursor{Timestamp: last}
+	for index := len(results) - 1; index >= 0 && results[index].Timestamp == last; index-- {
+		end.Skip++
+	}
+	return end
+}
+
+// storedRollup is how the file and sql stores encode rollups as json
+// the counters behind the derived fields are hidden from the api's json, so they are repeated here
+type storedRollup struct {
+	model.Rollup
+	LatencySumMs     int64   `json:"latencySumMs"`
+	LatencyHistogram []int64 `json:"latencyHistogram"`
+}
+
+// encodeRollup encodes a rollup with its counters
+func encodeRollup(bucket model.Rollup) ([]byte, error) {
+	encoded, err := json.Marshal(storedRollup{Rollup: bucket, LatencySumMs: bucket.LatencySumMs, LatencyHistogram: bucket.LatencyHistogram})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rollup: %w", err)
+	}
+	return encoded, nil
+}
+
+// decodeRollup reverses encodeRollup
+func decodeRollup(encoded []byte) (model.Rollup, error) {
+	var stored storedRollup
+	if err := json.Unmarshal(encoded, &stored); err != nil {
+		return model.Rollup{}, fmt.Errorf("failed to decode rollup: %w", err)
+	}
+	stored.Rollup.LatencySumMs = stored.LatencySumMs
+	stored.Rollup.LatencyHistogram = stored.LatencyHistogram
+	return stored.Rollup, nil
+}
@@ -2,14 +2,157 @@ package store
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
 	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/retention"
 )
 
-// Store defines the interface for persisting targets and results
+// ErrNotFound is returned when the requested item does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrTableNotConfigured is returned by optional DynamoDB features whose table name was not provided
+var ErrTableNotConfigured = errors.New("table not configured")
+
+// Store defines the interface for persisting targets, results and everything derived from them
+//
+// every implementation behaves the same way, storetest.Run checks it:
+// list methods return an empty slice rather than nil when there is nothing to return,
+// results are ordered by timestamp, and all methods are safe to call from several goroutines at once
+//
+// results of a target with the same timestamp (unix seconds) are the one place where stores differ:
+// the in-memory, bolt and PostgreSQL stores keep all of them, in the order they were added,
+// while DynamoDB keys results on target and timestamp, so a later one replaces the earlier
+// scheduled probes of a target are at least 10 seconds apart, so in practice this rarely drops a probe
 type Store interface {
-	AddTarget(ctx context.Context, name, url string) (model.Target, error)
+	TargetStore
+	ResultStore
+	MaintenanceStore
+	SLOStore
+	IncidentStore
+}
+
+// TargetStore persists the monitored targets
+type TargetStore interface {
+	// AddTarget stores a new target and returns it with its generated ID
+	AddTarget(ctx context.Context, target model.Target) (model.Target, error)
 	ListTargets(ctx context.Context) ([]model.Target, error)
+	// GetTarget returns a single target, or ErrNotFound
+	GetTarget(ctx context.Context, id string) (model.Target, error)
+	// UpdateTarget replaces an existing target (matched by ID), or returns ErrNotFound
+	UpdateTarget(ctx context.Context, target model.Target) (model.Target, error)
+	// DeleteTarget removes a target, and its results too if purgeResults is set, or returns ErrNotFound
+	DeleteTarget(ctx context.Context, id string, purgeResults bool) error
+}
+
+// ResultStore persists probe results
+type ResultStore interface {
+	// AddResult stores a result and merges it into the target's rollups
 	AddResult(ctx context.Context, result model.Result) error
+	// LatestResults returns the newest result by timestamp of every target that has one, in no particular order
 	LatestResults(ctx context.Context) ([]model.Result, error)
+	// ResultsForTarget returns the full history of a target, oldest first
+	// it reads every stored result, use QueryResults for anything that runs per probe or per request
 	ResultsForTarget(ctx context.Context, targetID string) ([]model.Result, error)
+	// ResultsBetween returns every result of a target with from <= timestamp <= to, oldest first
+	ResultsBetween(ctx context.Context, targetID string, from, to time.Time) ([]model.Result, error)
+	// QueryResults returns one page of a target's results, continue with the page's NextCursor
+	// or ErrInvalidCursor if the cursor can't be decoded
+	QueryResults(ctx context.Context, targetID string, query ResultQuery) (ResultPage, error)
+	// Rollups returns the aggregates of a target for one of rollup.Steps with from <= start <= to, oldest first
+	// AddResult keeps them up to date
+	Rollups(ctx context.Context, targetID string, step time.Duration, from, to time.Time) ([]model.Rollup, error)
+}
+
+// Compactor is implemented by the stores that delete old results themselves
+// DynamoDB expires them through its ttl attribute instead, see WithRetention
+type Compactor interface {
+	// Compact deletes the results that the retention policy of their target (defaults.ForTarget) no longer keeps
+	// and the rollups past rollup.Expiry, and returns how many results it deleted
+	Compact(ctx context.Context, defaults retention.Policy, now time.Time) (int, error)
+}
+
+// TargetResultAdder is implemented by the stores that need the target of a result to store it
+// DynamoDB sets the ttl of a result from the retention of its target, AddResult has to look the target up for that
+type TargetResultAdder interface {
+	// AddTargetResult stores a result of target like AddResult, without reading the target again
+	AddTargetResult(ctx context.Context, target model.Target, result model.Result) error
+}
+
+// AddResult stores a result of target, through AddTargetResult when the store implements it
+// the prober already holds the target, so this saves a read per probe
+func AddResult(ctx context.Context, resultStore ResultStore, target model.Target, result model.Result) error {
+	if targetResultAdder, ok := resultStore.(TargetResultAdder); ok {
+		return targetResultAdder.AddTargetResult(ctx, target, result)
+	}
+	return resultStore.AddResult(ctx, result)
+}
+
+// MaintenanceStore persists maintenance windows
+type MaintenanceStore interface {
+	// AddMaintenanceWindow stores a new window and returns it with its generated ID
+	AddMaintenanceWindow(ctx context.Context, window model.MaintenanceWindow) (model.MaintenanceWindow, error)
+	ListMaintenanceWindows(ctx context.Context) ([]model.MaintenanceWindow, error)
+	// GetMaintenanceWindow returns a single window, or ErrNotFound
+	GetMaintenanceWindow(ctx context.Context, id string) (model.MaintenanceWindow, error)
+	// DeleteMaintenanceWindow removes a window, or returns ErrNotFound
+	DeleteMaintenanceWindow(ctx context.Context, id string) error
+}
+
+// SLOStore persists service level objective definitions
+type SLOStore interface {
+	// AddSLO stores a new SLO and returns it with its generated ID
+	AddSLO(ctx context.Context, objective model.SLO) (model.SLO, error)
+	ListSLOs(ctx context.Context) ([]model.SLO, error)
+	// GetSLO returns a single SLO, or ErrNotFound
+	GetSLO(ctx context.Context, id string) (model.SLO, error)
+	// DeleteSLO removes an SLO, or returns ErrNotFound
+	DeleteSLO(ctx context.Context, id string) error
+}
+
+// IncidentStore persists incidents
+// incidents are listed newest first
+// the update methods only touch their own fields, so an operator and the prober can't overwrite each other
+type IncidentStore interface {
+	// AddIncident stores a new incident and returns it with its generated ID
+	AddIncident(ctx context.Context, incident model.Incident) (model.Incident, error)
+	ListIncidents(ctx context.Context) ([]model.Incident, error)
+	IncidentsForTarget(ctx context.Context, targetID string) ([]model.Incident, error)
+	// GetIncident returns a single incident, or ErrNotFound
+	GetIncident(ctx context.Context, id string) (model.Incident, error)
+	// ResolveIncident marks an incident as resolved at endTime, or returns ErrNotFound
+	ResolveIncident(ctx context.Context, id string, endTime int64) (model.Incident, error)
+	// AcknowledgeIncident records who acknowledged an incident and when, or returns ErrNotFound
+	AcknowledgeIncident(ctx context.Context, id, acknowledgedBy string, acknowledgedAt int64) (model.Incident, error)
+	// AddIncidentNote appends a note to an incident, or returns ErrNotFound
+	AddIncidentNote(ctx context.Context, id string, note model.IncidentNote) (model.Incident, error)
+}
+
+// SortIncidents orders incidents newest first, the order every IncidentStore lists them in
+func SortIncidents(incidents []model.Incident) {
+	sort.Slice(incidents, func(i, j int) bool {
+		if incidents[i].StartTime != incidents[j].StartTime {
+			return incidents[i].StartTime > incidents[j].StartTime
+		}
+		return incidents[i].ID > incidents[j].ID
+	})
+}
+
+// lastID is the most recent ID handed out by newID
+var lastID atomic.Int64
+
+// newID generates the IDs of the DynamoDB and PostgreSQL stores, the current time in nanoseconds
+// two calls in the same process never return the same ID, even when the clock doesn't move between them
+func newID() string {
+	for {
+		last := lastID.Load()
+		id := max(timeNow().UnixNano(), last+1)
+		if lastID.CompareAndSwap(last, id) {
+			return strconv.FormatInt(id, 10)
+		}
+	}
 }
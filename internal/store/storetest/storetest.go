@@ -0,0 +1,517 @@
+// Package storetest checks that a store.Store implementation behaves like the others
+//
+// every backend runs the same suite from its own test:
+//
+//	storetest.Run(t, func(t *testing.T) store.Store { return NewInMemoryStore() })
+//
+// the suite only looks at the items it created, so it can run against a shared database
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sspier/cloudpulse/internal/model"
+	"github.com/sspier/cloudpulse/internal/retention"
+	"github.com/sspier/cloudpulse/internal/store"
+)
+
+// concurrent writers and results per writer in the concurrency checks
+const (
+	writers          = 8
+	resultsPerWriter = 25
+)
+
+// Run runs the conformance suite, newStore is called once per subtest
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	t.Helper()
+
+	t.Run("Targets", func(t *testing.T) { testTargets(t, newStore(t)) })
+	t.Run("EmptyResults", func(t *testing.T) { testEmptyResults(t, newStore(t)) })
+	t.Run("ResultOrdering", func(t *testing.T) { testResultOrdering(t, newStore(t)) })
+	t.Run("QueryLimits", func(t *testing.T) { testQueryLimits(t, newStore(t)) })
+	t.Run("Rollups", func(t *testing.T) { testRollups(t, newStore(t)) })
+	t.Run("Incidents", func(t *testing.T) { testIncidents(t, newStore(t)) })
+	t.Run("ConcurrentWrites", func(t *testing.T) { testConcurrentWrites(t, newStore(t)) })
+	t.Run("Retention", func(t *testing.T) { testRetention(t, newStore(t)) })
+}
+
+// hourStart is the beginning of the previous hour
+// results are recent so that no retention job removes them while the suite runs
+func hourStart() int64 {
+	return time.Now().UTC().Truncate(time.Hour).Add(-time.Hour).Unix()
+}
+
+// addTarget adds a target and deletes it with its results when the test ends
+func addTarget(t *testing.T, testStore store.Store, name string) model.Target {
+	t.Helper()
+
+	target, err := testStore.AddTarget(context.Background(), model.Target{Name: name, URL: "https://" + name + ".example.com", Labels: map[string]string{"suite": "storetest"}})
+	if err != nil {
+		t.Fatalf("failed to add target: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = testStore.DeleteTarget(context.Background(), target.ID, true)
+	})
+	return target
+}
+
+// addResults stores a result for each timestamp, in the given order
+func addResults(t *testing.T, testStore store.Store, targetID string, timestamps ...int64) {
+	t.Helper()
+
+	for _, timestamp := range timestamps {
+		if err := testStore.AddResult(context.Background(), model.Result{TargetID: targetID, Status: model.StatusUp, HTTPStatus: 200, LatencyMs: 100, Timestamp: timestamp}); err != nil {
+			t.Fatalf("failed to add result: %v", err)
+		}
+	}
+}
+
+// timestampsOf lists the timestamps of results in their order
+func timestampsOf(results []model.Result) []int64 {
+	timestamps := make([]int64, 0, len(results))
+	for _, result := range results {
+		timestamps = append(timestamps, result.Timestamp)
+	}
+	return timestamps
+}
+
+// latestFor picks the latest result of a target out of LatestResults
+func latestFor(t *testing.T, testStore store.Store, targetID string) (model.Result, bool) {
+	t.Helper()
+
+	latest, err := testStore.LatestResults(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get latest results: %v", err)
+	}
+	for _, result := range latest {
+		if result.TargetID == targetID {
+			return result, true
+		}
+	}
+	return model.Result{}, false
+}
+
+func testTargets(t *testing.T, testStore store.Store) {
+	ctx := context.Background()
+
+	first := addTarget(t, testStore, "first")
+	second := addTarget(t, testStore, "second")
+	if first.ID == "" || first.ID == second.ID {
+		t.Fatalf("expected two distinct generated IDs, got %q and %q", first.ID, second.ID)
+	}
+
+	stored, err := testStore.GetTarget(ctx, first.ID)
+	if err != nil || stored.Name != "first" || stored.Labels["suite"] != "storetest" {
+		t.Fatalf("expected the first target, got %+v (%v)", stored, err)
+	}
+
+	targets, err := testStore.ListTargets(ctx)
+	if err != nil {
+		t.Fatalf("failed to list targets: %v", err)
+	}
+	for _, id := range []string{first.ID, second.ID} {
+		if !slices.ContainsFunc(targets, func(target model.Target) bool { return target.ID == id }) {
+			t.Fatalf("expected target %s in %+v", id, targets)
+		}
+	}
+
+	first.Paused = true
+	if _, err := testStore.UpdateTarget(ctx, first); err != nil {
+		t.Fatalf("failed to update target: %v", err)
+	}
+	if stored, _ := testStore.GetTarget(ctx, first.ID); !stored.Paused {
+		t.Fatalf("expected the update to be stored, got %+v", stored)
+	}
+
+	if err := testStore.DeleteTarget(ctx, second.ID, true); err != nil {
+		t.Fatalf("failed to delete target: %v", err)
+	}
+	if _, err := testStore.GetTarget(ctx, second.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a deleted target, got %v", err)
+	}
+	if err := testStore.DeleteTarget(ctx, second.ID, true); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound deleting twice, got %v", err)
+	}
+	if _, err := testStore.UpdateTarget(ctx, second); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound updating a deleted target, got %v", err)
+	}
+}
+
+// lists are empty slices rather than nil, so they encode as [] and not null
+func testEmptyResults(t *testing.T, testStore store.Store) {
+	ctx := context.Background()
+	target := addTarget(t, testStore, "empty")
+
+	checkEmpty := func(name string, length int, isNil bool, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		if isNil || length != 0 {
+			t.Fatalf("%s: expected an empty, non-nil slice, got %d items (nil: %v)", name, length, isNil)
+		}
+	}
+
+	history, err := testStore.ResultsForTarget(ctx, target.ID)
+	checkEmpty("ResultsForTarget", len(history), history == nil, err)
+
+	between, err := testStore.ResultsBetween(ctx, target.ID, time.Unix(0, 0), time.Now())
+	checkEmpty("ResultsBetween", len(between), between == nil, err)
+
+	page, err := testStore.QueryResults(ctx, target.ID, store.ResultQuery{})
+	checkEmpty("QueryResults", len(page.Results), page.Results == nil, err)
+	if page.NextCursor != "" {
+		t.Fatalf("expected no next cursor without results, got %q", page.NextCursor)
+	}
+
+	rollups, err := testStore.Rollups(ctx, target.ID, time.Hour, time.Unix(0, 0), time.Now())
+	checkEmpty("Rollups", len(rollups), rollups == nil, err)
+
+	incidents, err := testStore.IncidentsForTarget(ctx, target.ID)
+	checkEmpty("IncidentsForTarget", len(incidents), incidents == nil, err)
+
+	// a target without results has no latest result
+	if result, ok := latestFor(t, testStore, target.ID); ok {
+		t.Fatalf("expected no latest result, got %+v", result)
+	}
+
+	// the lists below may hold items of other tests on a shared database, but are never nil
+	latest, err := testStore.LatestResults(ctx)
+	if err != nil || latest == nil {
+		t.Fatalf("LatestResults: expected a non-nil slice, got %v (%v)", latest, err)
+	}
+	windows, err := testStore.ListMaintenanceWindows(ctx)
+	if err != nil || windows == nil {
+		t.Fatalf("ListMaintenanceWindows: expected a non-nil slice, got %v (%v)", windows, err)
+	}
+	objectives, err := testStore.ListSLOs(ctx)
+	if err != nil || objectives == nil {
+		t.Fatalf("ListSLOs: expected a non-nil slice, got %v (%v)", objectives, err)
+	}
+	allIncidents, err := testStore.ListIncidents(ctx)
+	if err != nil || allIncidents == nil {
+		t.Fatalf("ListIncidents: expected a non-nil slice, got %v (%v)", allIncidents, err)
+	}
+
+	missing := "storetest-missing-" + fmt.Sprint(time.Now().UnixNano())
+	if _, err := testStore.GetTarget(ctx, missing); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("GetTarget: expected ErrNotFound, got %v", err)
+	}
+	if _, err := testStore.GetIncident(ctx, missing); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("GetIncident: expected ErrNotFound, got %v", err)
+	}
+	if _, err := testStore.ResolveIncident(ctx, missing, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("ResolveIncident: expected ErrNotFound, got %v", err)
+	}
+}
+
+// results are ordered by timestamp whatever order they were added in
+// timestamps are distinct, DynamoDB keeps only one result per target and second (see store.Store)
+func testResultOrdering(t *testing.T, testStore store.Store) {
+	ctx := context.Background()
+	target := addTarget(t, testStore, "ordering")
+	start := hourStart()
+
+	addResults(t, testStore, target.ID, start+30, start+10, start+20, start+50, start+40)
+
+	history, err := testStore.ResultsForTarget(ctx, target.ID)
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if want := []int64{start + 10, start + 20, start + 30, start + 40, start + 50}; !slices.Equal(timestampsOf(history), want) {
+		t.Fatalf("ResultsForTarget: expected %v, got %v", want, timestampsOf(history))
+	}
+
+	between, err := testStore.ResultsBetween(ctx, target.ID, time.Unix(start+20, 0), time.Unix(start+40, 0))
+	if err != nil {
+		t.Fatalf("failed to get results between: %v", err)
+	}
+	if want := []int64{start + 20, start + 30, start + 40}; !slices.Equal(timestampsOf(between), want) {
+		t.Fatalf("ResultsBetween: expected %v (both ends included), got %v", want, timestampsOf(between))
+	}
+
+	// a late result lands in the history but doesn't replace the newest
+	addResults(t, testStore, target.ID, start)
+	latest, ok := latestFor(t, testStore, target.ID)
+	if !ok || latest.Timestamp != start+50 {
+		t.Fatalf("expected the newest result as latest, got %+v", latest)
+	}
+	if latest.Name != "ordering" || latest.Labels["suite"] != "storetest" {
+		t.Fatalf("expected the latest result to carry the target's name and labels, got %+v", latest)
+	}
+
+	if history, _ := testStore.ResultsForTarget(ctx, target.ID); len(history) != 6 || history[0].Timestamp != start {
+		t.Fatalf("expected the late result first in the history, got %v", timestampsOf(history))
+	}
+}
+
+// pages hold at most the limit, the cursor continues where the previous page ended
+func testQueryLimits(t *testing.T, testStore store.Store) {
+	ctx := context.Background()
+	target := addTarget(t, testStore, "limits")
+	start := hourStart()
+
+	var timestamps []int64
+	for index := int64(0); index < 25; index++ {
+		timestamps = append(timestamps, start+index*10)
+	}
+	addResults(t, testStore, target.ID, timestamps...)
+
+	collect := func(query store.ResultQuery) ([]int64, []int) {
+		t.Helper()
+		var collected []int64
+		var pageSizes []int
+		for {
+			page, err := testStore.QueryResults(ctx, target.ID, query)
+			if err != nil {
+				t.Fatalf("failed to query results: %v", err)
+			}
+			collected = append(collected, timestampsOf(page.Results)...)
+			pageSizes = append(pageSizes, len(page.Results))
+			if page.NextCursor == "" {
+				return collected, pageSizes
+			}
+			query.Cursor = page.NextCursor
+		}
+	}
+
+	ascending, pageSizes := collect(store.ResultQuery{Limit: 10})
+	if !slices.Equal(ascending, timestamps) || !slices.Equal(pageSizes, []int{10, 10, 5}) {
+		t.Fatalf("expected pages of 10, 10 and 5 oldest first, got %v in %v", ascending, pageSizes)
+	}
+
+	descending, pageSizes := collect(store.ResultQuery{Limit: 10, Descending: true})
+	reversed := slices.Clone(timestamps)
+	slices.Reverse(reversed)
+	if !slices.Equal(descending, reversed) || !slices.Equal(pageSizes, []int{10, 10, 5}) {
+		t.Fatalf("expected pages of 10, 10 and 5 newest first, got %v in %v", descending, pageSizes)
+	}
+
+	// an exact multiple of the limit ends without an empty page
+	if _, pageSizes := collect(store.ResultQuery{Limit: 25}); !slices.Equal(pageSizes, []int{25}) {
+		t.Fatalf("expected a single full page, got %v", pageSizes)
+	}
+
+	// without a limit the default applies
+	if all, _ := collect(store.ResultQuery{}); len(all) != 25 {
+		t.Fatalf("expected every result with the default limit, got %d", len(all))
+	}
+
+	ranged, _ := collect(store.ResultQuery{From: time.Unix(start+50, 0), To: time.Unix(start+90, 0), Limit: 2})
+	if want := []int64{start + 50, start + 60, start + 70, start + 80, start + 90}; !slices.Equal(ranged, want) {
+		t.Fatalf("expected %v within the range, got %v", want, ranged)
+	}
+
+	if _, err := testStore.QueryResults(ctx, target.ID, store.ResultQuery{Cursor: "not a cursor"}); !errors.Is(err, store.ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+// every result is merged into the bucket of each step
+func testRollups(t *testing.T, testStore store.Store) {
+	ctx := context.Background()
+	target := addTarget(t, testStore, "rollups")
+	start := hourStart()
+
+	addResults(t, testStore, target.ID, start, start+30, start+90)
+	if err := testStore.AddResult(ctx, model.Result{TargetID: target.ID, Status: model.StatusDown, HTTPStatus: 503, Timestamp: start + 120}); err != nil {
+		t.Fatalf("failed to add result: %v", err)
+	}
+
+	minutes, err := testStore.Rollups(ctx, target.ID, time.Minute, time.Unix(start, 0), time.Unix(start+3599, 0))
+	if err != nil {
+		t.Fatalf("failed to get minute rollups: %v", err)
+	}
+	var starts []int64
+	for _, bucket := range minutes {
+		starts = append(starts, bucket.Start)
+	}
+	if !slices.Equal(starts, []int64{start, start + 60, start + 120}) || minutes[0].Count != 2 {
+		t.Fatalf("expected three minute buckets oldest first, got %+v", minutes)
+	}
+
+	hours, err := testStore.Rollups(ctx, target.ID, time.Hour, time.Unix(start, 0), time.Unix(start, 0))
+	if err != nil || len(hours) != 1 {
+		t.Fatalf("expected one hour bucket, got %+v (%v)", hours, err)
+	}
+	if hour := hours[0]; hour.Count != 4 || hour.UpCount != 3 || hour.MinLatencyMs != 100 || hour.LatencySumMs != 300 {
+		t.Fatalf("unexpected hour bucket: %+v", hour)
+	}
+}
+
+// incidents are listed newest first and updates only touch their own fields
+func testIncidents(t *testing.T, testStore store.Store) {
+	ctx := context.Background()
+	target := addTarget(t, testStore, "incidents")
+	start := hourStart()
+
+	older, err := testStore.AddIncident(ctx, model.Incident{TargetID: target.ID, Status: model.IncidentOpen, StartTime: start})
+	if err != nil {
+		t.Fatalf("failed to add incident: %v", err)
+	}
+	newer, _ := testStore.AddIncident(ctx, model.Incident{TargetID: target.ID, Status: model.IncidentOpen, StartTime: start + 600})
+
+	incidents, err := testStore.IncidentsForTarget(ctx, target.ID)
+	if err != nil || len(incidents) != 2 || incidents[0].ID != newer.ID || incidents[1].ID != older.ID {
+		t.Fatalf("expected both incidents newest first, got %+v (%v)", incidents, err)
+	}
+
+	if _, err := testStore.AcknowledgeIncident(ctx, older.ID, "alice", start+60); err != nil {
+		t.Fatalf("failed to acknowledge incident: %v", err)
+	}
+	resolved, err := testStore.ResolveIncident(ctx, older.ID, start+300)
+	if err != nil || resolved.Status != model.IncidentResolved || resolved.EndTime != start+300 || resolved.AcknowledgedBy != "alice" {
+		t.Fatalf("expected a resolved, acknowledged incident, got %+v (%v)", resolved, err)
+	}
+}
+
+// concurrent writers don't lose results, rollup counts, notes or IDs
+func testConcurrentWrites(t *testing.T, testStore store.Store) {
+	ctx := context.Background()
+	target := addTarget(t, testStore, "concurrent")
+	incident, err := testStore.AddIncident(ctx, model.Incident{TargetID: target.ID, Status: model.IncidentOpen, StartTime: hourStart()})
+	if err != nil {
+		t.Fatalf("failed to add incident: %v", err)
+	}
+	start := hourStart()
+
+	var waitGroup sync.WaitGroup
+	errs := make(chan error, writers*(resultsPerWriter+2))
+	ids := make(chan string, writers)
+	for writer := 0; writer < writers; writer++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			// writers interleave: writer w owns the timestamps start+w, start+w+writers, ...
+			for index := 0; index < resultsPerWriter; index++ {
+				timestamp := start + int64(index*writers+writer)
+				errs <- testStore.AddResult(ctx, model.Result{TargetID: target.ID, Status: model.StatusUp, HTTPStatus: 200, LatencyMs: 100, Timestamp: timestamp})
+			}
+
+			other, err := testStore.AddTarget(ctx, model.Target{Name: fmt.Sprintf("concurrent-%d", writer), URL: "https://concurrent.example.com"})
+			errs <- err
+			if err == nil {
+				ids <- other.ID
+				t.Cleanup(func() { _ = testStore.DeleteTarget(context.Background(), other.ID, true) })
+			}
+
+			_, err = testStore.AddIncidentNote(ctx, incident.ID, model.IncidentNote{Text: fmt.Sprintf("note %d", writer), Timestamp: start})
+			errs <- err
+		}()
+	}
+	waitGroup.Wait()
+	close(errs)
+	close(ids)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent write failed: %v", err)
+		}
+	}
+
+	uniqueIDs := map[string]bool{target.ID: true}
+	for id := range ids {
+		if uniqueIDs[id] {
+			t.Fatalf("expected unique target IDs, got %s twice", id)
+		}
+		uniqueIDs[id] = true
+	}
+
+	total := writers * resultsPerWriter
+	history, err := testStore.ResultsForTarget(ctx, target.ID)
+	if err != nil || len(history) != total {
+		t.Fatalf("expected %d results, got %d (%v)", total, len(history), err)
+	}
+	if !slices.IsSortedFunc(history, func(a, b model.Result) int { return int(a.Timestamp - b.Timestamp) }) {
+		t.Fatalf("expected the history oldest first, got %v", timestampsOf(history))
+	}
+
+	if latest, ok := latestFor(t, testStore, target.ID); !ok || latest.Timestamp != start+int64(total-1) {
+		t.Fatalf("expected the newest result as latest, got %+v", latest)
+	}
+
+	hours, err := testStore.Rollups(ctx, target.ID, time.Hour, time.Unix(start, 0), time.Unix(start, 0))
+	if err != nil || len(hours) != 1 || hours[0].Count != total || hours[0].LatencySumMs != int64(total)*100 {
+		t.Fatalf("expected every result in the hour bucket, got %+v (%v)", hours, err)
+	}
+
+	stored, err := testStore.GetIncident(ctx, incident.ID)
+	if err != nil || len(stored.Notes) != writers {
+		t.Fatalf("expected %d notes, got %+v (%v)", writers, stored.Notes, err)
+	}
+}
+
+// compaction keeps what the policy of each target allows, the newest results first
+func testRetention(t *testing.T, testStore store.Store) {
+	compactor, ok := testStore.(store.Compactor)
+	if !ok {
+		t.Skip("the store expires results through a ttl")
+	}
+	ctx := context.Background()
+	start := hourStart()
+
+	byDefault := addTarget(t, testStore, "retention-default")
+	limited := addTarget(t, testStore, "retention-limited")
+	limited.MaxResults = 3
+	longer := addTarget(t, testStore, "retention-longer")
+	longer.RetentionDays = 1
+	for _, target := range []model.Target{limited, longer} {
+		if _, err := testStore.UpdateTarget(ctx, target); err != nil {
+			t.Fatalf("failed to update target: %v", err)
+		}
+	}
+
+	var timestamps []int64
+	for index := int64(0); index < 10; index++ {
+		timestamps = append(timestamps, start+index*10)
+	}
+	for _, target := range []model.Target{byDefault, limited, longer} {
+		addResults(t, testStore, target.ID, timestamps...)
+	}
+
+	// an hour after the sixth result: the default keeps the last five, the limited target only three of those
+	defaults := retention.Policy{MaxAge: time.Hour}
+	if _, err := compactor.Compact(ctx, defaults, time.Unix(start+50, 0).Add(time.Hour)); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+
+	for _, check := range []struct {
+		target model.Target
+		want   []int64
+	}{
+		{byDefault, timestamps[5:]},
+		{limited, timestamps[7:]},
+		{longer, timestamps},
+	} {
+		history, err := testStore.ResultsForTarget(ctx, check.target.ID)
+		if err != nil || !slices.Equal(timestampsOf(history), check.want) {
+			t.Fatalf("%s: expected %v after compaction, got %v (%v)", check.target.Name, check.want, timestampsOf(history), err)
+		}
+	}
+
+	// the latest result and the rollups of the kept hour stay
+	if latest, ok := latestFor(t, testStore, limited.ID); !ok || latest.Timestamp != timestamps[9] {
+		t.Fatalf("expected the latest result to stay, got %+v", latest)
+	}
+	if hours, _ := testStore.Rollups(ctx, byDefault.ID, time.Hour, time.Unix(start, 0), time.Unix(start, 0)); len(hours) != 1 || hours[0].Count != 10 {
+		t.Fatalf("expected the hour bucket to stay, got %+v", hours)
+	}
+
+	// once a bucket is past its retention, it goes too
+	if _, err := compactor.Compact(ctx, defaults, time.Unix(start, 0).Add(8*24*time.Hour)); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+	if minutes, _ := testStore.Rollups(ctx, byDefault.ID, time.Minute, time.Unix(start, 0), time.Unix(start+3599, 0)); len(minutes) != 0 {
+		t.Fatalf("expected the minute buckets to expire after a week, got %+v", minutes)
+	}
+	if hours, _ := testStore.Rollups(ctx, byDefault.ID, time.Hour, time.Unix(start, 0), time.Unix(start, 0)); len(hours) != 1 {
+		t.Fatalf("expected the hour bucket to be kept for 90 days, got %+v", hours)
+	}
+}
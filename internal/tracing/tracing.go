@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies cloudpulse's own spans
+const instrumentationName = "github.com/sspier/cloudpulse"
+
+// Tracer returns the tracer the cloudpulse packages create their spans with
+// without Setup (or without an endpoint) it is a no-op tracer, so instrumented code costs next to nothing
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the global tracer provider when an OTLP endpoint is configured
+//
+//	OTEL_EXPORTER_OTLP_ENDPOINT          e.g. http://otel-collector:4318, spans are sent over OTLP/HTTP
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT   full url for traces only, takes precedence
+//	OTEL_SERVICE_NAME                    defaults to the given service name
+//
+// the other standard OTEL_EXPORTER_OTLP_* variables (headers, timeout, ...) are honored by the exporter
+// the returned function flushes pending spans, call it before the process (or a lambda invocation) ends
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	// always accept and forward w3c trace context, even when we don't export anything ourselves
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+	}
+
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		serviceName = name
+	}
+	serviceResource, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build otel resource: %w", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+	)
+	otel.SetTracerProvider(tracerProvider)
+
+	return tracerProvider.ForceFlush, nil
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+// WriteHeader records the status code before passing it on
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	recorder.statusCode = statusCode
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+// InstrumentHandler wraps every request in a server span, continuing the caller's trace if it sent one
+// the span is named after the matched route pattern, e.g. "GET /results/{id}"
+func InstrumentHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+		ctx, span := Tracer().Start(ctx, request.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(request.Method),
+				semconv.URLPath(request.URL.Path),
+			),
+		)
+		defer span.End()
+
+		tracedRequest := request.WithContext(ctx)
+		recorder := &statusRecorder{ResponseWriter: responseWriter, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, tracedRequest)
+
+		// the mux fills in the pattern it matched while serving
+		if tracedRequest.Pattern != "" {
+			span.SetName(request.Method + " " + tracedRequest.Pattern)
+			span.SetAttributes(semconv.HTTPRoute(tracedRequest.Pattern))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.statusCode))
+		if recorder.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.statusCode))
+		}
+	})
+}
+
+// End records err (if any) on the span and ends it
+// meant to be deferred with a pointer to a named error result: defer tracing.End(span, &err)
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}